@@ -0,0 +1,115 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"github.com/varun425/MiniClubChaincode/events"
+)
+
+// chaincodeEvent is a chaincode event as received from the gateway or read from a fixture
+type chaincodeEvent struct {
+	BlockNumber   uint64          `json:"blocknumber"`
+	TransactionID string          `json:"txid"`
+	ChaincodeName string          `json:"chaincode"`
+	EventName     string          `json:"name"`
+	Payload       json.RawMessage `json:"payload"`
+}
+
+// Decoded is one event forwarded to the sinks, envelopes are split into their events
+// Version is 0 for events emitted outside an envelope
+type Decoded struct {
+	BlockNumber   uint64          `json:"blocknumber"`
+	TransactionID string          `json:"txid"`
+	Name          string          `json:"name"`
+	Version       int             `json:"version"`
+	Payload       json.RawMessage `json:"payload"`
+}
+
+// transferEvent is the payload of the erc20 Transfer and Approval events
+type transferEvent struct {
+	From  string `json:"from"`
+	To    string `json:"to"`
+	Value int    `json:"value"`
+}
+
+// decode splits a chaincode event into the events it carries and checks the payloads of the known events
+func decode(event *chaincodeEvent) ([]Decoded, error) {
+
+	if event.EventName != events.EnvelopeName {
+		decoded := Decoded{
+			BlockNumber:   event.BlockNumber,
+			TransactionID: event.TransactionID,
+			Name:          event.EventName,
+			Payload:       event.Payload,
+		}
+
+		err := checkPayload(decoded)
+		if err != nil {
+			return nil, err
+		}
+
+		return []Decoded{decoded}, nil
+	}
+
+	envelope := new(events.Envelope)
+	err := json.Unmarshal(event.Payload, envelope)
+	if err != nil {
+		return nil, fmt.Errorf("invalid envelope in transaction %v: %v", event.TransactionID, err)
+	}
+
+	if envelope.Version > events.SchemaVersion {
+		return nil, fmt.Errorf("envelope version %v of transaction %v is newer than the supported version %v", envelope.Version, event.TransactionID, events.SchemaVersion)
+	}
+
+	decoded := []Decoded{}
+	for _, subEvent := range envelope.Events {
+		next := Decoded{
+			BlockNumber:   event.BlockNumber,
+			TransactionID: event.TransactionID,
+			Name:          subEvent.Name,
+			Version:       subEvent.Version,
+			Payload:       subEvent.Payload,
+		}
+
+		err = checkPayload(next)
+		if err != nil {
+			return nil, err
+		}
+
+		decoded = append(decoded, next)
+	}
+
+	return decoded, nil
+}
+
+// checkPayload returns an error if the payload of a Transfer, Approval or membership lifecycle event does not decode
+// Other events are forwarded as they are
+func checkPayload(decoded Decoded) error {
+
+	var payload interface{}
+
+	switch decoded.Name {
+	case "Transfer", "Approval":
+		payload = new(transferEvent)
+	case events.UserRegistered:
+		payload = new(events.UserRegisteredEvent)
+	case events.MembershipCreated:
+		payload = new(events.MembershipCreatedEvent)
+	case events.MembershipUpgraded:
+		payload = new(events.MembershipUpgradedEvent)
+	case events.MembershipCancelled:
+		payload = new(events.MembershipCancelledEvent)
+	case events.MembershipExpired:
+		payload = new(events.MembershipExpiredEvent)
+	default:
+		return nil
+	}
+
+	err := json.Unmarshal(decoded.Payload, payload)
+	if err != nil {
+		return fmt.Errorf("invalid %v event in transaction %v: %v", decoded.Name, decoded.TransactionID, err)
+	}
+
+	return nil
+}
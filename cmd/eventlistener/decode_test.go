@@ -0,0 +1,97 @@
+package main
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/varun425/MiniClubChaincode/events"
+)
+
+func TestDecodeSplitsEnvelopes(t *testing.T) {
+
+	event := fixtureEvents(t)[0]
+
+	decoded, err := decode(event)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(decoded) != 2 {
+		t.Fatalf("decoded %v events, want 2", len(decoded))
+	}
+
+	for i, name := range []string{"Transfer", events.UserRegistered} {
+		next := decoded[i]
+		if next.Name != name || next.Version != events.SchemaVersion || next.BlockNumber != 5 || next.TransactionID != event.TransactionID {
+			t.Errorf("event %v is %v version %v of block %v transaction %v, want %v version %v of block 5 transaction %v",
+				i, next.Name, next.Version, next.BlockNumber, next.TransactionID, name, events.SchemaVersion, event.TransactionID)
+		}
+	}
+
+	transfer := new(transferEvent)
+	_ = json.Unmarshal(decoded[0].Payload, transfer)
+	if transfer.Value != 100 {
+		t.Errorf("transfer of %v, want 100", transfer.Value)
+	}
+}
+
+func TestDecodePlainEvent(t *testing.T) {
+
+	decoded, err := decode(&chaincodeEvent{
+		BlockNumber:   8,
+		TransactionID: "tx",
+		EventName:     "Approval",
+		Payload:       json.RawMessage(`{"from":"alice","to":"club","value":10}`),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(decoded) != 1 || decoded[0].Name != "Approval" || decoded[0].Version != 0 {
+		t.Errorf("decoded %+v, want the Approval event without version", decoded)
+	}
+}
+
+func TestDecodeRejectsNewerEnvelopes(t *testing.T) {
+
+	envelope, _ := json.Marshal(events.Envelope{
+		Version: events.SchemaVersion + 1,
+		TxID:    "tx",
+		Events:  []events.Event{{Name: "Transfer", Version: events.SchemaVersion + 1, Payload: json.RawMessage(`{"from":"alice","to":"club","value":10}`)}},
+	})
+
+	_, err := decode(&chaincodeEvent{TransactionID: "tx", EventName: events.EnvelopeName, Payload: envelope})
+	if err == nil {
+		t.Errorf("envelope of version %v decoded", events.SchemaVersion+1)
+	}
+}
+
+func TestDecodeRejectsMalformedPayloads(t *testing.T) {
+
+	envelope := func(name string, payload string) json.RawMessage {
+		content, _ := json.Marshal(events.Envelope{
+			Version: events.SchemaVersion,
+			TxID:    "tx",
+			Events:  []events.Event{{Name: name, Version: events.SchemaVersion, Payload: json.RawMessage(payload)}},
+		})
+		return content
+	}
+
+	for _, event := range []*chaincodeEvent{
+		{EventName: events.EnvelopeName, Payload: json.RawMessage(`["not","an","envelope"]`)},
+		{EventName: events.EnvelopeName, Payload: envelope("Transfer", `{"from":"alice","to":"club","value":"ten"}`)},
+		{EventName: events.EnvelopeName, Payload: envelope(events.MembershipCreated, `{"price":"free"}`)},
+		{EventName: "Transfer", Payload: json.RawMessage(`"alice to club"`)},
+	} {
+		event.TransactionID = "tx"
+		if decoded, err := decode(event); err == nil {
+			t.Errorf("%v event %s decoded to %+v", event.EventName, event.Payload, decoded)
+		}
+	}
+
+	// unknown events are forwarded as they are
+	decoded, err := decode(&chaincodeEvent{TransactionID: "tx", EventName: events.EnvelopeName, Payload: envelope("ClassBooked", `"any payload"`)})
+	if err != nil || len(decoded) != 1 {
+		t.Errorf("unknown event decoded to %+v, %v", decoded, err)
+	}
+}
@@ -0,0 +1,94 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"os"
+
+	"github.com/hyperledger/fabric-gateway/pkg/client"
+)
+
+// replay passes the events recorded in a fixture, one JSON chaincode event per line, to handle
+// The checkpointer is used as with a live network: events before the checkpoint are skipped and handled events are checkpointed
+func replay(path string, checkpointer *client.FileCheckpointer, handle func(*chaincodeEvent) error) error {
+
+	file, err := os.Open(path)
+	if err != nil {
+		return fmt.Errorf("failed to open fixture: %v", err)
+	}
+	defer file.Close()
+
+	// within the checkpointed block, skip up to and including the checkpointed transaction
+	skipping := checkpointer.TransactionID() != ""
+
+	scanner := bufio.NewScanner(file)
+	scanner.Buffer(make([]byte, 1024*1024), 1024*1024)
+
+	for line := 1; scanner.Scan(); line++ {
+		if len(scanner.Bytes()) == 0 {
+			continue
+		}
+
+		event := new(chaincodeEvent)
+		err = json.Unmarshal(scanner.Bytes(), event)
+		if err != nil {
+			return fmt.Errorf("invalid event on line %v of %v: %v", line, path, err)
+		}
+
+		if event.BlockNumber < checkpointer.BlockNumber() {
+			continue
+		}
+
+		if skipping && event.BlockNumber == checkpointer.BlockNumber() {
+			if event.TransactionID == checkpointer.TransactionID() {
+				skipping = false
+			}
+			continue
+		}
+		skipping = false
+
+		err = handle(event)
+		if err != nil {
+			return err
+		}
+
+		err = checkpointer.CheckpointChaincodeEvent(&client.ChaincodeEvent{
+			BlockNumber:   event.BlockNumber,
+			TransactionID: event.TransactionID,
+			ChaincodeName: event.ChaincodeName,
+			EventName:     event.EventName,
+			Payload:       event.Payload,
+		})
+		if err != nil {
+			return fmt.Errorf("failed to checkpoint transaction %v: %v", event.TransactionID, err)
+		}
+	}
+
+	return scanner.Err()
+}
+
+// recorder appends the chaincode events received from the network to a fixture
+type recorder struct {
+	encoder *json.Encoder
+	file    *os.File
+}
+
+// newRecorder opens the fixture file to record to
+func newRecorder(path string) (*recorder, error) {
+
+	file, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open %v: %v", path, err)
+	}
+
+	return &recorder{encoder: json.NewEncoder(file), file: file}, nil
+}
+
+func (r *recorder) record(event *chaincodeEvent) error {
+	return r.encoder.Encode(event)
+}
+
+func (r *recorder) Close() error {
+	return r.file.Close()
+}
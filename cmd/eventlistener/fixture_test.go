@@ -0,0 +1,158 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/hyperledger/fabric-gateway/pkg/client"
+)
+
+const fixturePath = "testdata/events.jsonl"
+
+// fixtureEvents returns the events recorded in the fixture, in order
+func fixtureEvents(t *testing.T) []*chaincodeEvent {
+
+	t.Helper()
+
+	file, err := os.Open(fixturePath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer file.Close()
+
+	recorded := []*chaincodeEvent{}
+	scanner := bufio.NewScanner(file)
+	scanner.Buffer(make([]byte, 1024*1024), 1024*1024)
+	for scanner.Scan() {
+		event := new(chaincodeEvent)
+		err = json.Unmarshal(scanner.Bytes(), event)
+		if err != nil {
+			t.Fatal(err)
+		}
+		recorded = append(recorded, event)
+	}
+
+	return recorded
+}
+
+// transactionIDs returns the transaction ids of the events
+func transactionIDs(recorded []*chaincodeEvent) []string {
+
+	ids := []string{}
+	for _, event := range recorded {
+		ids = append(ids, event.TransactionID)
+	}
+
+	return ids
+}
+
+// replayFixture replays the fixture with the checkpoint file and returns the handled events
+func replayFixture(t *testing.T, checkpointPath string, handle func(*chaincodeEvent) error) ([]*chaincodeEvent, error) {
+
+	t.Helper()
+
+	checkpointer, err := client.NewFileCheckpointer(checkpointPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer checkpointer.Close()
+
+	handled := []*chaincodeEvent{}
+	err = replay(fixturePath, checkpointer, func(event *chaincodeEvent) error {
+		if handle != nil {
+			err := handle(event)
+			if err != nil {
+				return err
+			}
+		}
+		handled = append(handled, event)
+		return nil
+	})
+
+	return handled, err
+}
+
+func TestReplayCheckpointsEveryEvent(t *testing.T) {
+
+	recorded := fixtureEvents(t)
+	checkpointPath := filepath.Join(t.TempDir(), "checkpoint.json")
+
+	handled, err := replayFixture(t, checkpointPath, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(transactionIDs(handled), transactionIDs(recorded)) {
+		t.Errorf("handled %v, want %v", transactionIDs(handled), transactionIDs(recorded))
+	}
+
+	// a restarted listener has nothing left to replay
+	handled, err = replayFixture(t, checkpointPath, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(handled) != 0 {
+		t.Errorf("replayed %v again", transactionIDs(handled))
+	}
+}
+
+func TestReplayResumesFromCheckpointMidBlock(t *testing.T) {
+
+	recorded := fixtureEvents(t)
+	checkpointPath := filepath.Join(t.TempDir(), "checkpoint.json")
+
+	// the first of the two transactions of block 6 was forwarded
+	if recorded[1].BlockNumber != 6 || recorded[2].BlockNumber != 6 {
+		t.Fatalf("fixture has no block with two transactions at lines 2 and 3")
+	}
+
+	checkpointer, err := client.NewFileCheckpointer(checkpointPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = checkpointer.CheckpointChaincodeEvent(&client.ChaincodeEvent{BlockNumber: 6, TransactionID: recorded[1].TransactionID})
+	if err != nil {
+		t.Fatal(err)
+	}
+	checkpointer.Close()
+
+	handled, err := replayFixture(t, checkpointPath, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(transactionIDs(handled), transactionIDs(recorded[2:])) {
+		t.Errorf("handled %v, want %v", transactionIDs(handled), transactionIDs(recorded[2:]))
+	}
+}
+
+func TestReplayDoesNotCheckpointFailedEvents(t *testing.T) {
+
+	recorded := fixtureEvents(t)
+	checkpointPath := filepath.Join(t.TempDir(), "checkpoint.json")
+
+	errSink := errors.New("sink unavailable")
+	failing := recorded[4].TransactionID
+
+	_, err := replayFixture(t, checkpointPath, func(event *chaincodeEvent) error {
+		if event.TransactionID == failing {
+			return errSink
+		}
+		return nil
+	})
+	if !errors.Is(err, errSink) {
+		t.Fatalf("replay returned %v, want %v", err, errSink)
+	}
+
+	// the failed event is handled again once the sink is back
+	handled, err := replayFixture(t, checkpointPath, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(transactionIDs(handled), transactionIDs(recorded[4:])) {
+		t.Errorf("handled %v, want %v", transactionIDs(handled), transactionIDs(recorded[4:]))
+	}
+}
@@ -0,0 +1,140 @@
+package main
+
+import (
+	"context"
+	"crypto/x509"
+	"fmt"
+	"os"
+
+	"github.com/hyperledger/fabric-gateway/pkg/client"
+	"github.com/hyperledger/fabric-gateway/pkg/identity"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials"
+)
+
+// gatewayConfig holds the connection settings of the Fabric Gateway peer and the identity of the listener
+type gatewayConfig struct {
+	PeerEndpoint string
+	HostOverride string
+	TLSCertPath  string
+	MSPID        string
+	CertPath     string
+	KeyPath      string
+	Channel      string
+	Chaincode    string
+}
+
+// listen receives the chaincode events of the contract from the checkpoint on and passes each to handle
+// An event is checkpointed once handled, so a restarted listener resumes after the last handled event
+func listen(ctx context.Context, config gatewayConfig, checkpointer *client.FileCheckpointer, handle func(*chaincodeEvent) error) error {
+
+	connection, err := newGrpcConnection(config)
+	if err != nil {
+		return err
+	}
+	defer connection.Close()
+
+	id, sign, err := newIdentity(config)
+	if err != nil {
+		return err
+	}
+
+	gateway, err := client.Connect(id, client.WithSign(sign), client.WithClientConnection(connection))
+	if err != nil {
+		return fmt.Errorf("failed to connect to the gateway: %v", err)
+	}
+	defer gateway.Close()
+
+	network := gateway.GetNetwork(config.Channel)
+
+	events, err := network.ChaincodeEvents(ctx, config.Chaincode, client.WithCheckpoint(checkpointer))
+	if err != nil {
+		return fmt.Errorf("failed to subscribe to chaincode events: %v", err)
+	}
+
+	for event := range events {
+		err = handle(&chaincodeEvent{
+			BlockNumber:   event.BlockNumber,
+			TransactionID: event.TransactionID,
+			ChaincodeName: event.ChaincodeName,
+			EventName:     event.EventName,
+			Payload:       event.Payload,
+		})
+		if err != nil {
+			return err
+		}
+
+		err = checkpointer.CheckpointChaincodeEvent(event)
+		if err != nil {
+			return fmt.Errorf("failed to checkpoint transaction %v: %v", event.TransactionID, err)
+		}
+	}
+
+	// the event channel closes when the context is cancelled or the connection fails
+	if ctx.Err() != nil {
+		return nil
+	}
+
+	return fmt.Errorf("chaincode event stream closed")
+}
+
+// newGrpcConnection opens a TLS connection to the gateway peer
+func newGrpcConnection(config gatewayConfig) (*grpc.ClientConn, error) {
+
+	certificatePEM, err := os.ReadFile(config.TLSCertPath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read TLS certificate: %v", err)
+	}
+
+	certificate, err := identity.CertificateFromPEM(certificatePEM)
+	if err != nil {
+		return nil, fmt.Errorf("invalid TLS certificate: %v", err)
+	}
+
+	certPool := x509.NewCertPool()
+	certPool.AddCert(certificate)
+	transportCredentials := credentials.NewClientTLSFromCert(certPool, config.HostOverride)
+
+	connection, err := grpc.NewClient(config.PeerEndpoint, grpc.WithTransportCredentials(transportCredentials))
+	if err != nil {
+		return nil, fmt.Errorf("failed to create gRPC connection: %v", err)
+	}
+
+	return connection, nil
+}
+
+// newIdentity reads the X.509 certificate and private key of the listener
+func newIdentity(config gatewayConfig) (*identity.X509Identity, identity.Sign, error) {
+
+	certificatePEM, err := os.ReadFile(config.CertPath)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to read certificate: %v", err)
+	}
+
+	certificate, err := identity.CertificateFromPEM(certificatePEM)
+	if err != nil {
+		return nil, nil, fmt.Errorf("invalid certificate: %v", err)
+	}
+
+	id, err := identity.NewX509Identity(config.MSPID, certificate)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	privateKeyPEM, err := os.ReadFile(config.KeyPath)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to read private key: %v", err)
+	}
+
+	privateKey, err := identity.PrivateKeyFromPEM(privateKeyPEM)
+	if err != nil {
+		return nil, nil, fmt.Errorf("invalid private key: %v", err)
+	}
+
+	sign, err := identity.NewPrivateKeySign(privateKey)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return id, sign, nil
+}
@@ -0,0 +1,105 @@
+// Command eventlistener forwards the chaincode events of the MiniClub contract to off-chain sinks
+//
+// It connects to a peer through the Fabric Gateway, or replays a recorded fixture with -fixture,
+// and checkpoints every forwarded event so that a restarted listener resumes where it stopped.
+package main
+
+import (
+	"context"
+	"flag"
+	"log"
+	"os"
+	"os/signal"
+	"syscall"
+
+	"github.com/hyperledger/fabric-gateway/pkg/client"
+)
+
+func main() {
+
+	config := gatewayConfig{}
+	flag.StringVar(&config.PeerEndpoint, "peer", "localhost:7051", "gateway peer endpoint")
+	flag.StringVar(&config.HostOverride, "hostoverride", "peer0.org1.example.com", "TLS host name of the gateway peer")
+	flag.StringVar(&config.TLSCertPath, "tlscert", "", "TLS CA certificate of the gateway peer")
+	flag.StringVar(&config.MSPID, "mspid", "Org1MSP", "MSP ID of the listener identity")
+	flag.StringVar(&config.CertPath, "cert", "", "certificate of the listener identity")
+	flag.StringVar(&config.KeyPath, "key", "", "private key of the listener identity")
+	flag.StringVar(&config.Channel, "channel", "mychannel", "channel of the contract")
+	flag.StringVar(&config.Chaincode, "chaincode", "miniclub", "chaincode name of the contract")
+
+	checkpointPath := flag.String("checkpoint", "checkpoint.json", "file the last forwarded event is checkpointed to")
+	sinkNames := flag.String("sinks", "stdout", "comma separated sinks: stdout, webhook, file")
+	webhookURL := flag.String("webhook", "", "URL the webhook sink posts events to")
+	outPath := flag.String("out", "", "file the file sink appends events to")
+	fixturePath := flag.String("fixture", "", "replay the events recorded in this file instead of connecting to a peer")
+	recordPath := flag.String("record", "", "append the events received from the peer to this fixture")
+	flag.Parse()
+
+	sinks, err := newSinks(*sinkNames, *webhookURL, *outPath)
+	if err != nil {
+		log.Fatalf("error:%v", err)
+	}
+	defer func() {
+		for _, sink := range sinks {
+			sink.Close()
+		}
+	}()
+
+	checkpointer, err := client.NewFileCheckpointer(*checkpointPath)
+	if err != nil {
+		log.Fatalf("failed to open checkpoint %v: %v", *checkpointPath, err)
+	}
+	defer checkpointer.Close()
+
+	var rec *recorder
+	if *recordPath != "" {
+		rec, err = newRecorder(*recordPath)
+		if err != nil {
+			log.Fatalf("error:%v", err)
+		}
+		defer rec.Close()
+	}
+
+	handle := func(event *chaincodeEvent) error {
+		if rec != nil {
+			err := rec.record(event)
+			if err != nil {
+				return err
+			}
+		}
+
+		decoded, err := decode(event)
+		if err != nil {
+			return err
+		}
+
+		for _, next := range decoded {
+			for _, sink := range sinks {
+				err = sink.Send(next)
+				if err != nil {
+					return err
+				}
+			}
+		}
+
+		return nil
+	}
+
+	if *fixturePath != "" {
+		err = replay(*fixturePath, checkpointer, handle)
+		if err != nil {
+			log.Fatalf("error:%v", err)
+		}
+		return
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	log.Printf("listening to %v on %v from block %v", config.Chaincode, config.Channel, checkpointer.BlockNumber())
+
+	err = listen(ctx, config, checkpointer, handle)
+	if err != nil {
+		log.Fatalf("error:%v", err)
+	}
+}
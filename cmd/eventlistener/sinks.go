@@ -0,0 +1,94 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+)
+
+// Sink receives the decoded events, an error stops the listener before the event is checkpointed
+type Sink interface {
+	Send(event Decoded) error
+	Close() error
+}
+
+// newSinks creates the sinks named in the comma separated list: stdout, webhook and file
+func newSinks(names string, webhookURL string, filePath string) ([]Sink, error) {
+
+	sinks := []Sink{}
+	for _, name := range strings.Split(names, ",") {
+		switch strings.TrimSpace(name) {
+		case "stdout":
+			sinks = append(sinks, &writerSink{encoder: json.NewEncoder(os.Stdout)})
+		case "webhook":
+			if webhookURL == "" {
+				return nil, fmt.Errorf("the webhook sink requires -webhook")
+			}
+			sinks = append(sinks, &webhookSink{url: webhookURL, client: &http.Client{Timeout: 10 * time.Second}})
+		case "file":
+			if filePath == "" {
+				return nil, fmt.Errorf("the file sink requires -out")
+			}
+			file, err := os.OpenFile(filePath, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+			if err != nil {
+				return nil, fmt.Errorf("failed to open %v: %v", filePath, err)
+			}
+			sinks = append(sinks, &writerSink{encoder: json.NewEncoder(file), closer: file})
+		default:
+			return nil, fmt.Errorf("unknown sink %v, expected stdout, webhook or file", name)
+		}
+	}
+
+	return sinks, nil
+}
+
+// writerSink writes every event as one line of JSON, to stdout or to a file
+type writerSink struct {
+	encoder *json.Encoder
+	closer  io.Closer
+}
+
+func (s *writerSink) Send(event Decoded) error {
+	return s.encoder.Encode(event)
+}
+
+func (s *writerSink) Close() error {
+
+	if s.closer == nil {
+		return nil
+	}
+
+	return s.closer.Close()
+}
+
+// webhookSink posts every event as JSON to a URL, any status other than 2xx is an error
+type webhookSink struct {
+	url    string
+	client *http.Client
+}
+
+func (s *webhookSink) Send(event Decoded) error {
+
+	body, _ := json.Marshal(event)
+
+	response, err := s.client.Post(s.url, "application/json", bytes.NewReader(body))
+	if err != nil {
+		return fmt.Errorf("failed to post %v event: %v", event.Name, err)
+	}
+	defer response.Body.Close()
+
+	if response.StatusCode < 200 || response.StatusCode > 299 {
+		return fmt.Errorf("webhook answered %v to %v event", response.Status, event.Name)
+	}
+
+	return nil
+}
+
+func (s *webhookSink) Close() error {
+	return nil
+}
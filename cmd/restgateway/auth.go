@@ -0,0 +1,77 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"strings"
+)
+
+// errUnauthenticated is returned for requests without a known API key
+var errUnauthenticated = errors.New("a valid API key is required as Authorization: Bearer <key>")
+
+// apiKeys maps the hex SHA-256 digest of every API key to the wallet identity its requests are signed with
+// The keys themselves are not stored, a digest is created with: printf %s <key> | sha256sum
+type apiKeys map[string]string
+
+// loadAPIKeys reads a JSON object of key digests to wallet labels
+func loadAPIKeys(path string) (apiKeys, error) {
+
+	if path == "" {
+		return nil, fmt.Errorf("an API keys file is required, see -keys")
+	}
+
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read API keys: %v", err)
+	}
+
+	stored := map[string]string{}
+	err = json.Unmarshal(content, &stored)
+	if err != nil {
+		return nil, fmt.Errorf("invalid API keys: %v", err)
+	}
+
+	keys := apiKeys{}
+	for digest, label := range stored {
+		decoded, err := hex.DecodeString(digest)
+		if err != nil || len(decoded) != sha256.Size {
+			return nil, fmt.Errorf("invalid API key digest %v, expected a hex SHA-256 digest", digest)
+		}
+
+		if label == "" {
+			return nil, fmt.Errorf("API key digest %v has no wallet identity", digest)
+		}
+
+		keys[strings.ToLower(digest)] = label
+	}
+
+	if len(keys) == 0 {
+		return nil, fmt.Errorf("no API keys in %v", path)
+	}
+
+	return keys, nil
+}
+
+// authenticate returns the wallet label of the API key the request is made with
+func (k apiKeys) authenticate(r *http.Request) (string, error) {
+
+	authorization := r.Header.Get("Authorization")
+	key := strings.TrimPrefix(authorization, "Bearer ")
+	if key == authorization || key == "" {
+		return "", errUnauthenticated
+	}
+
+	digest := sha256.Sum256([]byte(key))
+
+	label, ok := k[hex.EncodeToString(digest[:])]
+	if !ok {
+		return "", errUnauthenticated
+	}
+
+	return label, nil
+}
@@ -0,0 +1,113 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// recordingBackend returns 20 for every transaction and records the identity it was signed with
+type recordingBackend struct {
+	labels []string
+}
+
+func (b *recordingBackend) Submit(id *Identity, contract string, transaction string, args []string) ([]byte, error) {
+	b.labels = append(b.labels, id.Label)
+	return []byte("20"), nil
+}
+
+func (b *recordingBackend) Evaluate(id *Identity, contract string, transaction string, args []string) ([]byte, error) {
+	b.labels = append(b.labels, id.Label)
+	return []byte("20"), nil
+}
+
+func (b *recordingBackend) Close() error {
+	return nil
+}
+
+func digest(key string) string {
+	sum := sha256.Sum256([]byte(key))
+	return hex.EncodeToString(sum[:])
+}
+
+func TestRequestsAreSignedWithTheIdentityOfTheAPIKey(t *testing.T) {
+
+	metadata, err := loadMetadata("")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	backend := &recordingBackend{}
+	keys := apiKeys{digest("alice-key"): "alice"}
+	handler, err := newServer(backend, &mockWallet{mspID: "Org1MSP", identities: map[string]*Identity{}}, keys, metadata)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, test := range []struct {
+		authorization string
+		status        int
+	}{
+		{"", http.StatusUnauthorized},
+		{"alice-key", http.StatusUnauthorized},
+		{"Bearer ", http.StatusUnauthorized},
+		{"Bearer bob-key", http.StatusUnauthorized},
+		{"Bearer alice-key", http.StatusOK},
+	} {
+		request := httptest.NewRequest(http.MethodGet, "/api/HealthClub/GetTrainerCommission", nil)
+		request.Header.Set("X-Identity", "admin")
+		if test.authorization != "" {
+			request.Header.Set("Authorization", test.authorization)
+		}
+
+		response := httptest.NewRecorder()
+		handler.ServeHTTP(response, request)
+
+		if response.Code != test.status {
+			t.Errorf("authorization %q: status %v, want %v", test.authorization, response.Code, test.status)
+		}
+	}
+
+	// the identity of the key, never the one asked for in the request
+	if len(backend.labels) != 1 || backend.labels[0] != "alice" {
+		t.Errorf("transactions signed as %v, want once as alice", backend.labels)
+	}
+}
+
+func TestLoadAPIKeys(t *testing.T) {
+
+	dir := t.TempDir()
+
+	for _, test := range []struct {
+		content string
+		valid   bool
+	}{
+		{`{"` + digest("alice-key") + `": "alice"}`, true},
+		{`{"alice-key": "alice"}`, false},
+		{`{"` + digest("alice-key") + `": ""}`, false},
+		{`{}`, false},
+		{`[]`, false},
+	} {
+		path := filepath.Join(dir, "keys.json")
+		err := os.WriteFile(path, []byte(test.content), 0600)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		keys, err := loadAPIKeys(path)
+		if test.valid && (err != nil || keys[digest("alice-key")] != "alice") {
+			t.Errorf("keys %v: %v, %v", test.content, keys, err)
+		}
+		if !test.valid && err == nil {
+			t.Errorf("keys %v loaded", test.content)
+		}
+	}
+
+	if _, err := loadAPIKeys(""); err == nil {
+		t.Errorf("gateway started without API keys")
+	}
+}
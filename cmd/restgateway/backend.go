@@ -0,0 +1,101 @@
+package main
+
+import (
+	"errors"
+	"net/http"
+	"strings"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// Backend runs the transactions of the chaincode as the given identity
+// A transaction rejected by the contract is returned as a *contractError
+type Backend interface {
+	Submit(id *Identity, contract string, transaction string, args []string) ([]byte, error)
+	Evaluate(id *Identity, contract string, transaction string, args []string) ([]byte, error)
+	Close() error
+}
+
+// contractError is the error message a transaction was rejected with by the contract
+type contractError struct {
+	message string
+}
+
+func (e *contractError) Error() string {
+	return e.message
+}
+
+// conflictError is a submitted transaction that was endorsed but not committed, e.g. on an MVCC read conflict
+type conflictError struct {
+	message string
+}
+
+func (e *conflictError) Error() string {
+	return e.message
+}
+
+// contractErrorStatus maps fragments of the contract error messages to HTTP status codes, the first match wins
+var contractErrorStatus = []struct {
+	fragment string
+	status   int
+}{
+	{"not supported by the mock backend", http.StatusNotImplemented},
+
+	{"not found", http.StatusNotFound},
+	{"does not exist", http.StatusNotFound},
+	{"not exist", http.StatusNotFound},
+
+	{"access denied", http.StatusForbidden},
+	{"not authorized", http.StatusForbidden},
+	{"only the ", http.StatusForbidden},
+	{"only owner", http.StatusForbidden},
+	{"can perform this operation", http.StatusForbidden},
+
+	{"already", http.StatusConflict},
+	{"insufficient funds", http.StatusConflict},
+	{"has no balance", http.StatusConflict},
+	{"not enough allowance", http.StatusConflict},
+
+	{"invalid", http.StatusBadRequest},
+	{"expected", http.StatusBadRequest},
+	{"acceptable", http.StatusBadRequest},
+	{"must be", http.StatusBadRequest},
+	{"cannot be negative", http.StatusBadRequest},
+	{"cannot exceed", http.StatusBadRequest},
+	{"is required", http.StatusBadRequest},
+	{"is not allowed", http.StatusBadRequest},
+
+	{"failed to ", http.StatusInternalServerError},
+}
+
+// httpStatus returns the HTTP status code of a transaction error
+// Contract rejections not matching contractErrorStatus break a business rule of the contract and are 422
+func httpStatus(err error) int {
+
+	var contractErr *contractError
+	if errors.As(err, &contractErr) {
+		message := strings.ToLower(contractErr.message)
+		for _, rule := range contractErrorStatus {
+			if strings.Contains(message, rule.fragment) {
+				return rule.status
+			}
+		}
+
+		return http.StatusUnprocessableEntity
+	}
+
+	var conflictErr *conflictError
+	if errors.As(err, &conflictErr) {
+		return http.StatusConflict
+	}
+
+	switch status.Code(err) {
+	case codes.Unavailable:
+		return http.StatusServiceUnavailable
+	case codes.DeadlineExceeded:
+		return http.StatusGatewayTimeout
+	}
+
+	return http.StatusBadGateway
+}
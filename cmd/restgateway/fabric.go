@@ -0,0 +1,150 @@
+//go:build !mock
+
+package main
+
+import (
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"os"
+	"regexp"
+
+	"github.com/hyperledger/fabric-gateway/pkg/client"
+	"github.com/hyperledger/fabric-gateway/pkg/identity"
+	"github.com/hyperledger/fabric-protos-go-apiv2/gateway"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/status"
+)
+
+// defaultWallet of the gateway build reads the identities of a Fabric SDK wallet
+const defaultWallet = "file"
+
+// chaincodeResponse matches the endorsement error detail carrying the error message of the contract
+var chaincodeResponse = regexp.MustCompile(`chaincode response \d+, (.*)$`)
+
+// fabricBackend runs the transactions through the Fabric Gateway peer
+// The gRPC connection is shared, every transaction connects a gateway client with the identity of the request
+type fabricBackend struct {
+	config     gatewayConfig
+	connection *grpc.ClientConn
+}
+
+func newBackend(config gatewayConfig) (Backend, error) {
+	return newFabricBackend(config)
+}
+
+func newFabricBackend(config gatewayConfig) (*fabricBackend, error) {
+
+	certificatePEM, err := os.ReadFile(config.TLSCertPath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read TLS certificate: %v", err)
+	}
+
+	certificate, err := identity.CertificateFromPEM(certificatePEM)
+	if err != nil {
+		return nil, fmt.Errorf("invalid TLS certificate: %v", err)
+	}
+
+	certPool := x509.NewCertPool()
+	certPool.AddCert(certificate)
+	transportCredentials := credentials.NewClientTLSFromCert(certPool, config.HostOverride)
+
+	connection, err := grpc.NewClient(config.PeerEndpoint, grpc.WithTransportCredentials(transportCredentials))
+	if err != nil {
+		return nil, fmt.Errorf("failed to create gRPC connection: %v", err)
+	}
+
+	return &fabricBackend{config: config, connection: connection}, nil
+}
+
+func (b *fabricBackend) Submit(id *Identity, contract string, transaction string, args []string) ([]byte, error) {
+
+	gw, err := b.connect(id)
+	if err != nil {
+		return nil, err
+	}
+	defer gw.Close()
+
+	result, err := gw.GetNetwork(b.config.Channel).GetContractWithName(b.config.Chaincode, contract).SubmitTransaction(transaction, args...)
+	if err != nil {
+		return nil, transactionError(err)
+	}
+
+	return result, nil
+}
+
+func (b *fabricBackend) Evaluate(id *Identity, contract string, transaction string, args []string) ([]byte, error) {
+
+	gw, err := b.connect(id)
+	if err != nil {
+		return nil, err
+	}
+	defer gw.Close()
+
+	result, err := gw.GetNetwork(b.config.Channel).GetContractWithName(b.config.Chaincode, contract).EvaluateTransaction(transaction, args...)
+	if err != nil {
+		return nil, transactionError(err)
+	}
+
+	return result, nil
+}
+
+func (b *fabricBackend) Close() error {
+	return b.connection.Close()
+}
+
+// connect creates a gateway client signing with the identity over the shared connection
+func (b *fabricBackend) connect(id *Identity) (*client.Gateway, error) {
+
+	certificate, err := identity.CertificateFromPEM(id.Certificate)
+	if err != nil {
+		return nil, fmt.Errorf("invalid certificate of %v: %v", id.Label, err)
+	}
+
+	x509Identity, err := identity.NewX509Identity(id.MSPID, certificate)
+	if err != nil {
+		return nil, err
+	}
+
+	privateKey, err := identity.PrivateKeyFromPEM(id.PrivateKey)
+	if err != nil {
+		return nil, fmt.Errorf("invalid private key of %v: %v", id.Label, err)
+	}
+
+	sign, err := identity.NewPrivateKeySign(privateKey)
+	if err != nil {
+		return nil, err
+	}
+
+	gw, err := client.Connect(x509Identity, client.WithSign(sign), client.WithClientConnection(b.connection))
+	if err != nil {
+		return nil, fmt.Errorf("failed to connect to the gateway: %v", err)
+	}
+
+	return gw, nil
+}
+
+// transactionError returns the error message of the contract as a *contractError and a failed commit as a *conflictError
+// Other errors, e.g. an unavailable peer, are returned as they are
+func transactionError(err error) error {
+
+	var commitErr *client.CommitError
+	if errors.As(err, &commitErr) {
+		return &conflictError{message: fmt.Sprintf("transaction %v failed to commit with status %v", commitErr.TransactionID, commitErr.Code)}
+	}
+
+	for _, detail := range status.Convert(err).Details() {
+		errorDetail, ok := detail.(*gateway.ErrorDetail)
+		if !ok {
+			continue
+		}
+
+		match := chaincodeResponse.FindStringSubmatch(errorDetail.Message)
+		if match != nil {
+			return &contractError{message: match[1]}
+		}
+	}
+
+	return err
+}
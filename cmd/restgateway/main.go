@@ -0,0 +1,113 @@
+// Command restgateway serves the HealthClub and erc20 transactions of the MiniClub chaincode as REST endpoints
+//
+// Every transaction of the contract metadata is served at /api/<contract>/<transaction>: evaluate transactions
+// as GET with the parameters in the query, submit transactions as POST with a JSON object of the parameters.
+// The OpenAPI spec of the endpoints is served at /openapi.json and written to a file with -openapi; openapi.json
+// is the spec of the embedded metadata.json.
+//
+// Transaction requests are authenticated with an API key sent as Authorization: Bearer <key>. The -keys file is a
+// JSON object mapping the hex SHA-256 digest of every key to the wallet identity its requests are signed with, so
+// callers cannot choose the identity. The REST API is served on localhost unless -listen names another address.
+//
+// Built with -tags mock, the contracts run in-process on an in-memory world state instead of through a peer,
+// with generated identities. The mock is a separate build because the chaincode shim and the gateway client
+// register the same Fabric protobuf types.
+package main
+
+import (
+	"flag"
+	"log"
+	"net/http"
+	"os"
+)
+
+// gatewayConfig holds the connection settings of the Fabric Gateway peer
+type gatewayConfig struct {
+	PeerEndpoint string
+	HostOverride string
+	TLSCertPath  string
+	Channel      string
+	Chaincode    string
+}
+
+func main() {
+
+	config := gatewayConfig{}
+	flag.StringVar(&config.PeerEndpoint, "peer", "localhost:7051", "gateway peer endpoint")
+	flag.StringVar(&config.HostOverride, "hostoverride", "peer0.org1.example.com", "TLS host name of the gateway peer")
+	flag.StringVar(&config.TLSCertPath, "tlscert", "", "TLS CA certificate of the gateway peer")
+	flag.StringVar(&config.Channel, "channel", "mychannel", "channel of the contract")
+	flag.StringVar(&config.Chaincode, "chaincode", "miniclub", "chaincode name of the contract")
+
+	listen := flag.String("listen", "localhost:8080", "address to serve the REST API on")
+	keysPath := flag.String("keys", "", "JSON file of API key SHA-256 digests to wallet identities")
+	walletKind := flag.String("wallet", defaultWallet, "identity wallet: file, msp or mock")
+	walletPath := flag.String("walletpath", "wallet", "directory of the file or msp wallet")
+	mspID := flag.String("mspid", "Org1MSP", "MSP ID of the msp and mock wallet identities")
+	metadataIdentity := flag.String("identity", "appUser", "wallet identity fetching the contract metadata with -fetchmetadata")
+	metadataPath := flag.String("metadata", "", "contract metadata file, the embedded metadata.json if empty")
+	fetch := flag.Bool("fetchmetadata", false, "fetch the contract metadata from the chaincode instead of a file")
+	openAPIPath := flag.String("openapi", "", "write the OpenAPI spec of the metadata to this file and exit")
+	flag.Parse()
+
+	if *openAPIPath != "" {
+		metadata, err := loadMetadata(*metadataPath)
+		if err != nil {
+			log.Fatalf("error:%v", err)
+		}
+
+		spec, err := openAPISpec(metadata)
+		if err != nil {
+			log.Fatalf("error:%v", err)
+		}
+
+		err = os.WriteFile(*openAPIPath, append(spec, '\n'), 0644)
+		if err != nil {
+			log.Fatalf("failed to write %v: %v", *openAPIPath, err)
+		}
+		return
+	}
+
+	keys, err := loadAPIKeys(*keysPath)
+	if err != nil {
+		log.Fatalf("error:%v", err)
+	}
+
+	wallet, err := newWallet(*walletKind, *walletPath, *mspID)
+	if err != nil {
+		log.Fatalf("error:%v", err)
+	}
+
+	backend, err := newBackend(config)
+	if err != nil {
+		log.Fatalf("error:%v", err)
+	}
+	defer backend.Close()
+
+	var metadata *Metadata
+	if *fetch {
+		var id *Identity
+		id, err = wallet.Get(*metadataIdentity)
+		if err != nil {
+			log.Fatalf("identity %v: %v", *metadataIdentity, err)
+		}
+		metadata, err = fetchMetadata(backend, id)
+	} else {
+		metadata, err = loadMetadata(*metadataPath)
+	}
+	if err != nil {
+		log.Fatalf("error:%v", err)
+	}
+
+	handler, err := newServer(backend, wallet, keys, metadata)
+	if err != nil {
+		log.Fatalf("error:%v", err)
+	}
+
+	log.Printf("serving %v transactions on %v", len(handler.routes), *listen)
+
+	err = http.ListenAndServe(*listen, handler)
+	if err != nil {
+		log.Fatalf("error:%v", err)
+	}
+}
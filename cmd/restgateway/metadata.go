@@ -0,0 +1,127 @@
+package main
+
+import (
+	_ "embed"
+	"encoding/json"
+	"fmt"
+	"os"
+)
+
+// systemContract is the contract every contractapi chaincode serves its metadata from
+const systemContract = "org.hyperledger.fabric"
+
+// defaultMetadata is the metadata of the MiniClub chaincode as returned by org.hyperledger.fabric:GetMetadata,
+// with the param0, param1, ... names of the parameters replaced by their names in the contract source
+//
+//go:embed metadata.json
+var defaultMetadata []byte
+
+// Metadata is the part of the contractapi chaincode metadata the gateway routes and documents transactions with
+type Metadata struct {
+	Contracts  map[string]ContractMetadata `json:"contracts"`
+	Components struct {
+		Schemas map[string]json.RawMessage `json:"schemas"`
+	} `json:"components"`
+}
+
+// ContractMetadata describes the transactions of one contract of the chaincode
+type ContractMetadata struct {
+	Name         string                `json:"name"`
+	Transactions []TransactionMetadata `json:"transactions"`
+	Default      bool                  `json:"default"`
+}
+
+// TransactionMetadata describes one transaction, Returns is nil for transactions that only return an error
+type TransactionMetadata struct {
+	Name       string              `json:"name"`
+	Tag        []string            `json:"tag"`
+	Parameters []ParameterMetadata `json:"parameters"`
+	Returns    *Schema             `json:"returns"`
+}
+
+// ParameterMetadata describes one parameter of a transaction
+type ParameterMetadata struct {
+	Name   string `json:"name"`
+	Schema Schema `json:"schema"`
+}
+
+// Schema is a JSON schema, Type is read to convert arguments and results and the whole schema is passed on to the OpenAPI spec
+type Schema struct {
+	Type string          `json:"type"`
+	Raw  json.RawMessage `json:"-"`
+}
+
+func (s *Schema) UnmarshalJSON(data []byte) error {
+
+	type schemaFields Schema
+	fields := new(schemaFields)
+	err := json.Unmarshal(data, fields)
+	if err != nil {
+		return err
+	}
+
+	*s = Schema(*fields)
+	s.Raw = append(json.RawMessage{}, data...)
+
+	return nil
+}
+
+func (s Schema) MarshalJSON() ([]byte, error) {
+
+	if s.Raw == nil {
+		return []byte("{}"), nil
+	}
+
+	return s.Raw, nil
+}
+
+// IsEvaluate returns true if the transaction is tagged evaluate, i.e. it only reads the world state
+func (t TransactionMetadata) IsEvaluate() bool {
+
+	for _, tag := range t.Tag {
+		if tag == "evaluate" || tag == "EVALUATE" {
+			return true
+		}
+	}
+
+	return false
+}
+
+// loadMetadata reads the chaincode metadata from a file, or the embedded one if path is empty
+func loadMetadata(path string) (*Metadata, error) {
+
+	content := defaultMetadata
+	if path != "" {
+		var err error
+		content, err = os.ReadFile(path)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read metadata: %v", err)
+		}
+	}
+
+	return parseMetadata(content)
+}
+
+// fetchMetadata evaluates GetMetadata of the system contract, parameters are then named param0, param1, ...
+func fetchMetadata(backend Backend, id *Identity) (*Metadata, error) {
+
+	content, err := backend.Evaluate(id, systemContract, "GetMetadata", nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch metadata: %v", err)
+	}
+
+	return parseMetadata(content)
+}
+
+func parseMetadata(content []byte) (*Metadata, error) {
+
+	metadata := new(Metadata)
+	err := json.Unmarshal(content, metadata)
+	if err != nil {
+		return nil, fmt.Errorf("invalid metadata: %v", err)
+	}
+
+	delete(metadata.Contracts, systemContract)
+
+	return metadata, nil
+}
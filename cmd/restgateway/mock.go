@@ -0,0 +1,287 @@
+//go:build mock
+
+package main
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"log"
+	"sync"
+
+	"github.com/golang/protobuf/proto"
+	"github.com/hyperledger/fabric-chaincode-go/shim"
+	"github.com/hyperledger/fabric-chaincode-go/shimtest"
+	"github.com/hyperledger/fabric-contract-api-go/contractapi"
+	"github.com/hyperledger/fabric-protos-go/ledger/queryresult"
+	"github.com/hyperledger/fabric-protos-go/msp"
+	"github.com/hyperledger/fabric-protos-go/peer"
+	"github.com/varun425/MiniClubChaincode/erc20"
+	"github.com/varun425/MiniClubChaincode/events/envelope"
+	"github.com/varun425/MiniClubChaincode/healthclub"
+)
+
+// defaultWallet of the mock build generates the identities, the mock backend does not verify them
+const defaultWallet = "mock"
+
+// errRichQuery is returned for CouchDB queries, the mock world state is a key value store
+var errRichQuery = errors.New("rich queries are not supported by the mock backend")
+
+func newBackend(config gatewayConfig) (Backend, error) {
+
+	log.Printf("running the contracts in the mock backend, the world state is kept in memory")
+
+	return newMockBackend()
+}
+
+// mockBackend runs the contracts in-process on an in-memory world state, one transaction at a time
+// The writes of a transaction are committed once it succeeds, the writes of evaluated and failed transactions are discarded
+type mockBackend struct {
+	mu        sync.Mutex
+	chaincode *contractapi.ContractChaincode
+	stub      *mockStub
+}
+
+func newMockBackend() (*mockBackend, error) {
+
+	// the contracts are set up as in the chaincode main
+	healthClub := &healthclub.HealthClub{}
+	healthClub.TransactionContextHandler = new(envelope.TransactionContext)
+
+	chaincode, err := contractapi.NewChaincode(healthClub, &erc20.SmartContract{})
+	if err != nil {
+		return nil, fmt.Errorf("failed to create miniclub chaincode: %v", err)
+	}
+
+	return &mockBackend{
+		chaincode: chaincode,
+		stub:      &mockStub{MockStub: shimtest.NewMockStub("miniclub", chaincode)},
+	}, nil
+}
+
+func (b *mockBackend) Submit(id *Identity, contract string, transaction string, args []string) ([]byte, error) {
+	return b.invoke(id, contract, transaction, args, true)
+}
+
+func (b *mockBackend) Evaluate(id *Identity, contract string, transaction string, args []string) ([]byte, error) {
+	return b.invoke(id, contract, transaction, args, false)
+}
+
+func (b *mockBackend) Close() error {
+	return nil
+}
+
+func (b *mockBackend) invoke(id *Identity, contract string, transaction string, args []string, commit bool) ([]byte, error) {
+
+	creator, err := proto.Marshal(&msp.SerializedIdentity{Mspid: id.MSPID, IdBytes: id.Certificate})
+	if err != nil {
+		return nil, fmt.Errorf("failed to serialize identity %v: %v", id.Label, err)
+	}
+
+	txID := make([]byte, 32)
+	_, err = rand.Read(txID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to generate transaction id: %v", err)
+	}
+
+	invokeArgs := [][]byte{[]byte(contract + ":" + transaction)}
+	for _, arg := range args {
+		invokeArgs = append(invokeArgs, []byte(arg))
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.stub.args = invokeArgs
+	b.stub.creator = creator
+	b.stub.event = nil
+	b.stub.writes = nil
+
+	b.stub.MockTransactionStart(hex.EncodeToString(txID))
+	defer b.stub.MockTransactionEnd(hex.EncodeToString(txID))
+
+	response := b.chaincode.Invoke(b.stub)
+	if response.Status != shim.OK {
+		return nil, &contractError{message: response.Message}
+	}
+
+	if !commit {
+		return response.Payload, nil
+	}
+
+	err = b.stub.commit()
+	if err != nil {
+		return nil, fmt.Errorf("failed to commit %v:%v: %v", contract, transaction, err)
+	}
+
+	if b.stub.event != nil {
+		log.Printf("%v:%v emitted %v: %s", contract, transaction, b.stub.event.EventName, b.stub.event.Payload)
+	}
+
+	return response.Payload, nil
+}
+
+// mockStub completes the shimtest stub with the arguments and creator of the transaction,
+// range queries with pagination and the single chaincode event of a transaction
+// Writes are buffered until commit, reads see the committed world state as on the peer
+type mockStub struct {
+	*shimtest.MockStub
+	args    [][]byte
+	creator []byte
+	event   *peer.ChaincodeEvent
+	writes  []mockWrite
+}
+
+// mockWrite is a buffered write of a transaction, value is ignored when the key is deleted
+type mockWrite struct {
+	key     string
+	value   []byte
+	deleted bool
+}
+
+func (s *mockStub) PutState(key string, value []byte) error {
+
+	if key == "" {
+		return errors.New("key must not be an empty string")
+	}
+
+	s.writes = append(s.writes, mockWrite{key: key, value: value})
+
+	return nil
+}
+
+func (s *mockStub) DelState(key string) error {
+
+	s.writes = append(s.writes, mockWrite{key: key, deleted: true})
+
+	return nil
+}
+
+// commit applies the buffered writes of the transaction to the world state in order
+func (s *mockStub) commit() error {
+
+	for _, write := range s.writes {
+		var err error
+		if write.deleted {
+			err = s.MockStub.DelState(write.key)
+		} else {
+			err = s.MockStub.PutState(write.key, write.value)
+		}
+
+		if err != nil {
+			return err
+		}
+	}
+
+	s.writes = nil
+
+	return nil
+}
+
+func (s *mockStub) GetArgs() [][]byte {
+	return s.args
+}
+
+func (s *mockStub) GetStringArgs() []string {
+
+	args := []string{}
+	for _, arg := range s.args {
+		args = append(args, string(arg))
+	}
+
+	return args
+}
+
+func (s *mockStub) GetFunctionAndParameters() (string, []string) {
+
+	args := s.GetStringArgs()
+	if len(args) == 0 {
+		return "", []string{}
+	}
+
+	return args[0], args[1:]
+}
+
+func (s *mockStub) GetCreator() ([]byte, error) {
+	return s.creator, nil
+}
+
+// SetEvent keeps the last event of the transaction, as the peer does
+func (s *mockStub) SetEvent(name string, payload []byte) error {
+
+	if name == "" {
+		return errors.New("event name can not be empty string")
+	}
+
+	s.event = &peer.ChaincodeEvent{EventName: name, Payload: payload}
+
+	return nil
+}
+
+// GetStateByRangeWithPagination returns up to pageSize keys from the bookmark on, the bookmark of the next page is its first key
+func (s *mockStub) GetStateByRangeWithPagination(startKey, endKey string, pageSize int32, bookmark string) (shim.StateQueryIteratorInterface, *peer.QueryResponseMetadata, error) {
+
+	if bookmark != "" {
+		startKey = bookmark
+	}
+
+	iterator, err := s.MockStub.GetStateByRange(startKey, endKey)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer iterator.Close()
+
+	page := &pageIterator{}
+	metadata := &peer.QueryResponseMetadata{}
+	for iterator.HasNext() {
+		kv, err := iterator.Next()
+		if err != nil {
+			return nil, nil, err
+		}
+
+		if int32(len(page.results)) == pageSize {
+			metadata.Bookmark = kv.Key
+			break
+		}
+
+		page.results = append(page.results, kv)
+	}
+	metadata.FetchedRecordsCount = int32(len(page.results))
+
+	return page, metadata, nil
+}
+
+func (s *mockStub) GetQueryResult(query string) (shim.StateQueryIteratorInterface, error) {
+	return nil, errRichQuery
+}
+
+func (s *mockStub) GetQueryResultWithPagination(query string, pageSize int32, bookmark string) (shim.StateQueryIteratorInterface, *peer.QueryResponseMetadata, error) {
+	return nil, nil, errRichQuery
+}
+
+// pageIterator iterates over one page of a range query
+type pageIterator struct {
+	results []*queryresult.KV
+	next    int
+}
+
+func (p *pageIterator) HasNext() bool {
+	return p.next < len(p.results)
+}
+
+func (p *pageIterator) Next() (*queryresult.KV, error) {
+
+	if !p.HasNext() {
+		return nil, errors.New("no more results")
+	}
+
+	kv := p.results[p.next]
+	p.next++
+
+	return kv, nil
+}
+
+func (p *pageIterator) Close() error {
+	return nil
+}
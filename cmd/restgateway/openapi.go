@@ -0,0 +1,173 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sort"
+	"strconv"
+)
+
+// errorStatuses are the error responses documented for every transaction, see contractErrorStatus
+var errorStatuses = []int{
+	http.StatusBadRequest,
+	http.StatusUnauthorized,
+	http.StatusForbidden,
+	http.StatusNotFound,
+	http.StatusConflict,
+	http.StatusUnprocessableEntity,
+}
+
+// transactionPath is the REST path of a transaction
+func transactionPath(contract string, transaction string) string {
+	return "/api/" + contract + "/" + transaction
+}
+
+// openAPISpec generates the OpenAPI 3.0 document of the REST endpoints from the chaincode metadata
+// Evaluate transactions are GET with the parameters in the query, submit transactions are POST with a JSON object of the parameters
+func openAPISpec(metadata *Metadata) ([]byte, error) {
+
+	paths := map[string]interface{}{}
+
+	contracts := []string{}
+	for name := range metadata.Contracts {
+		contracts = append(contracts, name)
+	}
+	sort.Strings(contracts)
+
+	for _, contract := range contracts {
+		for _, transaction := range metadata.Contracts[contract].Transactions {
+			operation := map[string]interface{}{
+				"operationId": contract + "_" + transaction.Name,
+				"tags":        []string{contract},
+				"responses":   transactionResponses(transaction),
+			}
+
+			parameters := []interface{}{}
+
+			method := "post"
+			if transaction.IsEvaluate() {
+				method = "get"
+				for _, parameter := range transaction.Parameters {
+					queryParameter := map[string]interface{}{
+						"name":     parameter.Name,
+						"in":       "query",
+						"required": parameter.Schema.Type != "string",
+						"schema":   parameter.Schema,
+					}
+					if parameter.Schema.Type == "array" {
+						queryParameter["style"] = "form"
+						queryParameter["explode"] = true
+					}
+					parameters = append(parameters, queryParameter)
+				}
+			} else if len(transaction.Parameters) > 0 {
+				properties := map[string]interface{}{}
+				required := []string{}
+				for _, parameter := range transaction.Parameters {
+					properties[parameter.Name] = parameter.Schema
+					required = append(required, parameter.Name)
+				}
+
+				operation["requestBody"] = map[string]interface{}{
+					"required": true,
+					"content": map[string]interface{}{
+						"application/json": map[string]interface{}{
+							"schema": map[string]interface{}{
+								"type":       "object",
+								"properties": properties,
+								"required":   required,
+							},
+						},
+					},
+				}
+			}
+			if len(parameters) > 0 {
+				operation["parameters"] = parameters
+			}
+
+			paths[transactionPath(contract, transaction.Name)] = map[string]interface{}{method: operation}
+		}
+	}
+
+	schemas := map[string]interface{}{
+		"Error": map[string]interface{}{
+			"type":     "object",
+			"required": []string{"error"},
+			"properties": map[string]interface{}{
+				"error": map[string]string{"type": "string"},
+			},
+		},
+	}
+	for name, raw := range metadata.Components.Schemas {
+		schema := map[string]interface{}{}
+		err := json.Unmarshal(raw, &schema)
+		if err != nil {
+			return nil, fmt.Errorf("invalid schema %v: %v", name, err)
+		}
+
+		// $id is not part of the OpenAPI 3.0 schema object
+		delete(schema, "$id")
+		schemas[name] = schema
+	}
+
+	spec := map[string]interface{}{
+		"openapi": "3.0.3",
+		"info": map[string]string{
+			"title":       "MiniClub chaincode REST API",
+			"version":     "1.0.0",
+			"description": "Generated from the contract metadata. Contract errors are mapped to 400 (invalid input), 403 (not allowed to the caller), 404 (not found), 409 (conflicting state or balance) and 422 (other rejected business rules), internal contract failures to 500 and peer failures to 502, 503 and 504.",
+		},
+		"paths":    paths,
+		"security": []interface{}{map[string][]string{"apiKey": {}}},
+		"components": map[string]interface{}{
+			"schemas": schemas,
+			"securitySchemes": map[string]interface{}{
+				"apiKey": map[string]string{
+					"type":        "http",
+					"scheme":      "bearer",
+					"description": "API key, the transaction is signed with the wallet identity the gateway maps the key to",
+				},
+			},
+			"responses": map[string]interface{}{
+				"Error": map[string]interface{}{
+					"description": "the transaction failed",
+					"content": map[string]interface{}{
+						"application/json": map[string]interface{}{
+							"schema": map[string]string{"$ref": "#/components/schemas/Error"},
+						},
+					},
+				},
+			},
+		},
+	}
+
+	return json.MarshalIndent(spec, "", "  ")
+}
+
+// transactionResponses documents the result of a transaction and its error responses
+func transactionResponses(transaction TransactionMetadata) map[string]interface{} {
+
+	responses := map[string]interface{}{
+		"default": map[string]string{"$ref": "#/components/responses/Error"},
+	}
+	for _, status := range errorStatuses {
+		responses[strconv.Itoa(status)] = map[string]string{"$ref": "#/components/responses/Error"}
+	}
+
+	if transaction.Returns == nil {
+		responses["204"] = map[string]string{"description": "the transaction succeeded"}
+		return responses
+	}
+
+	responses["200"] = map[string]interface{}{
+		"description": "the result of the transaction",
+		"content": map[string]interface{}{
+			"application/json": map[string]interface{}{
+				"schema": transaction.Returns,
+			},
+		},
+	}
+
+	return responses
+}
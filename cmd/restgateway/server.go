@@ -0,0 +1,218 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"strings"
+)
+
+// maxBodySize is the largest request body accepted
+const maxBodySize = 1 << 20
+
+// route is the transaction served on one path
+type route struct {
+	contract    string
+	transaction TransactionMetadata
+}
+
+// server serves the transactions of the chaincode metadata as REST endpoints and the OpenAPI spec of them
+// Requests are authenticated by API key and signed with the wallet identity of the key
+type server struct {
+	backend Backend
+	wallet  Wallet
+	keys    apiKeys
+	routes  map[string]route
+	spec    []byte
+}
+
+func newServer(backend Backend, wallet Wallet, keys apiKeys, metadata *Metadata) (*server, error) {
+
+	spec, err := openAPISpec(metadata)
+	if err != nil {
+		return nil, err
+	}
+
+	routes := map[string]route{}
+	for contract, contractMetadata := range metadata.Contracts {
+		for _, transaction := range contractMetadata.Transactions {
+			routes[transactionPath(contract, transaction.Name)] = route{contract: contract, transaction: transaction}
+		}
+	}
+
+	return &server{
+		backend: backend,
+		wallet:  wallet,
+		keys:    keys,
+		routes:  routes,
+		spec:    spec,
+	}, nil
+}
+
+func (s *server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+
+	if r.URL.Path == "/openapi.json" {
+		w.Header().Set("Content-Type", "application/json")
+		w.Write(s.spec)
+		return
+	}
+
+	route, ok := s.routes[r.URL.Path]
+	if !ok {
+		writeError(w, http.StatusNotFound, fmt.Sprintf("no transaction at %v", r.URL.Path))
+		return
+	}
+
+	method := http.MethodPost
+	if route.transaction.IsEvaluate() {
+		method = http.MethodGet
+	}
+	if r.Method != method {
+		w.Header().Set("Allow", method)
+		writeError(w, http.StatusMethodNotAllowed, fmt.Sprintf("%v accepts %v only", route.transaction.Name, method))
+		return
+	}
+
+	label, err := s.keys.authenticate(r)
+	if err != nil {
+		w.Header().Set("WWW-Authenticate", "Bearer")
+		writeError(w, http.StatusUnauthorized, err.Error())
+		return
+	}
+
+	// the key was issued for an identity missing from the wallet
+	id, err := s.wallet.Get(label)
+	if err != nil {
+		log.Printf("identity %v of an API key: %v", label, err)
+		writeError(w, http.StatusInternalServerError, "identity of the API key is not available")
+		return
+	}
+
+	var args []string
+	if method == http.MethodGet {
+		args, err = queryArguments(r, route.transaction)
+	} else {
+		r.Body = http.MaxBytesReader(w, r.Body, maxBodySize)
+		args, err = bodyArguments(r, route.transaction)
+	}
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	var result []byte
+	if method == http.MethodGet {
+		result, err = s.backend.Evaluate(id, route.contract, route.transaction.Name, args)
+	} else {
+		result, err = s.backend.Submit(id, route.contract, route.transaction.Name, args)
+	}
+	if err != nil {
+		status := httpStatus(err)
+		if status >= http.StatusInternalServerError {
+			log.Printf("%v %v as %v failed: %v", r.Method, r.URL.Path, label, err)
+		}
+		writeError(w, status, err.Error())
+		return
+	}
+
+	writeResult(w, route.transaction, result)
+}
+
+// queryArguments reads the arguments of an evaluate transaction from the query
+// A missing string parameter is empty, an array parameter is a JSON array or the parameter repeated once per element
+func queryArguments(r *http.Request, transaction TransactionMetadata) ([]string, error) {
+
+	query := r.URL.Query()
+
+	args := []string{}
+	for _, parameter := range transaction.Parameters {
+		values, ok := query[parameter.Name]
+		if !ok && parameter.Schema.Type == "string" {
+			values = []string{""}
+		} else if !ok {
+			return nil, fmt.Errorf("missing query parameter %v", parameter.Name)
+		}
+
+		if parameter.Schema.Type == "array" && !(len(values) == 1 && strings.HasPrefix(values[0], "[")) {
+			array, _ := json.Marshal(values)
+			args = append(args, string(array))
+			continue
+		}
+
+		args = append(args, values[0])
+	}
+
+	return args, nil
+}
+
+// bodyArguments reads the arguments of a submit transaction from the JSON object of the body
+// Values given as JSON strings are passed as the string, the others as their JSON as the contract parses them
+func bodyArguments(r *http.Request, transaction TransactionMetadata) ([]string, error) {
+
+	if len(transaction.Parameters) == 0 {
+		return []string{}, nil
+	}
+
+	body := map[string]json.RawMessage{}
+	err := json.NewDecoder(r.Body).Decode(&body)
+	if err != nil {
+		return nil, fmt.Errorf("the body must be a JSON object of the parameters: %v", err)
+	}
+
+	args := []string{}
+	for _, parameter := range transaction.Parameters {
+		value, ok := body[parameter.Name]
+		if !ok {
+			return nil, fmt.Errorf("missing parameter %v", parameter.Name)
+		}
+
+		var arg string
+		err = json.Unmarshal(value, &arg)
+		if err == nil {
+			args = append(args, arg)
+			continue
+		}
+		if parameter.Schema.Type == "string" {
+			return nil, fmt.Errorf("parameter %v must be a string", parameter.Name)
+		}
+
+		compact := new(bytes.Buffer)
+		err = json.Compact(compact, value)
+		if err != nil {
+			return nil, fmt.Errorf("invalid parameter %v: %v", parameter.Name, err)
+		}
+		args = append(args, compact.String())
+	}
+
+	return args, nil
+}
+
+// writeResult writes the result of a transaction as JSON
+// The contract returns strings unquoted and the other types as JSON
+func writeResult(w http.ResponseWriter, transaction TransactionMetadata, result []byte) {
+
+	if transaction.Returns == nil {
+		w.WriteHeader(http.StatusNoContent)
+		return
+	}
+
+	if transaction.Returns.Type == "string" {
+		result, _ = json.Marshal(string(result))
+	} else if len(result) == 0 {
+		result = []byte("null")
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Write(result)
+}
+
+func writeError(w http.ResponseWriter, status int, message string) {
+
+	body, _ := json.Marshal(map[string]string{"error": message})
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	w.Write(body)
+}
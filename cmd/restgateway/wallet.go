@@ -0,0 +1,197 @@
+package main
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"math/big"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+// errUnknownIdentity is returned by a wallet that holds no identity under the requested label
+var errUnknownIdentity = errors.New("unknown identity")
+
+// Identity is an X.509 identity the transactions are signed with, certificate and private key are PEM encoded
+type Identity struct {
+	Label       string
+	MSPID       string
+	Certificate []byte
+	PrivateKey  []byte
+}
+
+// Wallet looks up the identity a request is made as
+type Wallet interface {
+	Get(label string) (*Identity, error)
+}
+
+// newWallet creates a wallet of the given kind
+// file reads <label>.id identity files in the format of the Fabric SDK wallets
+// msp reads <label>/msp directories as created by cryptogen or the Fabric CA client
+// mock generates a self-signed identity for every label, for the mock backend
+func newWallet(kind string, path string, mspID string) (Wallet, error) {
+
+	switch kind {
+	case "file":
+		return &fileWallet{path: path}, nil
+	case "msp":
+		return &mspWallet{path: path, mspID: mspID}, nil
+	case "mock":
+		return &mockWallet{mspID: mspID, identities: map[string]*Identity{}}, nil
+	}
+
+	return nil, fmt.Errorf("unknown wallet %v, expected file, msp or mock", kind)
+}
+
+// fileWallet reads the identities of a Fabric SDK file system wallet
+type fileWallet struct {
+	path string
+}
+
+// walletIdentity is the content of an identity file of a Fabric SDK wallet
+type walletIdentity struct {
+	Credentials struct {
+		Certificate string `json:"certificate"`
+		PrivateKey  string `json:"privateKey"`
+	} `json:"credentials"`
+	MSPID   string `json:"mspId"`
+	Type    string `json:"type"`
+	Version int    `json:"version"`
+}
+
+func (w *fileWallet) Get(label string) (*Identity, error) {
+
+	content, err := os.ReadFile(filepath.Join(w.path, filepath.Base(label)+".id"))
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, errUnknownIdentity
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read identity %v: %v", label, err)
+	}
+
+	stored := new(walletIdentity)
+	err = json.Unmarshal(content, stored)
+	if err != nil {
+		return nil, fmt.Errorf("invalid identity %v: %v", label, err)
+	}
+
+	if stored.Type != "X.509" {
+		return nil, fmt.Errorf("identity %v is of type %v, only X.509 is supported", label, stored.Type)
+	}
+
+	return &Identity{
+		Label:       label,
+		MSPID:       stored.MSPID,
+		Certificate: []byte(stored.Credentials.Certificate),
+		PrivateKey:  []byte(stored.Credentials.PrivateKey),
+	}, nil
+}
+
+// mspWallet reads the signing certificate and key of <label>/msp directories, all of the same MSP
+type mspWallet struct {
+	path  string
+	mspID string
+}
+
+func (w *mspWallet) Get(label string) (*Identity, error) {
+
+	mspDir := filepath.Join(w.path, filepath.Base(label), "msp")
+
+	_, err := os.Stat(mspDir)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, errUnknownIdentity
+	}
+
+	certificate, err := readFirstFile(filepath.Join(mspDir, "signcerts"))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read certificate of %v: %v", label, err)
+	}
+
+	privateKey, err := readFirstFile(filepath.Join(mspDir, "keystore"))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read private key of %v: %v", label, err)
+	}
+
+	return &Identity{Label: label, MSPID: w.mspID, Certificate: certificate, PrivateKey: privateKey}, nil
+}
+
+// readFirstFile reads the first file of a directory, MSP directories hold a single certificate or key
+func readFirstFile(dir string) ([]byte, error) {
+
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, entry := range entries {
+		if !entry.IsDir() {
+			return os.ReadFile(filepath.Join(dir, entry.Name()))
+		}
+	}
+
+	return nil, fmt.Errorf("no file in %v", dir)
+}
+
+// mockWallet creates a self-signed identity the first time a label is used and keeps it in memory
+type mockWallet struct {
+	mspID      string
+	mu         sync.Mutex
+	identities map[string]*Identity
+}
+
+func (w *mockWallet) Get(label string) (*Identity, error) {
+
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	id, ok := w.identities[label]
+	if ok {
+		return id, nil
+	}
+
+	privateKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		return nil, fmt.Errorf("failed to generate key of %v: %v", label, err)
+	}
+
+	serialNumber, err := rand.Int(rand.Reader, new(big.Int).Lsh(big.NewInt(1), 128))
+	if err != nil {
+		return nil, fmt.Errorf("failed to generate serial number of %v: %v", label, err)
+	}
+
+	template := &x509.Certificate{
+		SerialNumber: serialNumber,
+		Subject:      pkix.Name{CommonName: label, OrganizationalUnit: []string{"client"}},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().AddDate(1, 0, 0),
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+	}
+
+	certificate, err := x509.CreateCertificate(rand.Reader, template, template, &privateKey.PublicKey, privateKey)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create certificate of %v: %v", label, err)
+	}
+
+	keyBytes, err := x509.MarshalPKCS8PrivateKey(privateKey)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode key of %v: %v", label, err)
+	}
+
+	id = &Identity{
+		Label:       label,
+		MSPID:       w.mspID,
+		Certificate: pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: certificate}),
+		PrivateKey:  pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: keyBytes}),
+	}
+	w.identities[label] = id
+
+	return id, nil
+}
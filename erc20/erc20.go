@@ -1,13 +1,13 @@
 package erc20
 
 import (
-	"encoding/json"
 	"errors"
 	"fmt"
 	"log"
 	"strconv"
 
 	"github.com/hyperledger/fabric-contract-api-go/contractapi"
+	"github.com/varun425/MiniClubChaincode/events/envelope"
 )
 
 // Define key names for options
@@ -26,6 +26,12 @@ type SmartContract struct {
 	contractapi.Contract
 }
 
+// GetEvaluateTransactions returns the transactions that only read the world state
+// They are tagged evaluate in the contract metadata, the others submit
+func (s *SmartContract) GetEvaluateTransactions() []string {
+	return []string{"BalanceOf", "ClientAccountBalance", "ClientAccountID", "TotalSupply", "Allowance", "Name", "Symbol"}
+}
+
 // event provides an organized struct for emitting events
 type event struct {
 	From  string `json:"from"`
@@ -117,13 +123,9 @@ func (s *SmartContract) Mint(ctx contractapi.TransactionContextInterface, amount
 
 	// Emit the Transfer event
 	transferEvent := event{"0x0", minter, amount}
-	transferEventJSON, err := json.Marshal(transferEvent)
+	err = envelope.Emit(ctx, "Transfer", transferEvent)
 	if err != nil {
-		return fmt.Errorf("failed to obtain JSON encoding: %v", err)
-	}
-	err = ctx.GetStub().SetEvent("Transfer", transferEventJSON)
-	if err != nil {
-		return fmt.Errorf("failed to set event: %v", err)
+		return err
 	}
 
 	log.Printf("minter account %s balance updated from %d to %d", minter, currentBalance, updatedBalance)
@@ -212,13 +214,9 @@ func (s *SmartContract) Burn(ctx contractapi.TransactionContextInterface, amount
 
 	// Emit the Transfer event
 	transferEvent := event{minter, "0x0", amount}
-	transferEventJSON, err := json.Marshal(transferEvent)
+	err = envelope.Emit(ctx, "Transfer", transferEvent)
 	if err != nil {
-		return fmt.Errorf("failed to obtain JSON encoding: %v", err)
-	}
-	err = ctx.GetStub().SetEvent("Transfer", transferEventJSON)
-	if err != nil {
-		return fmt.Errorf("failed to set event: %v", err)
+		return err
 	}
 
 	log.Printf("minter account %s balance updated from %d to %d", minter, currentBalance, updatedBalance)
@@ -253,13 +251,9 @@ func (s *SmartContract) Transfer(ctx contractapi.TransactionContextInterface, re
 
 	// Emit the Transfer event
 	transferEvent := event{clientID, recipient, amount}
-	transferEventJSON, err := json.Marshal(transferEvent)
-	if err != nil {
-		return fmt.Errorf("failed to obtain JSON encoding: %v", err)
-	}
-	err = ctx.GetStub().SetEvent("Transfer", transferEventJSON)
+	err = envelope.Emit(ctx, "Transfer", transferEvent)
 	if err != nil {
-		return fmt.Errorf("failed to set event: %v", err)
+		return err
 	}
 
 	return nil
@@ -410,13 +404,9 @@ func (s *SmartContract) Approve(ctx contractapi.TransactionContextInterface, spe
 
 	// Emit the Approval event
 	approvalEvent := event{owner, spender, value}
-	approvalEventJSON, err := json.Marshal(approvalEvent)
-	if err != nil {
-		return fmt.Errorf("failed to obtain JSON encoding: %v", err)
-	}
-	err = ctx.GetStub().SetEvent("Approval", approvalEventJSON)
+	err = envelope.Emit(ctx, "Approval", approvalEvent)
 	if err != nil {
-		return fmt.Errorf("failed to set event: %v", err)
+		return err
 	}
 
 	log.Printf("client %s approved a withdrawal allowance of %d for spender %s", owner, value, spender)
@@ -520,13 +510,9 @@ func (s *SmartContract) TransferFrom(ctx contractapi.TransactionContextInterface
 
 	// Emit the Transfer event
 	transferEvent := event{from, to, value}
-	transferEventJSON, err := json.Marshal(transferEvent)
+	err = envelope.Emit(ctx, "Transfer", transferEvent)
 	if err != nil {
-		return fmt.Errorf("failed to obtain JSON encoding: %v", err)
-	}
-	err = ctx.GetStub().SetEvent("Transfer", transferEventJSON)
-	if err != nil {
-		return fmt.Errorf("failed to set event: %v", err)
+		return err
 	}
 
 	log.Printf("spender %s allowance updated from %d to %d", spender, currentAllowance, updatedAllowance)
@@ -688,6 +674,245 @@ func transferHelper(ctx contractapi.TransactionContextInterface, from string, to
 	return nil
 }
 
+// TransferBatch collects the balance and allowance changes of several transfers made within one transaction
+// and writes every touched account once on Commit.
+// Fabric does not return the pending writes of a transaction from GetState, so calling transferHelper
+// twice for the same account in one transaction would silently drop the first update.
+type TransferBatch struct {
+	ctx           contractapi.TransactionContextInterface
+	parent        *TransferBatch
+	balances      map[string]int
+	balanceKeys   []string
+	allowances    map[string]int
+	allowanceKeys []string
+	transfers     []event
+}
+
+// NewTransferBatch returns an empty batch for the given transaction
+func NewTransferBatch(ctx contractapi.TransactionContextInterface) *TransferBatch {
+	return &TransferBatch{
+		ctx:        ctx,
+		balances:   map[string]int{},
+		allowances: map[string]int{},
+	}
+}
+
+// Fork returns an empty batch on top of this one, which sees the balances and allowances of this batch
+// Merge adds its transfers to this batch, so a step of several transfers that fails halfway can be dropped
+func (b *TransferBatch) Fork() *TransferBatch {
+	return &TransferBatch{
+		ctx:        b.ctx,
+		parent:     b,
+		balances:   map[string]int{},
+		allowances: map[string]int{},
+	}
+}
+
+// Merge adds the transfers of a batch returned by Fork to this batch
+func (b *TransferBatch) Merge(fork *TransferBatch) error {
+
+	if fork.parent != b {
+		return fmt.Errorf("batch is not a fork of this batch")
+	}
+
+	for _, account := range fork.balanceKeys {
+		b.setBalance(account, fork.balances[account])
+	}
+
+	for _, allowanceKey := range fork.allowanceKeys {
+		b.setAllowance(allowanceKey, fork.allowances[allowanceKey])
+	}
+
+	b.transfers = append(b.transfers, fork.transfers...)
+
+	return nil
+}
+
+// Transfer moves value tokens from the "from" account to the "to" account
+// The batch is left unchanged if the transfer fails, so callers may continue with other transfers
+func (b *TransferBatch) Transfer(from string, to string, value int) error {
+
+	if from == to {
+		return fmt.Errorf("cannot transfer to and from same client account")
+	}
+
+	if value < 0 {
+		return fmt.Errorf("transfer amount cannot be negative")
+	}
+
+	fromCurrentBalance, exists, err := b.balance(from)
+	if err != nil {
+		return err
+	}
+
+	if !exists {
+		return fmt.Errorf("client account %s has no balance", from)
+	}
+
+	if fromCurrentBalance < value {
+		return fmt.Errorf("client account %s has insufficient funds", from)
+	}
+
+	toCurrentBalance, _, err := b.balance(to)
+	if err != nil {
+		return err
+	}
+
+	fromUpdatedBalance, err := sub(fromCurrentBalance, value)
+	if err != nil {
+		return err
+	}
+
+	toUpdatedBalance, err := add(toCurrentBalance, value)
+	if err != nil {
+		return err
+	}
+
+	b.setBalance(from, fromUpdatedBalance)
+	b.setBalance(to, toUpdatedBalance)
+	b.transfers = append(b.transfers, event{from, to, value})
+
+	log.Printf("client %s balance updated from %d to %d", from, fromCurrentBalance, fromUpdatedBalance)
+	log.Printf("recipient %s balance updated from %d to %d", to, toCurrentBalance, toUpdatedBalance)
+
+	return nil
+}
+
+// TransferFrom moves value tokens from the "from" account to the "to" account using the allowance
+// the "from" account granted to spender through Approve
+func (b *TransferBatch) TransferFrom(from string, spender string, to string, value int) error {
+
+	allowanceKey, err := b.ctx.GetStub().CreateCompositeKey(allowancePrefix, []string{from, spender})
+	if err != nil {
+		return fmt.Errorf("failed to create the composite key for prefix %s: %v", allowancePrefix, err)
+	}
+
+	currentAllowance, err := b.allowance(allowanceKey)
+	if err != nil {
+		return err
+	}
+
+	if currentAllowance < value {
+		return fmt.Errorf("spender does not have enough allowance for transfer")
+	}
+
+	err = b.Transfer(from, to, value)
+	if err != nil {
+		return err
+	}
+
+	updatedAllowance, err := sub(currentAllowance, value)
+	if err != nil {
+		return err
+	}
+
+	b.setAllowance(allowanceKey, updatedAllowance)
+
+	log.Printf("spender %s allowance updated from %d to %d", spender, currentAllowance, updatedAllowance)
+
+	return nil
+}
+
+// Commit writes the updated balances and allowances to the world state
+// This function triggers a Transfer event for every transfer of the batch
+// A batch returned by Fork is merged into its parent instead, see Merge
+func (b *TransferBatch) Commit() error {
+
+	if b.parent != nil {
+		return fmt.Errorf("a forked batch is merged, not committed")
+	}
+
+	for _, account := range b.balanceKeys {
+		err := b.ctx.GetStub().PutState(account, []byte(strconv.Itoa(b.balances[account])))
+		if err != nil {
+			return fmt.Errorf("failed to update balance of %s: %v", account, err)
+		}
+	}
+
+	for _, allowanceKey := range b.allowanceKeys {
+		err := b.ctx.GetStub().PutState(allowanceKey, []byte(strconv.Itoa(b.allowances[allowanceKey])))
+		if err != nil {
+			return fmt.Errorf("failed to update state of smart contract for key %s: %v", allowanceKey, err)
+		}
+	}
+
+	// Emit the Transfer events in the order the transfers were made
+	for _, transferEvent := range b.transfers {
+		err := envelope.Emit(b.ctx, "Transfer", transferEvent)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// balance returns the balance of the account as seen by the batch and whether the account exists
+func (b *TransferBatch) balance(account string) (int, bool, error) {
+
+	if balance, ok := b.balances[account]; ok {
+		return balance, true, nil
+	}
+
+	if b.parent != nil {
+		return b.parent.balance(account)
+	}
+
+	balanceBytes, err := b.ctx.GetStub().GetState(account)
+	if err != nil {
+		return 0, false, fmt.Errorf("failed to read account %s from world state: %v", account, err)
+	}
+
+	if balanceBytes == nil {
+		return 0, false, nil
+	}
+
+	balance, _ := strconv.Atoi(string(balanceBytes)) // Error handling not needed since Itoa() was used when setting the account balance, guaranteeing it was an integer.
+
+	b.setBalance(account, balance)
+
+	return balance, true, nil
+}
+
+// setBalance records the balance of the account to be written on Commit
+func (b *TransferBatch) setBalance(account string, balance int) {
+
+	if _, ok := b.balances[account]; !ok {
+		b.balanceKeys = append(b.balanceKeys, account)
+	}
+	b.balances[account] = balance
+}
+
+// allowance returns the allowance stored under the allowance key as seen by the batch
+func (b *TransferBatch) allowance(allowanceKey string) (int, error) {
+
+	if allowance, ok := b.allowances[allowanceKey]; ok {
+		return allowance, nil
+	}
+
+	if b.parent != nil {
+		return b.parent.allowance(allowanceKey)
+	}
+
+	allowanceBytes, err := b.ctx.GetStub().GetState(allowanceKey)
+	if err != nil {
+		return 0, fmt.Errorf("failed to retrieve the allowance for %s from world state: %v", allowanceKey, err)
+	}
+
+	allowance, _ := strconv.Atoi(string(allowanceBytes)) // Error handling not needed since Itoa() was used when setting the allowance, guaranteeing it was an integer.
+
+	return allowance, nil
+}
+
+// setAllowance records the allowance to be written on Commit
+func (b *TransferBatch) setAllowance(allowanceKey string, allowance int) {
+
+	if _, ok := b.allowances[allowanceKey]; !ok {
+		b.allowanceKeys = append(b.allowanceKeys, allowanceKey)
+	}
+	b.allowances[allowanceKey] = allowance
+}
+
 // add two number checking for overflow
 func add(b int, q int) (int, error) {
 
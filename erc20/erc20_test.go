@@ -0,0 +1,159 @@
+package erc20
+
+import (
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/hyperledger/fabric-contract-api-go/contractapi"
+	"github.com/varun425/MiniClubChaincode/internal/chaincodetest"
+)
+
+// newTestWorld returns a world state with the balances set
+func newTestWorld(balances map[string]int) *chaincodetest.World {
+
+	world := chaincodetest.NewWorld(time.Date(2026, time.March, 2, 10, 0, 0, 0, time.UTC))
+	for account, balance := range balances {
+		world.Put(account, []byte(strconv.Itoa(balance)))
+	}
+
+	return world
+}
+
+func TestTransferBatchForkMergedOnSuccessOnly(t *testing.T) {
+
+	world := newTestWorld(map[string]int{"alice": 100, "bob": 50, "club": 0})
+	caller := chaincodetest.NewIdentity("club", nil)
+
+	ctx, err := world.Invoke(caller, func(ctx contractapi.TransactionContextInterface) error {
+
+		batch := NewTransferBatch(ctx)
+
+		// alice pays in full, the fork sees the balance of the batch
+		fork := batch.Fork()
+		err := fork.Transfer("alice", "club", 60)
+		if err != nil {
+			return err
+		}
+		err = fork.Transfer("club", "bob", 60)
+		if err != nil {
+			return err
+		}
+		err = batch.Merge(fork)
+		if err != nil {
+			return err
+		}
+
+		// bob's second transfer fails, the first one is dropped with the fork
+		fork = batch.Fork()
+		err = fork.Transfer("bob", "club", 100)
+		if err != nil {
+			return err
+		}
+		if fork.Transfer("bob", "alice", 100) == nil {
+			t.Errorf("transfer beyond the balance of the fork succeeded")
+		}
+
+		return batch.Commit()
+	})
+	if err != nil {
+		t.Fatalf("transaction failed: %v", err)
+	}
+
+	for account, balance := range map[string]int{"alice": 40, "bob": 110, "club": 0} {
+		if got := world.Balance(account); got != balance {
+			t.Errorf("balance of %v = %v, want %v", account, got, balance)
+		}
+	}
+
+	// one Transfer event per merged transfer, in order
+	expected := []string{
+		`{"from":"alice","to":"club","value":60}`,
+		`{"from":"club","to":"bob","value":60}`,
+	}
+	emitted := ctx.Envelope().Events
+	if len(emitted) != len(expected) {
+		t.Fatalf("%v events emitted, want %v", len(emitted), len(expected))
+	}
+	for i, transferEvent := range emitted {
+		if transferEvent.Name != "Transfer" || string(transferEvent.Payload) != expected[i] {
+			t.Errorf("event %v is %v %s, want Transfer %v", i, transferEvent.Name, transferEvent.Payload, expected[i])
+		}
+	}
+}
+
+func TestTransferBatchForkCannotCommit(t *testing.T) {
+
+	world := newTestWorld(map[string]int{"alice": 100})
+	caller := chaincodetest.NewIdentity("club", nil)
+
+	_, err := world.Invoke(caller, func(ctx contractapi.TransactionContextInterface) error {
+
+		batch := NewTransferBatch(ctx)
+		if batch.Fork().Commit() == nil {
+			t.Errorf("a fork was committed")
+		}
+		if batch.Merge(NewTransferBatch(ctx)) == nil {
+			t.Errorf("a batch that is not a fork was merged")
+		}
+
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("transaction failed: %v", err)
+	}
+}
+
+func TestTransferBatchForkUsesAllowance(t *testing.T) {
+
+	world := newTestWorld(map[string]int{"alice": 100})
+	alice := chaincodetest.NewIdentity("alice", nil)
+	club := chaincodetest.NewIdentity("club", nil)
+	contract := new(SmartContract)
+
+	world.Put(nameKey, []byte("MiniFitnessHealthClub"))
+	_, err := world.Invoke(alice, func(ctx contractapi.TransactionContextInterface) error {
+		return contract.Approve(ctx, "club", 70)
+	})
+	if err != nil {
+		t.Fatalf("approve failed: %v", err)
+	}
+
+	_, err = world.Invoke(club, func(ctx contractapi.TransactionContextInterface) error {
+
+		batch := NewTransferBatch(ctx)
+
+		fork := batch.Fork()
+		err := fork.TransferFrom("alice", "club", "club", 50)
+		if err != nil {
+			return err
+		}
+		err = batch.Merge(fork)
+		if err != nil {
+			return err
+		}
+
+		// 20 of the allowance is left to the next fork
+		if batch.Fork().TransferFrom("alice", "club", "club", 30) == nil {
+			t.Errorf("transfer beyond the remaining allowance succeeded")
+		}
+
+		return batch.Commit()
+	})
+	if err != nil {
+		t.Fatalf("transaction failed: %v", err)
+	}
+
+	if world.Balance("alice") != 50 || world.Balance("club") != 50 {
+		t.Errorf("balances alice %v club %v, want 50 and 50", world.Balance("alice"), world.Balance("club"))
+	}
+
+	allowance := 0
+	_, err = world.Invoke(club, func(ctx contractapi.TransactionContextInterface) error {
+		allowance, err = contract.Allowance(ctx, "alice", "club")
+		return err
+	})
+	if err != nil || allowance != 20 {
+		t.Errorf("allowance = %v (%v), want 20", allowance, err)
+	}
+}
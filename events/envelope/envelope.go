@@ -0,0 +1,75 @@
+// Package envelope collects the events of a transaction into one versioned events.Envelope.
+// Fabric keeps a single chaincode event per transaction, the last one set, so every event emitted through
+// Emit within a transaction of a contract using TransactionContext is added to the envelope and the whole
+// envelope is set again as the events.EnvelopeName event. Contracts using the default context keep emitting each
+// event under its own name.
+package envelope
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"github.com/hyperledger/fabric-contract-api-go/contractapi"
+	"github.com/varun425/MiniClubChaincode/events"
+)
+
+// TransactionContext is a transaction context that collects the events of the transaction into an envelope
+// Set it as the TransactionContextHandler of a contract, a new one is created for every transaction
+type TransactionContext struct {
+	contractapi.TransactionContext
+	envelope *events.Envelope
+}
+
+// Envelope returns the envelope of the transaction
+func (c *TransactionContext) Envelope() *events.Envelope {
+
+	if c.envelope == nil {
+		c.envelope = &events.Envelope{
+			Version: events.SchemaVersion,
+			TxID:    c.GetStub().GetTxID(),
+			Events:  []events.Event{},
+		}
+	}
+
+	return c.envelope
+}
+
+// envelopeContext is implemented by transaction contexts that collect events
+type envelopeContext interface {
+	Envelope() *events.Envelope
+}
+
+// Emit adds the event to the envelope of the transaction and sets the envelope as the chaincode event
+// Without TransactionContext the event is set under its own name
+func Emit(ctx contractapi.TransactionContextInterface, name string, payload interface{}) error {
+
+	payloadJSON, err := json.Marshal(payload)
+	if err != nil {
+		return fmt.Errorf("failed to obtain JSON encoding: %v", err)
+	}
+
+	envelopeCtx, ok := ctx.(envelopeContext)
+	if !ok {
+		err = ctx.GetStub().SetEvent(name, payloadJSON)
+		if err != nil {
+			return fmt.Errorf("failed to set event: %v", err)
+		}
+
+		return nil
+	}
+
+	envelope := envelopeCtx.Envelope()
+	envelope.Events = append(envelope.Events, events.Event{
+		Name:    name,
+		Version: events.SchemaVersion,
+		Payload: payloadJSON,
+	})
+
+	envelopeJSON, _ := json.Marshal(envelope)
+	err = ctx.GetStub().SetEvent(events.EnvelopeName, envelopeJSON)
+	if err != nil {
+		return fmt.Errorf("failed to set event: %v", err)
+	}
+
+	return nil
+}
@@ -0,0 +1,106 @@
+// Package events declares the events of the contracts, their payloads and the versioned envelope carrying them.
+// It has no chaincode dependencies so that off-chain consumers can decode the envelope, the envelope is
+// collected within a transaction by the envelope package.
+package events
+
+import (
+	"encoding/json"
+)
+
+const (
+	// EnvelopeName is the name of the chaincode event carrying the envelope
+	EnvelopeName = "HealthClubEvents"
+
+	// SchemaVersion is the version of the envelope and event payloads described in schema.json
+	SchemaVersion = 1
+)
+
+// Names of the membership lifecycle events
+const (
+	UserRegistered      = "UserRegistered"
+	MembershipCreated   = "MembershipCreated"
+	MembershipUpgraded  = "MembershipUpgraded"
+	MembershipCancelled = "MembershipCancelled"
+	MembershipExpired   = "MembershipExpired"
+)
+
+// Names of the events of the other HealthClub transactions
+// Their payloads are the HealthClub records the transactions return, described in schema.json
+const (
+	MembershipDowngraded       = "MembershipDowngraded"
+	MembershipRenewals         = "MembershipRenewals"
+	ReferralBonus              = "ReferralBonus"
+	CorporateMembershipCharged = "CorporateMembershipCharged"
+	GiftCardRedeemed           = "GiftCardRedeemed"
+	RewardsClaimed             = "RewardsClaimed"
+	ClassWaitlistPromoted      = "ClassWaitlistPromoted"
+	ClassSettled               = "ClassSettled"
+	SessionCompleted           = "SessionCompleted"
+	SessionDisputed            = "SessionDisputed"
+	SessionRefunded            = "SessionRefunded"
+)
+
+// Envelope carries every event emitted during the transaction TxID, in emission order
+type Envelope struct {
+	Version int     `json:"version"`
+	TxID    string  `json:"txid"`
+	Events  []Event `json:"events"`
+}
+
+// Event is one event of an envelope, Payload is the JSON encoding of the event named Name
+type Event struct {
+	Name    string          `json:"name"`
+	Version int             `json:"version"`
+	Payload json.RawMessage `json:"payload"`
+}
+
+// UserRegisteredEvent is the payload of UserRegistered
+type UserRegisteredEvent struct {
+	UserID     string `json:"userid"`
+	Name       string `json:"name"`
+	Email      string `json:"email"`
+	ReferredBy string `json:"referredby"`
+	Date       string `json:"date"`
+}
+
+// MembershipCreatedEvent is the payload of MembershipCreated, Price is the tokens paid after discounts
+// RenewalOf is the membership renewed, empty for a purchase
+type MembershipCreatedEvent struct {
+	MembershipID string `json:"membershipid"`
+	UserID       string `json:"userid"`
+	Level        string `json:"level"`
+	BranchID     string `json:"branchid"`
+	Price        int    `json:"price"`
+	StartDate    string `json:"startdate"`
+	EndDate      string `json:"enddate"`
+	RenewalOf    string `json:"renewalof"`
+}
+
+// MembershipUpgradedEvent is the payload of MembershipUpgraded
+type MembershipUpgradedEvent struct {
+	MembershipID string `json:"membershipid"`
+	UserID       string `json:"userid"`
+	FromLevel    string `json:"fromlevel"`
+	ToLevel      string `json:"tolevel"`
+	Credit       int    `json:"credit"`
+	AmountPaid   int    `json:"amountpaid"`
+	EndDate      string `json:"enddate"`
+}
+
+// MembershipCancelledEvent is the payload of MembershipCancelled, Refund is the tokens returned to the member
+type MembershipCancelledEvent struct {
+	MembershipID string `json:"membershipid"`
+	UserID       string `json:"userid"`
+	Level        string `json:"level"`
+	Refund       int    `json:"refund"`
+	Reason       string `json:"reason"`
+	Date         string `json:"date"`
+}
+
+// MembershipExpiredEvent is the payload of MembershipExpired
+type MembershipExpiredEvent struct {
+	MembershipID string `json:"membershipid"`
+	UserID       string `json:"userid"`
+	Level        string `json:"level"`
+	EndDate      string `json:"enddate"`
+}
@@ -0,0 +1,364 @@
+package healthclub
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+
+	"github.com/hyperledger/fabric-contract-api-go/contractapi"
+)
+
+const (
+	attendanceIndex = "attendance~UserID~Date~TxID"
+	occupancyIndex  = "occupancy~FacilityID~Date~UserID~TxID"
+	openVisitIndex  = "openvisit~UserID"
+)
+
+// Attendance is one visit of a user to a facility
+type Attendance struct {
+	UserID       string `json:"userid"`
+	FacilityID   string `json:"facilityid"`
+	Date         string `json:"date"`
+	CheckInTime  string `json:"checkintime"`
+	CheckOutTime string `json:"checkouttime"`
+	MembershipID string `json:"membershipid"`
+	Level        string `json:"level"`
+	CheckedInBy  string `json:"checkedinby"`
+	PassID       string `json:"passid"`
+	HostedBy     string `json:"hostedby"`
+}
+
+// Occupancy summarises the visits to a facility on one day
+type Occupancy struct {
+	FacilityID    string `json:"facilityid"`
+	Date          string `json:"date"`
+	TotalVisits   int    `json:"totalvisits"`
+	UniqueMembers int    `json:"uniquemembers"`
+	CheckedIn     int    `json:"checkedin"`
+}
+
+// CheckIn records the caller entering the given facility
+// The caller must hold an active membership that is not frozen, otherwise a valid guest or day pass of the caller is used
+func (h *HealthClub) CheckIn(ctx contractapi.TransactionContextInterface, facilityId string) (*Attendance, error) {
+
+	userid, err := ctx.GetClientIdentity().GetID()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get userID: %v", err)
+	}
+
+	return checkInUser(ctx, userPrefix+userid, facilityId, userPrefix+userid)
+}
+
+// StaffCheckIn records a member entering the given facility on behalf of the front desk, e.g. after scanning a member card
+func (h *HealthClub) StaffCheckIn(ctx contractapi.TransactionContextInterface, userId string, facilityId string) (*Attendance, error) {
+
+	err := checkStaff(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	staffid, err := ctx.GetClientIdentity().GetID()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get userID: %v", err)
+	}
+
+	return checkInUser(ctx, toUserKey(userId), facilityId, staffid)
+}
+
+// CheckOut records the caller leaving the facility of the open visit
+func (h *HealthClub) CheckOut(ctx contractapi.TransactionContextInterface) (*Attendance, error) {
+
+	userid, err := ctx.GetClientIdentity().GetID()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get userID: %v", err)
+	}
+
+	return checkOutUser(ctx, userPrefix+userid)
+}
+
+// StaffCheckOut records a member leaving the facility of the open visit on behalf of the front desk
+func (h *HealthClub) StaffCheckOut(ctx contractapi.TransactionContextInterface, userId string) (*Attendance, error) {
+
+	err := checkStaff(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	return checkOutUser(ctx, toUserKey(userId))
+}
+
+// GetAttendance returns the visits of a user between the from and to dates (inclusive, MM-DD-YYYY)
+// Members can only read their own visits, staff can read the visits of any member
+func (h *HealthClub) GetAttendance(ctx contractapi.TransactionContextInterface, userId string, from string, to string) ([]Attendance, error) {
+
+	userid, err := ctx.GetClientIdentity().GetID()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get userID: %v", err)
+	}
+
+	if toUserKey(userId) != userPrefix+userid {
+		err = checkStaff(ctx)
+		if err != nil {
+			return nil, fmt.Errorf("only the member or staff can read the attendance of %v: %v", userId, err)
+		}
+	}
+
+	fromDate, err := time.Parse(dateFormat, from)
+	if err != nil {
+		return nil, fmt.Errorf("invalid from date %v, expected MM-DD-YYYY", from)
+	}
+
+	toDate, err := time.Parse(dateFormat, to)
+	if err != nil {
+		return nil, fmt.Errorf("invalid to date %v, expected MM-DD-YYYY", to)
+	}
+
+	return getAttendance(ctx, toUserKey(userId), fromDate, toDate)
+}
+
+// GetDailyOccupancy returns the number of visits and members checked in at a facility on the given date (MM-DD-YYYY)
+func (h *HealthClub) GetDailyOccupancy(ctx contractapi.TransactionContextInterface, facilityId string, date string) (*Occupancy, error) {
+
+	day, err := time.Parse(dateFormat, date)
+	if err != nil {
+		return nil, fmt.Errorf("invalid date %v, expected MM-DD-YYYY", date)
+	}
+
+	occupancyIterator, err := ctx.GetStub().GetStateByPartialCompositeKey(occupancyIndex, []string{facilityId, day.Format(keyDateFormat)})
+	if err != nil {
+		return nil, fmt.Errorf("error:%v", err.Error())
+	}
+
+	defer occupancyIterator.Close()
+
+	occupancy := &Occupancy{
+		FacilityID: facilityId,
+		Date:       date,
+	}
+
+	members := map[string]bool{}
+	for occupancyIterator.HasNext() {
+		responseRange, err := occupancyIterator.Next()
+		if err != nil {
+			return nil, fmt.Errorf("error:%v", err.Error())
+		}
+
+		attendancebytes, err := ctx.GetStub().GetState(string(responseRange.Value))
+		if err != nil {
+			return nil, fmt.Errorf("error:%v", err.Error())
+		}
+
+		attendance := new(Attendance)
+		_ = json.Unmarshal(attendancebytes, &attendance)
+
+		occupancy.TotalVisits++
+		members[attendance.UserID] = true
+		if attendance.CheckOutTime == "" {
+			occupancy.CheckedIn++
+		}
+	}
+
+	occupancy.UniqueMembers = len(members)
+
+	return occupancy, nil
+}
+
+// checkInUser verifies the membership of the user and writes an attendance record for the visit
+func checkInUser(ctx contractapi.TransactionContextInterface, userId string, facilityId string, checkedInBy string) (*Attendance, error) {
+
+	if facilityId == "" {
+		return nil, fmt.Errorf("facility id is required")
+	}
+
+	openVisitKey, err := ctx.GetStub().CreateCompositeKey(openVisitIndex, []string{userId})
+	if err != nil {
+		return nil, fmt.Errorf("failed to create the composite key for prefix %s: %v", openVisitIndex, err)
+	}
+
+	openVisit, err := ctx.GetStub().GetState(openVisitKey)
+	if err != nil {
+		return nil, fmt.Errorf("error:%v", err)
+	}
+
+	if openVisit != nil {
+		return nil, fmt.Errorf("%v is already checked in, check out first", userId)
+	}
+
+	now, err := getTxTime(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("error:%v", err)
+	}
+
+	attendance := &Attendance{
+		UserID:      userId,
+		FacilityID:  facilityId,
+		Date:        now.Format(dateFormat),
+		CheckInTime: now.Format(timeFormat),
+		CheckedInBy: checkedInBy,
+	}
+
+	currentmembershipId, membershipdetails, err := getAccessMembership(ctx, userId)
+	if err == nil {
+		err = checkMembershipAccess(membershipdetails, now)
+	}
+
+	if err == nil {
+		err = checkBranchAccess(ctx, membershipdetails, facilityId)
+	}
+
+	if err == nil {
+		attendance.MembershipID = currentmembershipId
+		attendance.Level = membershipdetails.Level
+	} else {
+		// visitors without membership access enter with a guest or day pass
+		pass, passErr := usePass(ctx, userId, facilityId, now)
+		if passErr != nil {
+			return nil, fmt.Errorf("access denied: %v", err)
+		}
+
+		attendance.PassID = pass.PassID
+		attendance.HostedBy = pass.HostID
+	}
+
+	err = putAttendance(ctx, attendance, now, openVisitKey)
+	if err != nil {
+		return nil, err
+	}
+
+	log.Printf("%v checked in at %v", userId, facilityId)
+
+	return attendance, nil
+}
+
+// putAttendance writes a new attendance record, its occupancy index entry and the open visit of the user
+func putAttendance(ctx contractapi.TransactionContextInterface, attendance *Attendance, now time.Time, openVisitKey string) error {
+
+	txID := ctx.GetStub().GetTxID()
+	day := now.Format(keyDateFormat)
+
+	attendanceKey, err := ctx.GetStub().CreateCompositeKey(attendanceIndex, []string{attendance.UserID, day, txID})
+	if err != nil {
+		return fmt.Errorf("failed to create the composite key for prefix %s: %v", attendanceIndex, err)
+	}
+
+	occupancyKey, err := ctx.GetStub().CreateCompositeKey(occupancyIndex, []string{attendance.FacilityID, day, attendance.UserID, txID})
+	if err != nil {
+		return fmt.Errorf("failed to create the composite key for prefix %s: %v", occupancyIndex, err)
+	}
+
+	attendancebytes, _ := json.Marshal(attendance)
+	err = ctx.GetStub().PutState(attendanceKey, attendancebytes)
+	if err != nil {
+		return fmt.Errorf("error:%v", err)
+	}
+
+	err = ctx.GetStub().PutState(occupancyKey, []byte(attendanceKey))
+	if err != nil {
+		return fmt.Errorf("error:%v", err)
+	}
+
+	err = ctx.GetStub().PutState(openVisitKey, []byte(attendanceKey))
+	if err != nil {
+		return fmt.Errorf("error:%v", err)
+	}
+
+	return nil
+}
+
+// checkOutUser closes the open visit of the user
+func checkOutUser(ctx contractapi.TransactionContextInterface, userId string) (*Attendance, error) {
+
+	openVisitKey, err := ctx.GetStub().CreateCompositeKey(openVisitIndex, []string{userId})
+	if err != nil {
+		return nil, fmt.Errorf("failed to create the composite key for prefix %s: %v", openVisitIndex, err)
+	}
+
+	attendanceKey, err := ctx.GetStub().GetState(openVisitKey)
+	if err != nil {
+		return nil, fmt.Errorf("error:%v", err)
+	}
+
+	if attendanceKey == nil {
+		return nil, fmt.Errorf("%v is not checked in", userId)
+	}
+
+	attendancebytes, err := ctx.GetStub().GetState(string(attendanceKey))
+	if err != nil {
+		return nil, fmt.Errorf("error:%v", err)
+	}
+
+	attendance := new(Attendance)
+	_ = json.Unmarshal(attendancebytes, &attendance)
+
+	now, err := getTxTime(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("error:%v", err)
+	}
+
+	attendance.CheckOutTime = now.Format(timeFormat)
+
+	attendancebytes, _ = json.Marshal(attendance)
+	err = ctx.GetStub().PutState(string(attendanceKey), attendancebytes)
+	if err != nil {
+		return nil, fmt.Errorf("error:%v", err)
+	}
+
+	err = ctx.GetStub().DelState(openVisitKey)
+	if err != nil {
+		return nil, fmt.Errorf("error:%v", err)
+	}
+
+	log.Printf("%v checked out of %v", userId, attendance.FacilityID)
+
+	return attendance, nil
+}
+
+// getAttendance returns the visits of a user between two dates, both inclusive
+func getAttendance(ctx contractapi.TransactionContextInterface, userId string, from time.Time, to time.Time) ([]Attendance, error) {
+
+	attendanceIterator, err := ctx.GetStub().GetStateByPartialCompositeKey(attendanceIndex, []string{userId})
+	if err != nil {
+		return nil, fmt.Errorf("error:%v", err.Error())
+	}
+
+	defer attendanceIterator.Close()
+
+	fromDay := from.Format(keyDateFormat)
+	toDay := to.Format(keyDateFormat)
+
+	results := []Attendance{}
+	for attendanceIterator.HasNext() {
+		responseRange, err := attendanceIterator.Next()
+		if err != nil {
+			return nil, fmt.Errorf("error:%v", err.Error())
+		}
+
+		_, compositeKeyParts, err := ctx.GetStub().SplitCompositeKey(responseRange.Key)
+		if err != nil {
+			return nil, fmt.Errorf("error:%v", err.Error())
+		}
+
+		day := compositeKeyParts[1]
+		if day < fromDay || day > toDay {
+			continue
+		}
+
+		attendance := new(Attendance)
+		_ = json.Unmarshal(responseRange.Value, &attendance)
+		results = append(results, *attendance)
+	}
+
+	return results, nil
+}
+
+// toUserKey returns the ledger key of a user given either the client id or the "User-" prefixed key
+func toUserKey(userId string) string {
+
+	if strings.HasPrefix(userId, userPrefix) {
+		return userId
+	}
+
+	return userPrefix + userId
+}
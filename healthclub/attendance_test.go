@@ -0,0 +1,148 @@
+package healthclub
+
+import (
+	"testing"
+	"time"
+
+	"github.com/hyperledger/fabric-contract-api-go/contractapi"
+	"github.com/varun425/MiniClubChaincode/internal/chaincodetest"
+)
+
+// checkIn checks the user in at the facility and returns the error of the transaction
+func checkIn(c *testClub, user *chaincodetest.Identity, facilityId string) error {
+
+	_, err := c.invoke(user, func(ctx contractapi.TransactionContextInterface) error {
+		_, err := c.h.CheckIn(ctx, facilityId)
+		return err
+	})
+
+	return err
+}
+
+// visit checks the user in at the facility and out again an hour later
+func visit(t *testing.T, c *testClub, user *chaincodetest.Identity, facilityId string) {
+
+	t.Helper()
+
+	c.mustInvoke(t, user, func(ctx contractapi.TransactionContextInterface) error {
+		_, err := c.h.CheckIn(ctx, facilityId)
+		return err
+	})
+	c.after(time.Hour)
+	c.mustInvoke(t, user, func(ctx contractapi.TransactionContextInterface) error {
+		_, err := c.h.CheckOut(ctx)
+		return err
+	})
+}
+
+// visits returns the visits of the user on the day of the current timestamp
+func visits(t *testing.T, c *testClub, user *chaincodetest.Identity) []Attendance {
+
+	t.Helper()
+
+	var attendance []Attendance
+	c.mustInvoke(t, user, func(ctx contractapi.TransactionContextInterface) error {
+		var err error
+		attendance, err = c.h.GetAttendance(ctx, user.ID, c.Now.Format(dateFormat), c.Now.Format(dateFormat))
+		return err
+	})
+
+	return attendance
+}
+
+func TestCheckInRequiresMembershipAndOneOpenVisit(t *testing.T) {
+
+	c := newTestClub(t)
+	alice := c.member(t, "alice", 900)
+
+	if err := checkIn(c, alice, "gym"); err == nil {
+		t.Errorf("checked in without membership")
+	}
+
+	membershipId := c.buy(t, alice, goldlevel)
+
+	if err := checkIn(c, alice, "gym"); err != nil {
+		t.Fatalf("member not checked in: %v", err)
+	}
+	if err := checkIn(c, alice, "pool"); err == nil {
+		t.Errorf("checked in twice without checking out")
+	}
+
+	var occupancy *Occupancy
+	c.mustInvoke(t, c.staff, func(ctx contractapi.TransactionContextInterface) error {
+		var err error
+		occupancy, err = c.h.GetDailyOccupancy(ctx, "gym", c.Now.Format(dateFormat))
+		return err
+	})
+	if occupancy.TotalVisits != 1 || occupancy.UniqueMembers != 1 || occupancy.CheckedIn != 1 {
+		t.Errorf("occupancy %+v, want one member checked in", occupancy)
+	}
+
+	c.after(time.Hour)
+	c.mustInvoke(t, alice, func(ctx contractapi.TransactionContextInterface) error {
+		_, err := c.h.CheckOut(ctx)
+		return err
+	})
+
+	// the front desk checks the member in again
+	c.after(time.Hour)
+	c.mustInvoke(t, c.staff, func(ctx contractapi.TransactionContextInterface) error {
+		_, err := c.h.StaffCheckIn(ctx, alice.ID, "gym")
+		return err
+	})
+
+	attendance := visits(t, c, alice)
+	if len(attendance) != 2 {
+		t.Fatalf("%v visits, want 2", len(attendance))
+	}
+	for _, visit := range attendance {
+		if visit.MembershipID != membershipId || visit.FacilityID != "gym" {
+			t.Errorf("visit %+v, want a visit to gym with %v", visit, membershipId)
+		}
+	}
+
+	// visits of the same day are ordered by transaction id
+	checkedOut := map[string]bool{}
+	for _, visit := range attendance {
+		checkedOut[visit.CheckedInBy] = visit.CheckOutTime != ""
+	}
+	if !checkedOut[userPrefix+alice.ID] {
+		t.Errorf("visits %+v, want the visit of the member checked out", attendance)
+	}
+	if closed, found := checkedOut[c.staff.ID]; !found || closed {
+		t.Errorf("visits %+v, want an open visit checked in by the staff", attendance)
+	}
+}
+
+func TestAttendanceIsReadByTheMemberOrStaff(t *testing.T) {
+
+	c := newTestClub(t)
+	alice := c.member(t, "alice", 900)
+	bob := c.member(t, "bob", 0)
+	c.buy(t, alice, goldlevel)
+	visit(t, c, alice, "gym")
+
+	today := c.Now.Format(dateFormat)
+
+	_, err := c.invoke(bob, func(ctx contractapi.TransactionContextInterface) error {
+		_, err := c.h.GetAttendance(ctx, alice.ID, today, today)
+		return err
+	})
+	if err == nil {
+		t.Errorf("member read the attendance of another member")
+	}
+
+	var attendance []Attendance
+	c.mustInvoke(t, c.staff, func(ctx contractapi.TransactionContextInterface) error {
+		var err error
+		attendance, err = c.h.GetAttendance(ctx, userPrefix+alice.ID, today, today)
+		return err
+	})
+	if len(attendance) != 1 {
+		t.Errorf("staff read %v visits, want 1", len(attendance))
+	}
+
+	if len(visits(t, c, alice)) != 1 {
+		t.Errorf("member cannot read their own visit")
+	}
+}
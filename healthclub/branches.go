@@ -0,0 +1,360 @@
+package healthclub
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+
+	"github.com/hyperledger/fabric-contract-api-go/contractapi"
+)
+
+const (
+	branchIndex   = "branch~BranchID"
+	facilityIndex = "facility~FacilityID"
+)
+
+// Branch is one location of the club with its own manager, treasury account and facilities
+// Prices override the price of a level for memberships bought at the branch, levels without one use the level price.
+// RoyaltyPercent of every sale of a franchise branch is owed to headquarters, the owner account
+type Branch struct {
+	BranchID       string        `json:"branchid"`
+	Name           string        `json:"name"`
+	ManagerID      string        `json:"managerid"`
+	TreasuryID     string        `json:"treasuryid"`
+	Facilities     []string      `json:"facilities"`
+	Prices         []BranchPrice `json:"prices"`
+	RoyaltyPercent int           `json:"royaltypercent"`
+}
+
+// BranchPrice is the price of a level at a branch
+type BranchPrice struct {
+	Level            string `json:"level"`
+	EntryPrizeTokens int    `json:"entryprizetokens"`
+}
+
+// CreateBranch adds a location managed by managerId whose membership revenue is paid to the treasuryId account
+func (h *HealthClub) CreateBranch(ctx contractapi.TransactionContextInterface, branchId string, name string, managerId string, treasuryId string) (string, error) {
+
+	err := checkOwner(ctx)
+	if err != nil {
+		return "", err
+	}
+
+	if branchId == "" || name == "" || managerId == "" || treasuryId == "" {
+		return "", fmt.Errorf("branch id, name, manager and treasury are required")
+	}
+
+	if _, err := getBranch(ctx, branchId); err == nil {
+		return "", fmt.Errorf("branch %v already exists", branchId)
+	}
+
+	branch := &Branch{
+		BranchID:   branchId,
+		Name:       name,
+		ManagerID:  managerId,
+		TreasuryID: treasuryId,
+		Facilities: []string{},
+		Prices:     []BranchPrice{},
+	}
+
+	err = putBranch(ctx, branch)
+	if err != nil {
+		return "", err
+	}
+
+	log.Printf("branch %v %v created", branchId, name)
+
+	return "branch is created", nil
+}
+
+// SetBranchPrice sets the price of a level for memberships bought at the branch, by the owner or the branch manager
+func (h *HealthClub) SetBranchPrice(ctx contractapi.TransactionContextInterface, branchId string, level string, entryPrizeTokens int) (string, error) {
+
+	branch, err := getManagedBranch(ctx, branchId)
+	if err != nil {
+		return "", err
+	}
+
+	if level != goldlevel && level != diamondlevel && level != platinumlevel {
+		return "", fmt.Errorf("only Gold, Diamond, and Platinum levels are acceptable")
+	}
+
+	if entryPrizeTokens <= 0 {
+		return "", fmt.Errorf("price must be a positive integer")
+	}
+
+	prices := []BranchPrice{{Level: level, EntryPrizeTokens: entryPrizeTokens}}
+	for _, price := range branch.Prices {
+		if price.Level != level {
+			prices = append(prices, price)
+		}
+	}
+	branch.Prices = prices
+
+	err = putBranch(ctx, branch)
+	if err != nil {
+		return "", err
+	}
+
+	log.Printf("The %v level is set at %v tokens at branch %v", level, entryPrizeTokens, branchId)
+
+	return "branch price is updated", nil
+}
+
+// SetBranchRoyalty sets the percentage of the membership, class and trainer session sales of the branch paid to headquarters
+func (h *HealthClub) SetBranchRoyalty(ctx contractapi.TransactionContextInterface, branchId string, percent int) (string, error) {
+
+	err := checkOwner(ctx)
+	if err != nil {
+		return "", err
+	}
+
+	if percent < 0 || percent > 100 {
+		return "", fmt.Errorf("royalty must be between 0 and 100 percent")
+	}
+
+	branch, err := getBranch(ctx, branchId)
+	if err != nil {
+		return "", err
+	}
+
+	branch.RoyaltyPercent = percent
+
+	err = putBranch(ctx, branch)
+	if err != nil {
+		return "", err
+	}
+
+	log.Printf("branch %v pays %v percent royalty", branchId, percent)
+
+	return "branch royalty is updated", nil
+}
+
+// AddBranchFacility assigns a facility to the branch, check-ins at the facility are then restricted to members of the branch
+func (h *HealthClub) AddBranchFacility(ctx contractapi.TransactionContextInterface, branchId string, facilityId string) (string, error) {
+
+	branch, err := getManagedBranch(ctx, branchId)
+	if err != nil {
+		return "", err
+	}
+
+	if facilityId == "" {
+		return "", fmt.Errorf("facility id is required")
+	}
+
+	facilityBranch, err := getFacilityBranch(ctx, facilityId)
+	if err != nil {
+		return "", err
+	}
+
+	if facilityBranch != "" {
+		return "", fmt.Errorf("facility %v already belongs to branch %v", facilityId, facilityBranch)
+	}
+
+	facilityKey, err := ctx.GetStub().CreateCompositeKey(facilityIndex, []string{facilityId})
+	if err != nil {
+		return "", fmt.Errorf("failed to create the composite key for prefix %s: %v", facilityIndex, err)
+	}
+
+	err = ctx.GetStub().PutState(facilityKey, []byte(branchId))
+	if err != nil {
+		return "", fmt.Errorf("error:%v", err)
+	}
+
+	branch.Facilities = append(branch.Facilities, facilityId)
+
+	err = putBranch(ctx, branch)
+	if err != nil {
+		return "", err
+	}
+
+	return "facility is added", nil
+}
+
+// GetBranch returns a branch with its facilities and prices
+func (h *HealthClub) GetBranch(ctx contractapi.TransactionContextInterface, branchId string) (*Branch, error) {
+	return getBranch(ctx, branchId)
+}
+
+// GetNewMemberShipAtBranch buys a membership like GetNewMemberShip with the branch as home branch, at the branch price
+// Members of all-access levels can check in at every branch, others only at their home branch
+func (h *HealthClub) GetNewMemberShipAtBranch(ctx contractapi.TransactionContextInterface, level string, branchId string) (string, error) {
+
+	if _, err := getBranch(ctx, branchId); err != nil {
+		return "", err
+	}
+
+	return h.getNewMemberShip(ctx, level, purchaseOptions{BranchID: branchId})
+}
+
+// BuyDayPassAtBranch buys a day pass like BuyDayPass, valid at the facilities of the branch and paid to its treasury
+func (h *HealthClub) BuyDayPassAtBranch(ctx contractapi.TransactionContextInterface, date string, branchId string) (string, error) {
+
+	if _, err := getBranch(ctx, branchId); err != nil {
+		return "", err
+	}
+
+	return h.buyDayPass(ctx, date, branchId)
+}
+
+// UpdateMembershipLevelAllAccess sets whether memberships of the level give access to every branch
+func (h *HealthClub) UpdateMembershipLevelAllAccess(ctx contractapi.TransactionContextInterface, level string, allAccess bool) (string, error) {
+
+	err := checkOwner(ctx)
+	if err != nil {
+		return "", err
+	}
+
+	if level != goldlevel && level != diamondlevel && level != platinumlevel {
+		return "", fmt.Errorf("only Gold, Diamond, and Platinum levels are acceptable")
+	}
+
+	leveldetails, err := h.GetLevelDetails(ctx, level)
+	if err != nil {
+		return "", fmt.Errorf("error:%v", err)
+	}
+
+	leveldetails.AllAccess = allAccess
+
+	resInBytes, _ := json.Marshal(leveldetails)
+	err = ctx.GetStub().PutState(level, resInBytes)
+	if err != nil {
+		return "", fmt.Errorf("error:%v", err)
+	}
+
+	log.Printf("The %v level is updated to all-access %v", level, allAccess)
+
+	return "level is updated", nil
+}
+
+// checkBranchAccess returns an error if the membership is scoped to another branch than the one of the facility
+// Memberships without home branch, from before branches existed, and all-access memberships enter every branch
+func checkBranchAccess(ctx contractapi.TransactionContextInterface, membership *Membership, facilityId string) error {
+
+	if membership.BranchID == "" || membership.AllAccess {
+		return nil
+	}
+
+	facilityBranch, err := getFacilityBranch(ctx, facilityId)
+	if err != nil {
+		return err
+	}
+
+	if facilityBranch != "" && facilityBranch != membership.BranchID {
+		return fmt.Errorf("membership is valid at branch %v only", membership.BranchID)
+	}
+
+	return nil
+}
+
+// levelPrice returns the price of the level at the branch, or the level price without branch or branch price
+func levelPrice(ctx contractapi.TransactionContextInterface, level string, leveldetails *Level, branchId string) (int, error) {
+
+	if branchId == "" {
+		return leveldetails.EntryPrizeTokens, nil
+	}
+
+	branch, err := getBranch(ctx, branchId)
+	if err != nil {
+		return 0, err
+	}
+
+	for _, price := range branch.Prices {
+		if price.Level == level {
+			return price.EntryPrizeTokens, nil
+		}
+	}
+
+	return leveldetails.EntryPrizeTokens, nil
+}
+
+// revenueAccount returns the account membership revenue of the branch is paid to, the owner account without branch
+func revenueAccount(ctx contractapi.TransactionContextInterface, branchId string) (string, error) {
+
+	if branchId == "" {
+		return getOwnerID(ctx)
+	}
+
+	branch, err := getBranch(ctx, branchId)
+	if err != nil {
+		return "", err
+	}
+
+	return branch.TreasuryID, nil
+}
+
+// getManagedBranch reads a branch and returns an error if the caller is neither the owner nor the branch manager
+func getManagedBranch(ctx contractapi.TransactionContextInterface, branchId string) (*Branch, error) {
+
+	branch, err := getBranch(ctx, branchId)
+	if err != nil {
+		return nil, err
+	}
+
+	callerId, err := ctx.GetClientIdentity().GetID()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get userID: %v", err)
+	}
+
+	if callerId != branch.ManagerID && checkOwner(ctx) != nil {
+		return nil, fmt.Errorf("only the owner or the manager of branch %v can perform this operation", branchId)
+	}
+
+	return branch, nil
+}
+
+// getFacilityBranch returns the branch of the facility, empty if the facility is not assigned to a branch
+func getFacilityBranch(ctx contractapi.TransactionContextInterface, facilityId string) (string, error) {
+
+	facilityKey, err := ctx.GetStub().CreateCompositeKey(facilityIndex, []string{facilityId})
+	if err != nil {
+		return "", fmt.Errorf("failed to create the composite key for prefix %s: %v", facilityIndex, err)
+	}
+
+	branchbytes, err := ctx.GetStub().GetState(facilityKey)
+	if err != nil {
+		return "", fmt.Errorf("error:%v", err)
+	}
+
+	return string(branchbytes), nil
+}
+
+// getBranch reads a branch from the world state
+func getBranch(ctx contractapi.TransactionContextInterface, branchId string) (*Branch, error) {
+
+	branchKey, err := ctx.GetStub().CreateCompositeKey(branchIndex, []string{branchId})
+	if err != nil {
+		return nil, fmt.Errorf("failed to create the composite key for prefix %s: %v", branchIndex, err)
+	}
+
+	branchbytes, err := ctx.GetStub().GetState(branchKey)
+	if err != nil {
+		return nil, fmt.Errorf("error:%v", err)
+	}
+
+	if branchbytes == nil {
+		return nil, fmt.Errorf("branch %v not found", branchId)
+	}
+
+	branch := new(Branch)
+	_ = json.Unmarshal(branchbytes, &branch)
+
+	return branch, nil
+}
+
+// putBranch writes a branch to the world state
+func putBranch(ctx contractapi.TransactionContextInterface, branch *Branch) error {
+
+	branchKey, err := ctx.GetStub().CreateCompositeKey(branchIndex, []string{branch.BranchID})
+	if err != nil {
+		return fmt.Errorf("failed to create the composite key for prefix %s: %v", branchIndex, err)
+	}
+
+	branchbytes, _ := json.Marshal(branch)
+	err = ctx.GetStub().PutState(branchKey, branchbytes)
+	if err != nil {
+		return fmt.Errorf("error:%v", err)
+	}
+
+	return nil
+}
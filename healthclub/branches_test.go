@@ -0,0 +1,81 @@
+package healthclub
+
+import (
+	"testing"
+
+	"github.com/hyperledger/fabric-contract-api-go/contractapi"
+)
+
+// newBranches creates the branches north and south, each with one facility and its own treasury
+func newBranches(t *testing.T, c *testClub) {
+
+	t.Helper()
+
+	for _, branchId := range []string{"north", "south"} {
+		c.mustInvoke(t, c.owner, func(ctx contractapi.TransactionContextInterface) error {
+			_, err := c.h.CreateBranch(ctx, branchId, branchId, clientID(branchId+"-manager"), clientID(branchId+"-treasury"))
+			return err
+		})
+		c.mustInvoke(t, c.owner, func(ctx contractapi.TransactionContextInterface) error {
+			_, err := c.h.AddBranchFacility(ctx, branchId, branchId+"-gym")
+			return err
+		})
+	}
+}
+
+func TestCheckInIsRestrictedToTheHomeBranch(t *testing.T) {
+
+	c := newTestClub(t)
+	newBranches(t, c)
+
+	alice := c.member(t, "alice", 900)
+	c.mustInvoke(t, alice, func(ctx contractapi.TransactionContextInterface) error {
+		_, err := c.h.GetNewMemberShipAtBranch(ctx, goldlevel, "north")
+		return err
+	})
+	c.assertBalances(t, map[string]int{clientID("north-treasury"): 1000})
+
+	if err := checkIn(c, alice, "south-gym"); err == nil {
+		t.Errorf("member of north checked in at south")
+	}
+	visit(t, c, alice, "north-gym")
+
+	// facilities without branch admit every member
+	visit(t, c, alice, "gym")
+}
+
+func TestAllAccessMembershipsEnterEveryBranch(t *testing.T) {
+
+	c := newTestClub(t)
+	newBranches(t, c)
+	c.mustInvoke(t, c.owner, func(ctx contractapi.TransactionContextInterface) error {
+		_, err := c.h.UpdateMembershipLevelAllAccess(ctx, goldlevel, true)
+		return err
+	})
+
+	alice := c.member(t, "alice", 900)
+	c.mustInvoke(t, alice, func(ctx contractapi.TransactionContextInterface) error {
+		_, err := c.h.GetNewMemberShipAtBranch(ctx, goldlevel, "north")
+		return err
+	})
+
+	visit(t, c, alice, "south-gym")
+}
+
+func TestDayPassOfABranch(t *testing.T) {
+
+	c := newTestClub(t)
+	newBranches(t, c)
+
+	dave := c.member(t, "dave", 0)
+	c.mustInvoke(t, dave, func(ctx contractapi.TransactionContextInterface) error {
+		_, err := c.h.BuyDayPassAtBranch(ctx, testStart.Format(dateFormat), "north")
+		return err
+	})
+	c.assertBalances(t, map[string]int{dave.ID: 100 - defaultDayPassPrice, clientID("north-treasury"): defaultDayPassPrice})
+
+	if err := checkIn(c, dave, "south-gym"); err == nil {
+		t.Errorf("day pass of north used at south")
+	}
+	visit(t, c, dave, "north-gym")
+}
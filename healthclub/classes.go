@@ -0,0 +1,334 @@
+package healthclub
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+
+	"github.com/hyperledger/fabric-contract-api-go/contractapi"
+	erc20 "github.com/varun425/MiniClubChaincode/erc20"
+	"github.com/varun425/MiniClubChaincode/events"
+)
+
+const classIndex = "class~ClassID"
+
+// FitnessClass is a scheduled class members can book a seat in
+// Bookings and Waitlist hold user ids in booking order, the waitlist is promoted first in first out
+type FitnessClass struct {
+	ClassID        string   `json:"classid"`
+	Name           string   `json:"name"`
+	Trainer        string   `json:"trainer"`
+	Room           string   `json:"room"`
+	StartTime      string   `json:"starttime"`
+	Capacity       int      `json:"capacity"`
+	EligibleLevels []string `json:"eligiblelevels"`
+	Price          int      `json:"price"`
+	Bookings       []string `json:"bookings"`
+	Waitlist       []string `json:"waitlist"`
+	Attended       []string `json:"attended"`
+	Settled        bool     `json:"settled"`
+}
+
+// WaitlistPromotion is emitted when a waitlisted member gets a seat freed by a cancellation
+type WaitlistPromotion struct {
+	ClassID string `json:"classid"`
+	UserID  string `json:"userid"`
+}
+
+// CreateClass schedules a class, startTime is MM-DD-YYYY HH:MM:SS
+// eligibleLevels restricts booking to members of those levels, an empty list admits every level
+func (h *HealthClub) CreateClass(ctx contractapi.TransactionContextInterface, classId string, name string, trainer string, room string, startTime string, capacity int, eligibleLevels []string, price int) (string, error) {
+
+	err := checkStaff(ctx)
+	if err != nil {
+		return "", err
+	}
+
+	if classId == "" || name == "" {
+		return "", fmt.Errorf("class id and name are required")
+	}
+
+	if _, err := time.Parse(timeFormat, startTime); err != nil {
+		return "", fmt.Errorf("invalid start time %v, expected MM-DD-YYYY HH:MM:SS", startTime)
+	}
+
+	if capacity <= 0 {
+		return "", fmt.Errorf("capacity must be a positive integer")
+	}
+
+	if price < 0 {
+		return "", fmt.Errorf("price cannot be negative")
+	}
+
+	for _, level := range eligibleLevels {
+		if level != goldlevel && level != diamondlevel && level != platinumlevel {
+			return "", fmt.Errorf("only Gold, Diamond, and Platinum levels are acceptable")
+		}
+	}
+
+	classKey, err := ctx.GetStub().CreateCompositeKey(classIndex, []string{classId})
+	if err != nil {
+		return "", fmt.Errorf("failed to create the composite key for prefix %s: %v", classIndex, err)
+	}
+
+	existing, err := ctx.GetStub().GetState(classKey)
+	if err != nil {
+		return "", fmt.Errorf("error:%v", err)
+	}
+
+	if existing != nil {
+		return "", fmt.Errorf("class %v already exists", classId)
+	}
+
+	class := &FitnessClass{
+		ClassID:        classId,
+		Name:           name,
+		Trainer:        trainer,
+		Room:           room,
+		StartTime:      startTime,
+		Capacity:       capacity,
+		EligibleLevels: eligibleLevels,
+		Price:          price,
+		Bookings:       []string{},
+		Waitlist:       []string{},
+		Attended:       []string{},
+	}
+
+	err = putClass(ctx, class)
+	if err != nil {
+		return "", err
+	}
+
+	log.Printf("class %v scheduled at %v in %v for %v members", classId, startTime, room, capacity)
+
+	return "class is created", nil
+}
+
+// GetClassDetails returns a class with its bookings and waitlist
+func (h *HealthClub) GetClassDetails(ctx contractapi.TransactionContextInterface, classId string) (*FitnessClass, error) {
+	return getClass(ctx, classId)
+}
+
+// BookClass books the caller a seat in the class, or a place on the waitlist when the class is full
+// The class price is paid to the club account when booking, also for the waitlist, and refunded on cancellation
+func (h *HealthClub) BookClass(ctx contractapi.TransactionContextInterface, classId string) (string, error) {
+
+	userid, err := ctx.GetClientIdentity().GetID()
+	if err != nil {
+		return "", fmt.Errorf("failed to get userID: %v", err)
+	}
+
+	userId := userPrefix + userid
+
+	class, err := getClass(ctx, classId)
+	if err != nil {
+		return "", err
+	}
+
+	now, err := getTxTime(ctx)
+	if err != nil {
+		return "", fmt.Errorf("error:%v", err)
+	}
+
+	classStart, _ := time.Parse(timeFormat, class.StartTime)
+	if !now.Before(classStart) {
+		return "", fmt.Errorf("class %v has already started", classId)
+	}
+
+	if containsUser(class.Bookings, userId) || containsUser(class.Waitlist, userId) {
+		return "", fmt.Errorf("already booked in class %v", classId)
+	}
+
+	err = checkBookingAllowed(ctx, userId, now)
+	if err != nil {
+		return "", err
+	}
+
+	_, membershipdetails, err := getAccessMembership(ctx, userId)
+	if err != nil {
+		return "", fmt.Errorf("error:%v", err)
+	}
+
+	err = checkMembershipAccess(membershipdetails, now)
+	if err != nil {
+		return "", fmt.Errorf("cannot book class: %v", err)
+	}
+
+	if len(class.EligibleLevels) != 0 && !containsUser(class.EligibleLevels, membershipdetails.Level) {
+		return "", fmt.Errorf("class %v is open to %v members only", classId, strings.Join(class.EligibleLevels, ", "))
+	}
+
+	result := "Class booked"
+	if len(class.Bookings) < class.Capacity {
+		class.Bookings = append(class.Bookings, userId)
+	} else {
+		class.Waitlist = append(class.Waitlist, userId)
+		result = fmt.Sprintf("Class is full, added to waitlist at position %v", len(class.Waitlist))
+	}
+
+	err = putClass(ctx, class)
+	if err != nil {
+		return "", err
+	}
+
+	if class.Price > 0 {
+		// classes held in a room assigned to a branch are sales of that branch
+		branchId, err := getFacilityBranch(ctx, class.Room)
+		if err != nil {
+			return "", err
+		}
+
+		err = h.collectRevenue(ctx, branchId, revenueClass, class.Price, now)
+		if err != nil {
+			return "", err
+		}
+	}
+
+	log.Printf("%v: %v in class %v", result, userId, classId)
+
+	return result, nil
+}
+
+// CancelBooking cancels the seat or waitlist place of the caller in a class that has not started and refunds the price
+// A freed seat goes to the first member on the waitlist.
+// This function triggers a ClassWaitlistPromoted event when a member is promoted
+func (h *HealthClub) CancelBooking(ctx contractapi.TransactionContextInterface, classId string) (string, error) {
+
+	userid, err := ctx.GetClientIdentity().GetID()
+	if err != nil {
+		return "", fmt.Errorf("failed to get userID: %v", err)
+	}
+
+	userId := userPrefix + userid
+
+	class, err := getClass(ctx, classId)
+	if err != nil {
+		return "", err
+	}
+
+	now, err := getTxTime(ctx)
+	if err != nil {
+		return "", fmt.Errorf("error:%v", err)
+	}
+
+	classStart, _ := time.Parse(timeFormat, class.StartTime)
+	if !now.Before(classStart) {
+		return "", fmt.Errorf("class %v has already started", classId)
+	}
+
+	promotions := []WaitlistPromotion{}
+	if containsUser(class.Bookings, userId) {
+		class.Bookings = removeUser(class.Bookings, userId)
+
+		if len(class.Waitlist) > 0 && len(class.Bookings) < class.Capacity {
+			promoted := class.Waitlist[0]
+			class.Waitlist = class.Waitlist[1:]
+			class.Bookings = append(class.Bookings, promoted)
+			promotions = append(promotions, WaitlistPromotion{ClassID: classId, UserID: promoted})
+			log.Printf("%v promoted from the waitlist of class %v", promoted, classId)
+		}
+	} else if containsUser(class.Waitlist, userId) {
+		class.Waitlist = removeUser(class.Waitlist, userId)
+	} else {
+		return "", fmt.Errorf("no booking found in class %v", classId)
+	}
+
+	err = putClass(ctx, class)
+	if err != nil {
+		return "", err
+	}
+
+	if class.Price > 0 {
+		branchId, err := getFacilityBranch(ctx, class.Room)
+		if err != nil {
+			return "", err
+		}
+
+		batch := erc20.NewTransferBatch(ctx)
+		err = refundRevenue(ctx, batch, branchId, revenueClass, userId, userid, class.Price, now)
+		if err != nil {
+			return "", fmt.Errorf("failed to refund booking: %v", err)
+		}
+
+		err = batch.Commit()
+		if err != nil {
+			return "", fmt.Errorf("error:%v", err)
+		}
+	}
+
+	if len(promotions) > 0 {
+		err = emitEvent(ctx, events.ClassWaitlistPromoted, promotions)
+		if err != nil {
+			return "", err
+		}
+	}
+
+	return "Booking cancelled", nil
+}
+
+// getClass reads a class from the world state
+func getClass(ctx contractapi.TransactionContextInterface, classId string) (*FitnessClass, error) {
+
+	classKey, err := ctx.GetStub().CreateCompositeKey(classIndex, []string{classId})
+	if err != nil {
+		return nil, fmt.Errorf("failed to create the composite key for prefix %s: %v", classIndex, err)
+	}
+
+	classbytes, err := ctx.GetStub().GetState(classKey)
+	if err != nil {
+		return nil, fmt.Errorf("error:%v", err)
+	}
+
+	if classbytes == nil {
+		return nil, fmt.Errorf("class %v not found", classId)
+	}
+
+	class := new(FitnessClass)
+	_ = json.Unmarshal(classbytes, &class)
+
+	return class, nil
+}
+
+// putClass writes a class to the world state
+func putClass(ctx contractapi.TransactionContextInterface, class *FitnessClass) error {
+
+	classKey, err := ctx.GetStub().CreateCompositeKey(classIndex, []string{class.ClassID})
+	if err != nil {
+		return fmt.Errorf("failed to create the composite key for prefix %s: %v", classIndex, err)
+	}
+
+	classbytes, _ := json.Marshal(class)
+	err = ctx.GetStub().PutState(classKey, classbytes)
+	if err != nil {
+		return fmt.Errorf("error:%v", err)
+	}
+
+	return nil
+}
+
+// containsUser reports whether the id is in the list
+func containsUser(ids []string, id string) bool {
+
+	for _, existing := range ids {
+		if existing == id {
+			return true
+		}
+	}
+
+	return false
+}
+
+// removeUser returns the list without the id, keeping the order of the others
+func removeUser(ids []string, id string) []string {
+
+	remaining := []string{}
+	for _, existing := range ids {
+		if existing != id {
+			remaining = append(remaining, existing)
+		}
+	}
+
+	return remaining
+}
@@ -0,0 +1,138 @@
+package healthclub
+
+import (
+	"fmt"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/hyperledger/fabric-contract-api-go/contractapi"
+	"github.com/varun425/MiniClubChaincode/internal/chaincodetest"
+)
+
+// newClass schedules a class in the studio a day after the current timestamp
+func newClass(t *testing.T, c *testClub, classId string, capacity int, eligibleLevels []string, price int) {
+
+	t.Helper()
+
+	c.mustInvoke(t, c.staff, func(ctx contractapi.TransactionContextInterface) error {
+		_, err := c.h.CreateClass(ctx, classId, "Spinning", "tom", "studio", c.Now.Add(24*time.Hour).Format(timeFormat), capacity, eligibleLevels, price)
+		return err
+	})
+}
+
+// bookClass books the user in the class and returns the result of the transaction
+func bookClass(c *testClub, user *chaincodetest.Identity, classId string) (string, error) {
+
+	var result string
+	_, err := c.invoke(user, func(ctx contractapi.TransactionContextInterface) error {
+		var err error
+		result, err = c.h.BookClass(ctx, classId)
+		return err
+	})
+
+	return result, err
+}
+
+// class returns the committed class
+func class(t *testing.T, c *testClub, classId string) *FitnessClass {
+
+	t.Helper()
+
+	var class *FitnessClass
+	c.mustInvoke(t, c.staff, func(ctx contractapi.TransactionContextInterface) error {
+		var err error
+		class, err = c.h.GetClassDetails(ctx, classId)
+		return err
+	})
+
+	return class
+}
+
+func TestFullClassWaitlistIsChargedAndPromotedOnCancellation(t *testing.T) {
+
+	c := newTestClub(t)
+	alice := c.member(t, "alice", 950)
+	bob := c.member(t, "bob", 950)
+	carol := c.member(t, "carol", 950)
+	for _, member := range []*chaincodetest.Identity{alice, bob, carol} {
+		c.buy(t, member, goldlevel)
+	}
+	ownerBalance := c.Balance(c.owner.ID)
+
+	newClass(t, c, "spin", 1, nil, 20)
+
+	for i, member := range []*chaincodetest.Identity{alice, bob, carol} {
+		result, err := bookClass(c, member, "spin")
+		if err != nil {
+			t.Fatalf("booking %v failed: %v", i+1, err)
+		}
+		if i > 0 && result != fmt.Sprintf("Class is full, added to waitlist at position %v", i) {
+			t.Errorf("booking %v: %v, want a place on the waitlist", i+1, result)
+		}
+	}
+
+	if _, err := bookClass(c, alice, "spin"); err == nil {
+		t.Errorf("member booked the same class twice")
+	}
+
+	// the waitlist pays the price as well
+	c.assertBalances(t, map[string]int{alice.ID: 30, bob.ID: 30, carol.ID: 30, c.owner.ID: ownerBalance + 60})
+
+	c.mustInvoke(t, alice, func(ctx contractapi.TransactionContextInterface) error {
+		_, err := c.h.CancelBooking(ctx, "spin")
+		return err
+	})
+
+	spin := class(t, c, "spin")
+	if !reflect.DeepEqual(spin.Bookings, []string{userPrefix + bob.ID}) || !reflect.DeepEqual(spin.Waitlist, []string{userPrefix + carol.ID}) {
+		t.Errorf("bookings %v and waitlist %v, want bob promoted ahead of carol", spin.Bookings, spin.Waitlist)
+	}
+
+	// leaving the waitlist is refunded too
+	c.mustInvoke(t, carol, func(ctx contractapi.TransactionContextInterface) error {
+		_, err := c.h.CancelBooking(ctx, "spin")
+		return err
+	})
+
+	if spin = class(t, c, "spin"); len(spin.Waitlist) != 0 {
+		t.Errorf("waitlist %v, want it empty", spin.Waitlist)
+	}
+	c.assertBalances(t, map[string]int{alice.ID: 50, bob.ID: 30, carol.ID: 50, c.owner.ID: ownerBalance + 20})
+
+	// nobody books or cancels once the class has started
+	c.after(24 * time.Hour)
+	if _, err := bookClass(c, carol, "spin"); err == nil {
+		t.Errorf("class booked after it started")
+	}
+	_, err := c.invoke(bob, func(ctx contractapi.TransactionContextInterface) error {
+		_, err := c.h.CancelBooking(ctx, "spin")
+		return err
+	})
+	if err == nil {
+		t.Errorf("booking cancelled after the class started")
+	}
+}
+
+func TestClassBookingRequiresAnEligibleMembership(t *testing.T) {
+
+	c := newTestClub(t)
+	alice := c.member(t, "alice", 900)
+	bob := c.member(t, "bob", 4900)
+
+	newClass(t, c, "yoga", 10, []string{platinumlevel, diamondlevel}, 0)
+
+	if _, err := bookClass(c, alice, "yoga"); err == nil {
+		t.Errorf("class booked without membership")
+	}
+
+	c.buy(t, alice, goldlevel)
+	c.buy(t, bob, platinumlevel)
+
+	if _, err := bookClass(c, alice, "yoga"); err == nil {
+		t.Errorf("Gold member booked a class for Platinum and Diamond members")
+	}
+	if _, err := bookClass(c, bob, "yoga"); err != nil {
+		t.Errorf("Platinum member not booked: %v", err)
+	}
+}
@@ -0,0 +1,494 @@
+package healthclub
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+
+	"github.com/hyperledger/fabric-contract-api-go/contractapi"
+	erc20 "github.com/varun425/MiniClubChaincode/erc20"
+)
+
+const (
+	corporateIndex       = "corporate~CorporateID"
+	corporateChargeIndex = "corporatecharge~CorporateID~Month~TxID"
+)
+
+// CorporateAccount sponsors the memberships of the employees on its roster
+// The employer pre-funds by approving an allowance of MFHC to the club owner account from AccountID, each membership
+// bought by an employee is paid from that allowance up to MaxPerEmployee tokens and the employee pays the rest.
+// Users are invited to the roster and join it once they accept
+type CorporateAccount struct {
+	CorporateID    string   `json:"corporateid"`
+	Name           string   `json:"name"`
+	AccountID      string   `json:"accountid"`
+	MaxPerEmployee int      `json:"maxperemployee"`
+	Employees      []string `json:"employees"`
+	Invites        []string `json:"invites"`
+}
+
+// CorporateCharge is a membership of an employee paid by the corporate account
+type CorporateCharge struct {
+	CorporateID  string `json:"corporateid"`
+	EmployeeID   string `json:"employeeid"`
+	MembershipID string `json:"membershipid"`
+	Level        string `json:"level"`
+	Price        int    `json:"price"`
+	Amount       int    `json:"amount"`
+	Date         string `json:"date"`
+}
+
+// CorporateStatement lists the charges of a corporate account in one month
+type CorporateStatement struct {
+	CorporateID string            `json:"corporateid"`
+	Month       string            `json:"month"`
+	Charges     []CorporateCharge `json:"charges"`
+	Total       int               `json:"total"`
+}
+
+// RegisterCorporateAccount registers a corporate account paid from the account of the caller, who manages the roster
+// maxPerEmployee is the most tokens the account pays towards one membership of an employee
+func (h *HealthClub) RegisterCorporateAccount(ctx contractapi.TransactionContextInterface, corporateId string, name string, maxPerEmployee int) (string, error) {
+
+	accountId, err := ctx.GetClientIdentity().GetID()
+	if err != nil {
+		return "", fmt.Errorf("failed to get userID: %v", err)
+	}
+
+	if corporateId == "" || name == "" {
+		return "", fmt.Errorf("corporate id and name are required")
+	}
+
+	if maxPerEmployee <= 0 {
+		return "", fmt.Errorf("max per employee must be a positive integer")
+	}
+
+	if _, err := getCorporate(ctx, corporateId); err == nil {
+		return "", fmt.Errorf("corporate account %v already exists", corporateId)
+	}
+
+	corporate := &CorporateAccount{
+		CorporateID:    corporateId,
+		Name:           name,
+		AccountID:      accountId,
+		MaxPerEmployee: maxPerEmployee,
+		Employees:      []string{},
+		Invites:        []string{},
+	}
+
+	err = putCorporate(ctx, corporate)
+	if err != nil {
+		return "", err
+	}
+
+	log.Printf("corporate account %v registered, paying up to %v tokens per employee", corporateId, maxPerEmployee)
+
+	return "corporate account is registered, approve an allowance to the club owner to fund it", nil
+}
+
+// SetCorporateEmployeeLimit changes the most tokens the corporate account pays towards one membership of an employee
+func (h *HealthClub) SetCorporateEmployeeLimit(ctx contractapi.TransactionContextInterface, corporateId string, maxPerEmployee int) (string, error) {
+
+	corporate, err := getManagedCorporate(ctx, corporateId)
+	if err != nil {
+		return "", err
+	}
+
+	if maxPerEmployee <= 0 {
+		return "", fmt.Errorf("max per employee must be a positive integer")
+	}
+
+	corporate.MaxPerEmployee = maxPerEmployee
+
+	err = putCorporate(ctx, corporate)
+	if err != nil {
+		return "", err
+	}
+
+	return "corporate account is updated", nil
+}
+
+// InviteCorporateEmployee invites a registered user to the roster, the user joins on AcceptCorporateInvite
+func (h *HealthClub) InviteCorporateEmployee(ctx contractapi.TransactionContextInterface, corporateId string, userId string) (string, error) {
+
+	corporate, err := getManagedCorporate(ctx, corporateId)
+	if err != nil {
+		return "", err
+	}
+
+	employeeId := toUserKey(userId)
+
+	employee, err := getUser(ctx, employeeId)
+	if err != nil {
+		return "", err
+	}
+
+	if employee.CorporateID != "" {
+		return "", fmt.Errorf("%v is already on the roster of %v", employeeId, employee.CorporateID)
+	}
+
+	if containsUser(corporate.Invites, employeeId) {
+		return "", fmt.Errorf("%v is already invited", employeeId)
+	}
+
+	corporate.Invites = append(corporate.Invites, employeeId)
+
+	err = putCorporate(ctx, corporate)
+	if err != nil {
+		return "", err
+	}
+
+	return "employee is invited", nil
+}
+
+// AcceptCorporateInvite joins the caller to the roster of the corporate account that invited the caller
+// The memberships the caller buys from then on are sponsored by the account
+func (h *HealthClub) AcceptCorporateInvite(ctx contractapi.TransactionContextInterface, corporateId string) (string, error) {
+
+	userid, err := ctx.GetClientIdentity().GetID()
+	if err != nil {
+		return "", fmt.Errorf("failed to get userID: %v", err)
+	}
+
+	employeeId := userPrefix + userid
+
+	corporate, err := getCorporate(ctx, corporateId)
+	if err != nil {
+		return "", err
+	}
+
+	if !containsUser(corporate.Invites, employeeId) {
+		return "", fmt.Errorf("no invite from %v", corporateId)
+	}
+
+	employee, err := getUser(ctx, employeeId)
+	if err != nil {
+		return "", err
+	}
+
+	if employee.CorporateID != "" {
+		return "", fmt.Errorf("already on the roster of %v", employee.CorporateID)
+	}
+
+	corporate.Invites = removeUser(corporate.Invites, employeeId)
+	corporate.Employees = append(corporate.Employees, employeeId)
+	employee.CorporateID = corporateId
+
+	err = putCorporate(ctx, corporate)
+	if err != nil {
+		return "", err
+	}
+
+	err = putUser(ctx, employeeId, employee)
+	if err != nil {
+		return "", err
+	}
+
+	log.Printf("%v joined the roster of %v", employeeId, corporateId)
+
+	return "joined corporate roster", nil
+}
+
+// RemoveCorporateEmployee removes a user or a pending invite from the roster, see removeCorporateEmployee
+func (h *HealthClub) RemoveCorporateEmployee(ctx contractapi.TransactionContextInterface, corporateId string, userId string) (string, error) {
+
+	corporate, err := getManagedCorporate(ctx, corporateId)
+	if err != nil {
+		return "", err
+	}
+
+	return removeCorporateEmployee(ctx, corporate, toUserKey(userId), "removed from the roster of "+corporateId)
+}
+
+// LeaveCorporateRoster removes the caller from the roster of the corporate account, also declining a pending invite
+func (h *HealthClub) LeaveCorporateRoster(ctx contractapi.TransactionContextInterface, corporateId string) (string, error) {
+
+	userid, err := ctx.GetClientIdentity().GetID()
+	if err != nil {
+		return "", fmt.Errorf("failed to get userID: %v", err)
+	}
+
+	corporate, err := getCorporate(ctx, corporateId)
+	if err != nil {
+		return "", err
+	}
+
+	return removeCorporateEmployee(ctx, corporate, userPrefix+userid, "left the roster of "+corporateId)
+}
+
+// removeCorporateEmployee removes the employee or the pending invite from the roster of the corporate account
+// A current membership sponsored by the account is cancelled, the unused days of the tokens deposited are refunded
+// from the treasury: the sponsored share to the corporate account and the share the employee paid to the employee
+func removeCorporateEmployee(ctx contractapi.TransactionContextInterface, corporate *CorporateAccount, employeeId string, reason string) (string, error) {
+
+	if containsUser(corporate.Invites, employeeId) {
+		corporate.Invites = removeUser(corporate.Invites, employeeId)
+
+		err := putCorporate(ctx, corporate)
+		if err != nil {
+			return "", err
+		}
+
+		return "invite is withdrawn", nil
+	}
+
+	if !containsUser(corporate.Employees, employeeId) {
+		return "", fmt.Errorf("%v is not on the roster of %v", employeeId, corporate.CorporateID)
+	}
+
+	employee, err := getUser(ctx, employeeId)
+	if err != nil {
+		return "", err
+	}
+
+	corporate.Employees = removeUser(corporate.Employees, employeeId)
+	employee.CorporateID = ""
+
+	err = putCorporate(ctx, corporate)
+	if err != nil {
+		return "", err
+	}
+
+	err = putUser(ctx, employeeId, employee)
+	if err != nil {
+		return "", err
+	}
+
+	currentmembershipId, membershipdetails, err := getCurrentMembership(ctx, employeeId)
+	if err != nil || membershipdetails.SponsoredBy != corporate.CorporateID {
+		return "employee is removed", nil
+	}
+
+	if membershipdetails.Status != StatusActive && membershipdetails.Status != StatusFrozen {
+		return "employee is removed", nil
+	}
+
+	now, err := getTxTime(ctx)
+	if err != nil {
+		return "", fmt.Errorf("error:%v", err)
+	}
+
+	membershipstartDate, _ := time.Parse(dateFormat, membershipdetails.StartDate)
+	membershipendDate, _ := time.Parse(dateFormat, membershipdetails.EndDate)
+
+	termDays := daysBetween(membershipstartDate, membershipendDate)
+	remainingDays := daysBetween(now, membershipendDate)
+
+	sponsorRefund := 0
+	employeeRefund := 0
+	if termDays > 0 && remainingDays > 0 {
+		sponsorRefund = (membershipdetails.SponsoredAmount * remainingDays) / termDays
+		employeeRefund = ((membershipdetails.TokenDeposited - membershipdetails.SponsoredAmount) * remainingDays) / termDays
+	}
+
+	membershipdetails.RefundAmount = employeeRefund
+	membershipdetails.AutoRenew = false
+
+	err = transitionMembership(membershipdetails, StatusCancelled, now, reason)
+	if err != nil {
+		return "", fmt.Errorf("error:%v", err)
+	}
+
+	err = putMembership(ctx, currentmembershipId, membershipdetails)
+	if err != nil {
+		return "", err
+	}
+
+	err = setAutoRenewIndex(ctx, currentmembershipId, employeeId, false)
+	if err != nil {
+		return "", fmt.Errorf("error:%v", err)
+	}
+
+	err = dissolveGroup(ctx, employeeId, "sponsored membership cancelled")
+	if err != nil {
+		return "", err
+	}
+
+	if sponsorRefund > 0 || employeeRefund > 0 {
+		batch := erc20.NewTransferBatch(ctx)
+
+		// booked under the account of the sponsor, the employee refund is booked under the employee
+		if sponsorRefund > 0 {
+			err = refundRevenue(ctx, batch, membershipdetails.BranchID, revenueCancel, corporate.AccountID, corporate.AccountID, sponsorRefund, now)
+			if err != nil {
+				return "", fmt.Errorf("failed to refund corporate account %v: %v", corporate.CorporateID, err)
+			}
+		}
+
+		if employeeRefund > 0 {
+			err = refundRevenue(ctx, batch, membershipdetails.BranchID, revenueCancel, employeeId, strings.TrimPrefix(employeeId, userPrefix), employeeRefund, now)
+			if err != nil {
+				return "", fmt.Errorf("failed to refund %v: %v", employeeId, err)
+			}
+		}
+
+		err = batch.Commit()
+		if err != nil {
+			return "", fmt.Errorf("error:%v", err)
+		}
+	}
+
+	err = emitMembershipCancelled(ctx, currentmembershipId, membershipdetails, reason, now)
+	if err != nil {
+		return "", err
+	}
+
+	log.Printf("sponsored membership %v of %v cancelled, %v tokens refunded to %v and %v to the employee: %v", currentmembershipId, employeeId, sponsorRefund, corporate.CorporateID, employeeRefund, reason)
+
+	return "employee is removed and the sponsored membership is cancelled", nil
+}
+
+// GetCorporateAccount returns a corporate account with its roster and pending invites
+func (h *HealthClub) GetCorporateAccount(ctx contractapi.TransactionContextInterface, corporateId string) (*CorporateAccount, error) {
+	return getCorporate(ctx, corporateId)
+}
+
+// GetCorporateStatement returns the memberships paid by the corporate account in the month (YYYY-MM)
+// The statement can be read by the corporate account and by staff
+func (h *HealthClub) GetCorporateStatement(ctx contractapi.TransactionContextInterface, corporateId string, month string) (*CorporateStatement, error) {
+
+	if _, err := time.Parse("2006-01", month); err != nil {
+		return nil, fmt.Errorf("invalid month %v, expected YYYY-MM", month)
+	}
+
+	if _, err := getManagedCorporate(ctx, corporateId); err != nil {
+		if checkStaff(ctx) != nil {
+			return nil, err
+		}
+	}
+
+	chargeIterator, err := ctx.GetStub().GetStateByPartialCompositeKey(corporateChargeIndex, []string{corporateId, month})
+	if err != nil {
+		return nil, fmt.Errorf("error:%v", err.Error())
+	}
+
+	defer chargeIterator.Close()
+
+	statement := &CorporateStatement{
+		CorporateID: corporateId,
+		Month:       month,
+		Charges:     []CorporateCharge{},
+	}
+
+	for chargeIterator.HasNext() {
+		responseRange, err := chargeIterator.Next()
+		if err != nil {
+			return nil, fmt.Errorf("error:%v", err.Error())
+		}
+
+		charge := new(CorporateCharge)
+		_ = json.Unmarshal(responseRange.Value, &charge)
+
+		statement.Charges = append(statement.Charges, *charge)
+		statement.Total = statement.Total + charge.Amount
+	}
+
+	return statement, nil
+}
+
+// chargeCorporate adds the sponsored part of the membership price, paid to the payee from the allowance the corporate
+// account granted to the owner account, to the batch and records the charge on the statement of the month
+func chargeCorporate(ctx contractapi.TransactionContextInterface, batch *erc20.TransferBatch, corporateId string, employeeId string, membershipId string, level string, price int, adminID string, payee string, now time.Time) (*CorporateCharge, error) {
+
+	corporate, err := getCorporate(ctx, corporateId)
+	if err != nil {
+		return nil, err
+	}
+
+	amount := price
+	if amount > corporate.MaxPerEmployee {
+		amount = corporate.MaxPerEmployee
+	}
+
+	err = batch.TransferFrom(corporate.AccountID, adminID, payee, amount)
+	if err != nil {
+		return nil, fmt.Errorf("corporate account %v cannot sponsor the membership: %v", corporateId, err)
+	}
+
+	charge := &CorporateCharge{
+		CorporateID:  corporateId,
+		EmployeeID:   employeeId,
+		MembershipID: membershipId,
+		Level:        level,
+		Price:        price,
+		Amount:       amount,
+		Date:         now.Format(dateFormat),
+	}
+
+	chargeKey, err := ctx.GetStub().CreateCompositeKey(corporateChargeIndex, []string{corporateId, now.Format("2006-01"), ctx.GetStub().GetTxID()})
+	if err != nil {
+		return nil, fmt.Errorf("failed to create the composite key for prefix %s: %v", corporateChargeIndex, err)
+	}
+
+	chargebytes, _ := json.Marshal(charge)
+	err = ctx.GetStub().PutState(chargeKey, chargebytes)
+	if err != nil {
+		return nil, fmt.Errorf("error:%v", err)
+	}
+
+	log.Printf("corporate account %v pays %v of %v tokens for %v", corporateId, amount, price, employeeId)
+
+	return charge, nil
+}
+
+// getManagedCorporate reads a corporate account and returns an error if the caller does not manage it
+func getManagedCorporate(ctx contractapi.TransactionContextInterface, corporateId string) (*CorporateAccount, error) {
+
+	corporate, err := getCorporate(ctx, corporateId)
+	if err != nil {
+		return nil, err
+	}
+
+	callerId, err := ctx.GetClientIdentity().GetID()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get userID: %v", err)
+	}
+
+	if callerId != corporate.AccountID {
+		return nil, fmt.Errorf("only the corporate account %v can perform this operation", corporateId)
+	}
+
+	return corporate, nil
+}
+
+// getCorporate reads a corporate account from the world state
+func getCorporate(ctx contractapi.TransactionContextInterface, corporateId string) (*CorporateAccount, error) {
+
+	corporateKey, err := ctx.GetStub().CreateCompositeKey(corporateIndex, []string{corporateId})
+	if err != nil {
+		return nil, fmt.Errorf("failed to create the composite key for prefix %s: %v", corporateIndex, err)
+	}
+
+	corporatebytes, err := ctx.GetStub().GetState(corporateKey)
+	if err != nil {
+		return nil, fmt.Errorf("error:%v", err)
+	}
+
+	if corporatebytes == nil {
+		return nil, fmt.Errorf("corporate account %v not found", corporateId)
+	}
+
+	corporate := new(CorporateAccount)
+	_ = json.Unmarshal(corporatebytes, &corporate)
+
+	return corporate, nil
+}
+
+// putCorporate writes a corporate account to the world state
+func putCorporate(ctx contractapi.TransactionContextInterface, corporate *CorporateAccount) error {
+
+	corporateKey, err := ctx.GetStub().CreateCompositeKey(corporateIndex, []string{corporate.CorporateID})
+	if err != nil {
+		return fmt.Errorf("failed to create the composite key for prefix %s: %v", corporateIndex, err)
+	}
+
+	corporatebytes, _ := json.Marshal(corporate)
+	err = ctx.GetStub().PutState(corporateKey, corporatebytes)
+	if err != nil {
+		return fmt.Errorf("error:%v", err)
+	}
+
+	return nil
+}
@@ -0,0 +1,142 @@
+package healthclub
+
+import (
+	"testing"
+	"time"
+
+	"github.com/hyperledger/fabric-contract-api-go/contractapi"
+	"github.com/varun425/MiniClubChaincode/internal/chaincodetest"
+)
+
+// newCorporate registers the corporate account acme paying up to maxPerEmployee tokens, funded with an allowance of funds
+func newCorporate(t *testing.T, c *testClub, maxPerEmployee int, funds int) *chaincodetest.Identity {
+
+	t.Helper()
+
+	acme := chaincodetest.NewIdentity(clientID("acme"), nil)
+	c.mustInvoke(t, acme, func(ctx contractapi.TransactionContextInterface) error {
+		_, err := c.h.RegisterCorporateAccount(ctx, "acme", "Acme Corp", maxPerEmployee)
+		return err
+	})
+	c.mustInvoke(t, acme, func(ctx contractapi.TransactionContextInterface) error {
+		return c.h.Mint(ctx, funds)
+	})
+	c.mustInvoke(t, acme, func(ctx contractapi.TransactionContextInterface) error {
+		return c.h.Approve(ctx, c.owner.ID, funds)
+	})
+
+	return acme
+}
+
+// joinCorporate invites the member to the roster of acme and accepts the invite
+func joinCorporate(t *testing.T, c *testClub, acme *chaincodetest.Identity, member *chaincodetest.Identity) {
+
+	t.Helper()
+
+	c.mustInvoke(t, acme, func(ctx contractapi.TransactionContextInterface) error {
+		_, err := c.h.InviteCorporateEmployee(ctx, "acme", member.ID)
+		return err
+	})
+	c.mustInvoke(t, member, func(ctx contractapi.TransactionContextInterface) error {
+		_, err := c.h.AcceptCorporateInvite(ctx, "acme")
+		return err
+	})
+}
+
+func TestCorporateRosterNeedsConsent(t *testing.T) {
+
+	c := newTestClub(t)
+	acme := newCorporate(t, c, 600, 2000)
+	alice := c.member(t, "alice", 900)
+
+	c.mustInvoke(t, acme, func(ctx contractapi.TransactionContextInterface) error {
+		_, err := c.h.InviteCorporateEmployee(ctx, "acme", alice.ID)
+		return err
+	})
+	if corporateId := c.user(t, userPrefix+alice.ID).CorporateID; corporateId != "" {
+		t.Fatalf("invited user is on the roster of %v", corporateId)
+	}
+
+	// alice declines, so the membership of alice is not sponsored
+	c.mustInvoke(t, alice, func(ctx contractapi.TransactionContextInterface) error {
+		_, err := c.h.LeaveCorporateRoster(ctx, "acme")
+		return err
+	})
+	if _, err := c.invoke(alice, func(ctx contractapi.TransactionContextInterface) error {
+		_, err := c.h.AcceptCorporateInvite(ctx, "acme")
+		return err
+	}); err == nil {
+		t.Fatalf("declined invite accepted")
+	}
+
+	membershipId := c.buy(t, alice, goldlevel)
+	c.assertBalances(t, map[string]int{alice.ID: 0, acme.ID: 2000})
+
+	if _, err := c.invoke(acme, func(ctx contractapi.TransactionContextInterface) error {
+		_, err := c.h.RemoveCorporateEmployee(ctx, "acme", alice.ID)
+		return err
+	}); err == nil {
+		t.Errorf("user removed from a roster the user is not on")
+	}
+	if status := c.membership(t, membershipId).Status; status != StatusActive {
+		t.Errorf("membership is %v, want %v", status, StatusActive)
+	}
+}
+
+func TestRemovingEmployeeCancelsSponsoredMembership(t *testing.T) {
+
+	c := newTestClub(t)
+	acme := newCorporate(t, c, 600, 2000)
+	alice := c.member(t, "alice", 300)
+	joinCorporate(t, c, acme, alice)
+
+	membershipId := c.buy(t, alice, goldlevel)
+	c.assertBalances(t, map[string]int{alice.ID: 0, acme.ID: 1400, c.owner.ID: 1000})
+
+	sponsored := c.membership(t, membershipId)
+	if sponsored.SponsoredBy != "acme" || sponsored.SponsoredAmount != 600 {
+		t.Fatalf("membership sponsored by %q for %v, want acme for 600", sponsored.SponsoredBy, sponsored.SponsoredAmount)
+	}
+
+	// 20 of the 31 days are left
+	c.after(10 * 24 * time.Hour)
+	c.mustInvoke(t, acme, func(ctx contractapi.TransactionContextInterface) error {
+		_, err := c.h.RemoveCorporateEmployee(ctx, "acme", alice.ID)
+		return err
+	})
+
+	membership := c.membership(t, membershipId)
+	if membership.Status != StatusCancelled || membership.SponsoredBy != "acme" || membership.AutoRenew {
+		t.Errorf("membership is %v sponsored by %q with autorenew %v, want %v sponsored by acme without autorenew",
+			membership.Status, membership.SponsoredBy, membership.AutoRenew, StatusCancelled)
+	}
+	if corporateId := c.user(t, userPrefix+alice.ID).CorporateID; corporateId != "" {
+		t.Errorf("removed employee is on the roster of %v", corporateId)
+	}
+
+	// the sponsored 600 and the 400 alice paid are refunded for the days left
+	c.assertBalances(t, map[string]int{alice.ID: 258, acme.ID: 1787, c.owner.ID: 355})
+}
+
+func TestSponsoredMembershipIsNotRenewedOrUpgradedByTheMember(t *testing.T) {
+
+	c := newTestClub(t)
+	acme := newCorporate(t, c, 600, 2000)
+	alice := c.member(t, "alice", 9900)
+	joinCorporate(t, c, acme, alice)
+	c.buy(t, alice, goldlevel)
+
+	if _, err := c.invoke(alice, func(ctx contractapi.TransactionContextInterface) error {
+		_, err := c.h.SetAutoRenew(ctx, true)
+		return err
+	}); err == nil {
+		t.Errorf("auto-renewal enabled on a sponsored membership")
+	}
+
+	if _, err := c.invoke(alice, func(ctx contractapi.TransactionContextInterface) error {
+		_, err := c.h.UpgradeMembership(ctx, diamondlevel)
+		return err
+	}); err == nil {
+		t.Errorf("sponsored membership upgraded")
+	}
+}
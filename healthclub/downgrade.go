@@ -0,0 +1,251 @@
+package healthclub
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+
+	"github.com/hyperledger/fabric-contract-api-go/contractapi"
+	erc20 "github.com/varun425/MiniClubChaincode/erc20"
+	"github.com/varun425/MiniClubChaincode/events"
+)
+
+const downgradePolicyKey = "DowngradePolicy"
+
+// DowngradePolicy configures DowngradeMembership
+// FeePercent of the prorated credit is kept by the club and a downgrade needs at least MinDaysRemaining days left
+type DowngradePolicy struct {
+	FeePercent       int `json:"feepercent"`
+	MinDaysRemaining int `json:"mindaysremaining"`
+}
+
+// Downgrade describes the credit paid back for a downgrade
+type Downgrade struct {
+	MembershipID  string `json:"membershipid"`
+	FromLevel     string `json:"fromlevel"`
+	ToLevel       string `json:"tolevel"`
+	RemainingDays int    `json:"remainingdays"`
+	Credit        int    `json:"credit"`
+	Fee           int    `json:"fee"`
+	Refund        int    `json:"refund"`
+}
+
+var defaultDowngradePolicy = DowngradePolicy{
+	FeePercent:       10,
+	MinDaysRemaining: 30,
+}
+
+// DowngradeMembership moves the current membership of the caller to a cheaper level for the rest of its term.
+// The caller is credited the days remaining times the difference between the per day value of the tokens
+// deposited over the term and the per day price of the new level over its own term, less the downgrade fee,
+// paid from the club treasury. The EndDate is unchanged.
+// Memberships sponsored by a corporate account cannot be downgraded.
+// This function triggers a MembershipDowngraded event
+func (h *HealthClub) DowngradeMembership(ctx contractapi.TransactionContextInterface, level string) (*Downgrade, error) {
+
+	userid, err := ctx.GetClientIdentity().GetID()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get userID: %v", err)
+	}
+
+	userId := userPrefix + userid
+
+	if level != goldlevel && level != diamondlevel && level != platinumlevel {
+		return nil, fmt.Errorf("level not matched,expected Gold,Platinum,Diamond but given: %v", level)
+	}
+
+	currentmembershipId, membershipdetails, err := getCurrentMembership(ctx, userId)
+	if err != nil {
+		return nil, fmt.Errorf("error:%v", err)
+	}
+
+	if membershipdetails.Status != StatusActive {
+		return nil, fmt.Errorf("cannot downgrade a %v membership", membershipdetails.Status)
+	}
+
+	// the credit would pay out the share of the corporate account
+	if membershipdetails.SponsoredBy != "" {
+		return nil, fmt.Errorf("membership is sponsored by %v and cannot be downgraded", membershipdetails.SponsoredBy)
+	}
+
+	if membershipdetails.Level == level {
+		return nil, fmt.Errorf("already on %v level", level)
+	}
+
+	leveldetails, err := h.GetLevelDetails(ctx, level)
+	if err != nil {
+		return nil, fmt.Errorf("error:%v", err)
+	}
+
+	err = checkGroupFits(ctx, userId, leveldetails.MaxGroupSize)
+	if err != nil {
+		return nil, err
+	}
+
+	price, err := levelPrice(ctx, level, leveldetails, membershipdetails.BranchID)
+	if err != nil {
+		return nil, err
+	}
+
+	if membershipdetails.TokenDeposited-price <= 0 {
+		return nil, fmt.Errorf("%v is not a downgrade from %v, use UpgradeMembership", level, membershipdetails.Level)
+	}
+
+	policy, err := h.GetDowngradePolicy(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	now, err := getTxTime(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("error:%v", err)
+	}
+
+	membershipstartDate, _ := time.Parse(dateFormat, membershipdetails.StartDate)
+	membershipendDate, _ := time.Parse(dateFormat, membershipdetails.EndDate)
+
+	termDays := daysBetween(membershipstartDate, membershipendDate)
+	remainingDays := daysBetween(now, membershipendDate)
+
+	if remainingDays < policy.MinDaysRemaining || termDays <= 0 {
+		return nil, fmt.Errorf("downgrade requires at least %v days remaining, %v left", policy.MinDaysRemaining, remainingDays)
+	}
+
+	// both levels are valued per day of their own term, a shorter term costs more per day
+	newTermDays := daysBetween(membershipstartDate, membershipstartDate.AddDate(0, leveldetails.Months, 0))
+
+	credit := (membershipdetails.TokenDeposited*remainingDays)/termDays - (price*remainingDays)/newTermDays
+	if credit <= 0 {
+		return nil, fmt.Errorf("%v costs as much or more per day than %v, there is no credit to pay back", level, membershipdetails.Level)
+	}
+
+	fee := (credit * policy.FeePercent) / 100
+
+	downgrade := &Downgrade{
+		MembershipID:  currentmembershipId,
+		FromLevel:     membershipdetails.Level,
+		ToLevel:       level,
+		RemainingDays: remainingDays,
+		Credit:        credit,
+		Fee:           fee,
+		Refund:        credit - fee,
+	}
+
+	membershipdetails.Level = level
+	membershipdetails.AllAccess = leveldetails.AllAccess
+	membershipdetails.TokenDeposited = membershipdetails.TokenDeposited - downgrade.Refund
+
+	err = putMembership(ctx, currentmembershipId, membershipdetails)
+	if err != nil {
+		return nil, err
+	}
+
+	err = moveLevelIndex(ctx, userId, downgrade.FromLevel, level)
+	if err != nil {
+		return nil, err
+	}
+
+	if downgrade.Refund > 0 {
+		// refunded by the treasury that received the membership revenue
+		batch := erc20.NewTransferBatch(ctx)
+		err = refundRevenue(ctx, batch, membershipdetails.BranchID, revenueDowngrade, userId, strings.TrimPrefix(userId, userPrefix), downgrade.Refund, now)
+		if err != nil {
+			return nil, fmt.Errorf("failed to pay downgrade credit: %v", err)
+		}
+
+		err = batch.Commit()
+		if err != nil {
+			return nil, fmt.Errorf("error:%v", err)
+		}
+	}
+
+	err = emitEvent(ctx, events.MembershipDowngraded, downgrade)
+	if err != nil {
+		return nil, err
+	}
+
+	log.Printf("membership %v downgraded from %v to %v with %v tokens refunded", currentmembershipId, downgrade.FromLevel, level, downgrade.Refund)
+
+	return downgrade, nil
+}
+
+// SetDowngradePolicy sets the fee percent kept from downgrade credits and the minimum days remaining to downgrade
+func (h *HealthClub) SetDowngradePolicy(ctx contractapi.TransactionContextInterface, feePercent int, minDaysRemaining int) (string, error) {
+
+	err := checkOwner(ctx)
+	if err != nil {
+		return "", err
+	}
+
+	if feePercent < 0 || feePercent > 100 {
+		return "", fmt.Errorf("fee percent must be between 0 and 100")
+	}
+
+	if minDaysRemaining < 0 {
+		return "", fmt.Errorf("minimum days remaining cannot be negative")
+	}
+
+	policy := DowngradePolicy{
+		FeePercent:       feePercent,
+		MinDaysRemaining: minDaysRemaining,
+	}
+
+	policybytes, _ := json.Marshal(policy)
+	err = ctx.GetStub().PutState(downgradePolicyKey, policybytes)
+	if err != nil {
+		return "", fmt.Errorf("error:%v", err)
+	}
+
+	log.Printf("downgrade policy set to %v%% fee with %v minimum days remaining", feePercent, minDaysRemaining)
+
+	return "downgrade policy is updated", nil
+}
+
+// GetDowngradePolicy returns the downgrade policy, or the default policy if the owner has not set one
+func (h *HealthClub) GetDowngradePolicy(ctx contractapi.TransactionContextInterface) (*DowngradePolicy, error) {
+
+	policybytes, err := ctx.GetStub().GetState(downgradePolicyKey)
+	if err != nil {
+		return nil, fmt.Errorf("error:%v", err)
+	}
+
+	policy := defaultDowngradePolicy
+	if policybytes != nil {
+		_ = json.Unmarshal(policybytes, &policy)
+	}
+
+	return &policy, nil
+}
+
+// moveLevelIndex moves the user from the oldLevel to the newLevel entry of the level~UserID index
+func moveLevelIndex(ctx contractapi.TransactionContextInterface, userId string, oldLevel string, newLevel string) error {
+
+	oldIndexKey, err := ctx.GetStub().CreateCompositeKey(levelIndex, []string{oldLevel, userId})
+	if err != nil {
+		return fmt.Errorf("failed to create the composite key for prefix %s: %v", levelIndex, err)
+	}
+
+	err = ctx.GetStub().DelState(oldIndexKey)
+	if err != nil {
+		return fmt.Errorf("error in del state for %v level composite key", oldLevel)
+	}
+
+	newIndexKey, err := ctx.GetStub().CreateCompositeKey(levelIndex, []string{newLevel, userId})
+	if err != nil {
+		return fmt.Errorf("failed to create the composite key for prefix %s: %v", levelIndex, err)
+	}
+
+	userdetailsbytes, err := ctx.GetStub().GetState(userId)
+	if err != nil {
+		return fmt.Errorf("error:%v", err)
+	}
+
+	err = ctx.GetStub().PutState(newIndexKey, userdetailsbytes)
+	if err != nil {
+		return fmt.Errorf("error %v", err)
+	}
+
+	return nil
+}
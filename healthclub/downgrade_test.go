@@ -0,0 +1,83 @@
+package healthclub
+
+import (
+	"testing"
+	"time"
+
+	"github.com/hyperledger/fabric-contract-api-go/contractapi"
+)
+
+func TestSponsoredMembershipCannotBeDowngraded(t *testing.T) {
+
+	c := newTestClub(t)
+	acme := newCorporate(t, c, 4000, 4000)
+	alice := c.member(t, "alice", 900)
+	joinCorporate(t, c, acme, alice)
+
+	membershipId := c.buy(t, alice, platinumlevel)
+	c.assertBalances(t, map[string]int{alice.ID: 0, acme.ID: 0, c.owner.ID: 5000})
+
+	if _, err := c.invoke(alice, func(ctx contractapi.TransactionContextInterface) error {
+		_, err := c.h.DowngradeMembership(ctx, goldlevel)
+		return err
+	}); err == nil {
+		t.Fatalf("sponsored membership downgraded")
+	}
+
+	if level := c.membership(t, membershipId).Level; level != platinumlevel {
+		t.Errorf("membership level %v, want %v", level, platinumlevel)
+	}
+	c.assertBalances(t, map[string]int{alice.ID: 0, c.owner.ID: 5000})
+}
+
+func TestDowngradeCreditIsProratedPerDayOfEachTerm(t *testing.T) {
+
+	c := newTestClub(t)
+	c.mustInvoke(t, c.owner, func(ctx contractapi.TransactionContextInterface) error {
+		_, err := c.h.UpdateMembershipLevelToken(ctx, goldlevel, 300)
+		return err
+	})
+
+	alice := c.member(t, "alice", 7900)
+	membershipId := c.buy(t, alice, diamondlevel)
+	c.after(65 * 24 * time.Hour)
+
+	var downgrade *Downgrade
+	c.mustInvoke(t, alice, func(ctx contractapi.TransactionContextInterface) error {
+		var err error
+		downgrade, err = c.h.DowngradeMembership(ctx, goldlevel)
+		return err
+	})
+
+	// 299 days of the 365 day Diamond term are worth 6553, the same days of the 31 day Gold term cost 2893
+	if downgrade.RemainingDays != 299 || downgrade.Credit != 3660 || downgrade.Fee != 366 || downgrade.Refund != 3294 {
+		t.Errorf("downgrade %+v, want 299 days remaining with 3660 credit, 366 fee and 3294 refund", downgrade)
+	}
+
+	membership := c.membership(t, membershipId)
+	if membership.Level != goldlevel || membership.TokenDeposited != 4706 {
+		t.Errorf("membership on %v with %v deposited, want %v with 4706", membership.Level, membership.TokenDeposited, goldlevel)
+	}
+	c.assertBalances(t, map[string]int{alice.ID: 3294, c.owner.ID: 4706})
+}
+
+func TestDowngradeToLevelCostingMorePerDay(t *testing.T) {
+
+	c := newTestClub(t)
+	alice := c.member(t, "alice", 4900)
+	membershipId := c.buy(t, alice, platinumlevel)
+	c.after(10 * 24 * time.Hour)
+
+	// Gold costs 1000 for a month, more per day than 5000 for six months of Platinum
+	if _, err := c.invoke(alice, func(ctx contractapi.TransactionContextInterface) error {
+		_, err := c.h.DowngradeMembership(ctx, goldlevel)
+		return err
+	}); err == nil {
+		t.Fatalf("downgraded to a level costing more per day")
+	}
+
+	if level := c.membership(t, membershipId).Level; level != platinumlevel {
+		t.Errorf("membership level %v, want %v", level, platinumlevel)
+	}
+	c.assertBalances(t, map[string]int{alice.ID: 0, c.owner.ID: 5000})
+}
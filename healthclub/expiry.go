@@ -0,0 +1,145 @@
+package healthclub
+
+import (
+	"fmt"
+	"log"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/hyperledger/fabric-contract-api-go/contractapi"
+)
+
+const levelIndex = "level~UserID"
+
+// ExpiredMembership identifies a membership expired by ExpireMemberships
+type ExpiredMembership struct {
+	MembershipID string `json:"membershipid"`
+	UserID       string `json:"userid"`
+	Level        string `json:"level"`
+	EndDate      string `json:"enddate"`
+}
+
+// ExpiryResult is the outcome of one ExpireMemberships page
+// Bookmark is empty once all memberships have been scanned
+type ExpiryResult struct {
+	Expired             []ExpiredMembership `json:"expired"`
+	FetchedRecordsCount int                 `json:"fetchedrecordscount"`
+	Bookmark            string              `json:"bookmark"`
+}
+
+// ExpireMemberships scans up to pageSize memberships after the given bookmark and expires the active
+// memberships whose EndDate has passed according to the transaction timestamp, removing their entry
+// from the level~UserID index. Memberships that opted in to auto-renewal are left to ProcessRenewals.
+// Fabric only supports paginated range queries in read-only transactions, so the bookmark returned is
+// the last membership key scanned and the next page starts right after it.
+// This function triggers a MembershipExpired event for every expired membership, all in the envelope of the transaction
+func (h *HealthClub) ExpireMemberships(ctx contractapi.TransactionContextInterface, pageSize int, bookmark string) (*ExpiryResult, error) {
+
+	err := checkStaff(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	if pageSize <= 0 {
+		return nil, fmt.Errorf("page size must be a positive integer")
+	}
+
+	if bookmark != "" && !strings.HasPrefix(bookmark, membershipPrefix) {
+		return nil, fmt.Errorf("invalid bookmark %v", bookmark)
+	}
+
+	now, err := getTxTime(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("error:%v", err)
+	}
+
+	startKey := membershipPrefix
+	if bookmark != "" {
+		startKey = bookmark + "\x00"
+	}
+
+	membershipIterator, err := ctx.GetStub().GetStateByRange(startKey, membershipPrefix+string(utf8.MaxRune))
+	if err != nil {
+		return nil, fmt.Errorf("error:%v", err.Error())
+	}
+
+	// collect the page first, memberships and index entries are written while expiring
+	membershipIds := []string{}
+	for membershipIterator.HasNext() && len(membershipIds) < pageSize {
+		responseRange, err := membershipIterator.Next()
+		if err != nil {
+			membershipIterator.Close()
+			return nil, fmt.Errorf("error:%v", err.Error())
+		}
+
+		membershipIds = append(membershipIds, responseRange.Key)
+	}
+	hasMore := membershipIterator.HasNext()
+	membershipIterator.Close()
+
+	result := &ExpiryResult{
+		Expired:             []ExpiredMembership{},
+		FetchedRecordsCount: len(membershipIds),
+	}
+
+	if hasMore && len(membershipIds) > 0 {
+		result.Bookmark = membershipIds[len(membershipIds)-1]
+	}
+
+	for _, membershipId := range membershipIds {
+
+		membershipdetails, err := getMembership(ctx, membershipId)
+		if err != nil {
+			return nil, err
+		}
+
+		membershipendDate, _ := time.Parse(dateFormat, membershipdetails.EndDate)
+		if membershipdetails.Status != StatusActive || membershipdetails.AutoRenew || !now.After(membershipendDate) {
+			continue
+		}
+
+		userId := membershipdetails.UserID
+
+		err = transitionMembership(membershipdetails, StatusExpired, now, "membership ended")
+		if err != nil {
+			return nil, fmt.Errorf("error:%v", err)
+		}
+
+		err = putMembership(ctx, membershipId, membershipdetails)
+		if err != nil {
+			return nil, err
+		}
+
+		indexKey, err := ctx.GetStub().CreateCompositeKey(levelIndex, []string{membershipdetails.Level, userId})
+		if err != nil {
+			return nil, fmt.Errorf("failed to create the composite key for prefix %s: %v", levelIndex, err)
+		}
+
+		err = ctx.GetStub().DelState(indexKey)
+		if err != nil {
+			return nil, fmt.Errorf("error in del state for %v level composite key", membershipdetails.Level)
+		}
+
+		err = dissolveGroup(ctx, toUserKey(userId), "primary membership expired")
+		if err != nil {
+			return nil, err
+		}
+
+		err = emitMembershipExpired(ctx, membershipId, membershipdetails)
+		if err != nil {
+			return nil, err
+		}
+
+		result.Expired = append(result.Expired, ExpiredMembership{
+			MembershipID: membershipId,
+			UserID:       userId,
+			Level:        membershipdetails.Level,
+			EndDate:      membershipdetails.EndDate,
+		})
+
+		log.Printf("membership %v of %v expired on %v", membershipId, userId, membershipdetails.EndDate)
+	}
+
+	return result, nil
+}
@@ -0,0 +1,79 @@
+package healthclub
+
+import (
+	"testing"
+	"time"
+
+	"github.com/hyperledger/fabric-contract-api-go/contractapi"
+)
+
+func TestExpireMembershipsPagesFromBookmark(t *testing.T) {
+
+	c := newTestClub(t)
+	alice := c.member(t, "alice", 900)
+	bob := c.member(t, "bob", 4900)
+	carol := c.member(t, "carol", 900)
+
+	aliceMembership := c.buy(t, alice, goldlevel)
+	bobMembership := c.buy(t, bob, platinumlevel)
+	carolMembership := c.buy(t, carol, goldlevel)
+
+	c.after(40 * 24 * time.Hour)
+
+	expire := func(bookmark string) *ExpiryResult {
+		t.Helper()
+
+		var result *ExpiryResult
+		ctx := c.mustInvoke(t, c.staff, func(ctx contractapi.TransactionContextInterface) error {
+			var err error
+			result, err = c.h.ExpireMemberships(ctx, 2, bookmark)
+			return err
+		})
+
+		for _, name := range eventNames(ctx) {
+			if name != "MembershipExpired" {
+				t.Errorf("%v emitted by the sweep", name)
+			}
+		}
+		if len(eventNames(ctx)) != len(result.Expired) {
+			t.Errorf("%v events for %v expired memberships", len(eventNames(ctx)), len(result.Expired))
+		}
+
+		return result
+	}
+
+	first := expire("")
+	if first.FetchedRecordsCount != 2 || first.Bookmark != bobMembership {
+		t.Fatalf("first page scanned %v memberships up to %q, want 2 up to %v", first.FetchedRecordsCount, first.Bookmark, bobMembership)
+	}
+	if len(first.Expired) != 1 || first.Expired[0].MembershipID != aliceMembership {
+		t.Errorf("first page expired %+v, want %v", first.Expired, aliceMembership)
+	}
+
+	second := expire(first.Bookmark)
+	if second.FetchedRecordsCount != 1 || second.Bookmark != "" {
+		t.Errorf("last page scanned %v memberships with bookmark %q, want 1 without bookmark", second.FetchedRecordsCount, second.Bookmark)
+	}
+	if len(second.Expired) != 1 || second.Expired[0].MembershipID != carolMembership {
+		t.Errorf("last page expired %+v, want %v", second.Expired, carolMembership)
+	}
+
+	for membershipId, status := range map[string]string{aliceMembership: StatusExpired, bobMembership: StatusActive, carolMembership: StatusExpired} {
+		membership := c.membership(t, membershipId)
+		if membership.Status != status {
+			t.Errorf("membership %v is %v, want %v", membershipId, membership.Status, status)
+		}
+
+		indexKey := "\x00" + levelIndex + "\x00" + membership.Level + "\x00" + membership.UserID + "\x00"
+		if indexed := c.Get(indexKey) != nil; indexed != (status == StatusActive) {
+			t.Errorf("level index entry of %v present %v with the membership %v", membershipId, indexed, status)
+		}
+	}
+
+	if _, err := c.invoke(c.staff, func(ctx contractapi.TransactionContextInterface) error {
+		_, err := c.h.ExpireMemberships(ctx, 2, "User-alice")
+		return err
+	}); err == nil {
+		t.Errorf("sweep started at a bookmark outside the memberships")
+	}
+}
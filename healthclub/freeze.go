@@ -0,0 +1,243 @@
+package healthclub
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/hyperledger/fabric-contract-api-go/contractapi"
+)
+
+// FreezePeriod records a completed freeze of a membership
+type FreezePeriod struct {
+	StartDate string `json:"startdate"`
+	EndDate   string `json:"enddate"`
+	Days      int    `json:"days"`
+	Reason    string `json:"reason"`
+}
+
+// FreezeMembership suspends the current membership of the caller for up to the given number of days,
+// e.g. for medical or travel leave. Check-in is denied while the membership is frozen and the
+// EndDate is extended by the actual frozen duration on UnfreezeMembership, or by the requested days
+// once they have passed without UnfreezeMembership.
+// The total freeze days started in one calendar year on all memberships of the user cannot exceed the
+// MaxFreezeDays of the level, so buying a new membership does not reset the allowance.
+func (h *HealthClub) FreezeMembership(ctx contractapi.TransactionContextInterface, days int, reason string) (string, error) {
+
+	userid, err := ctx.GetClientIdentity().GetID()
+	if err != nil {
+		return "", fmt.Errorf("failed to get userID: %v", err)
+	}
+
+	if days <= 0 {
+		return "", fmt.Errorf("freeze days must be a positive integer")
+	}
+
+	if reason == "" {
+		return "", fmt.Errorf("freeze reason is required")
+	}
+
+	currentmembershipId, membershipdetails, err := getCurrentMembership(ctx, userPrefix+userid)
+	if err != nil {
+		return "", fmt.Errorf("error:%v", err)
+	}
+
+	now, err := getTxTime(ctx)
+	if err != nil {
+		return "", fmt.Errorf("error:%v", err)
+	}
+
+	if membershipdetails.Status == StatusFrozen {
+		return "", fmt.Errorf("membership is already frozen since %v", membershipdetails.FrozenFrom)
+	}
+
+	err = checkMembershipAccess(membershipdetails, now)
+	if err != nil {
+		return "", fmt.Errorf("cannot freeze membership: %v", err)
+	}
+
+	leveldetails, err := h.GetLevelDetails(ctx, membershipdetails.Level)
+	if err != nil {
+		return "", fmt.Errorf("error:%v", err)
+	}
+
+	usedDays, err := freezeDaysUsedInYear(ctx, userPrefix+userid, now.Year())
+	if err != nil {
+		return "", err
+	}
+
+	if usedDays+days > leveldetails.MaxFreezeDays {
+		return "", fmt.Errorf("%v membership allows %v freeze days per year, %v already used", membershipdetails.Level, leveldetails.MaxFreezeDays, usedDays)
+	}
+
+	err = transitionMembership(membershipdetails, StatusFrozen, now, reason)
+	if err != nil {
+		return "", fmt.Errorf("error:%v", err)
+	}
+
+	membershipdetails.FrozenFrom = now.Format(dateFormat)
+	membershipdetails.FrozenUntil = now.AddDate(0, 0, days).Format(dateFormat)
+	membershipdetails.FreezeReason = reason
+
+	err = putMembership(ctx, currentmembershipId, membershipdetails)
+	if err != nil {
+		return "", err
+	}
+
+	log.Printf("membership %v frozen from %v until %v", currentmembershipId, membershipdetails.FrozenFrom, membershipdetails.FrozenUntil)
+
+	return "Membership frozen until " + membershipdetails.FrozenUntil, nil
+}
+
+// UnfreezeMembership resumes the frozen membership of the caller and extends its EndDate by the
+// number of days it was actually frozen, capped at the number of days requested when freezing
+func (h *HealthClub) UnfreezeMembership(ctx contractapi.TransactionContextInterface) (string, error) {
+
+	userid, err := ctx.GetClientIdentity().GetID()
+	if err != nil {
+		return "", fmt.Errorf("failed to get userID: %v", err)
+	}
+
+	currentmembershipId, membershipdetails, err := getCurrentMembership(ctx, userPrefix+userid)
+	if err != nil {
+		return "", fmt.Errorf("error:%v", err)
+	}
+
+	if membershipdetails.Status != StatusFrozen {
+		return "", fmt.Errorf("membership is not frozen")
+	}
+
+	now, err := getTxTime(ctx)
+	if err != nil {
+		return "", fmt.Errorf("error:%v", err)
+	}
+
+	frozenFrom, _ := time.Parse(dateFormat, membershipdetails.FrozenFrom)
+	frozenUntil, _ := time.Parse(dateFormat, membershipdetails.FrozenUntil)
+
+	frozenDays := daysBetween(frozenFrom, now)
+	if plannedDays := daysBetween(frozenFrom, frozenUntil); frozenDays > plannedDays {
+		frozenDays = plannedDays
+	}
+	if frozenDays < 0 {
+		frozenDays = 0
+	}
+
+	err = resumeMembership(membershipdetails, now, frozenDays, "unfrozen")
+	if err != nil {
+		return "", fmt.Errorf("error:%v", err)
+	}
+
+	err = putMembership(ctx, currentmembershipId, membershipdetails)
+	if err != nil {
+		return "", err
+	}
+
+	log.Printf("membership %v unfrozen after %v days, new end date %v", currentmembershipId, frozenDays, membershipdetails.EndDate)
+
+	return "Membership unfrozen, new end date " + membershipdetails.EndDate, nil
+}
+
+// UpdateMembershipLevelFreezeDays sets the maximum number of freeze days per year for a level
+func (h *HealthClub) UpdateMembershipLevelFreezeDays(ctx contractapi.TransactionContextInterface, level string, maxFreezeDays int) (string, error) {
+
+	err := checkOwner(ctx)
+	if err != nil {
+		return "", err
+	}
+
+	if maxFreezeDays < 0 {
+		return "", fmt.Errorf("max freeze days cannot be negative")
+	}
+
+	if level != goldlevel && level != diamondlevel && level != platinumlevel {
+		return "", fmt.Errorf("only Gold, Diamond, and Platinum levels are acceptable")
+	}
+
+	leveldetails, err := h.GetLevelDetails(ctx, level)
+	if err != nil {
+		return "", fmt.Errorf("error:%v", err)
+	}
+
+	leveldetails.MaxFreezeDays = maxFreezeDays
+
+	resInBytes, _ := json.Marshal(leveldetails)
+	err = ctx.GetStub().PutState(level, resInBytes)
+	if err != nil {
+		return "", fmt.Errorf("error:%v", err)
+	}
+
+	log.Printf("The %v level is updated to %v freeze days per year", level, maxFreezeDays)
+
+	return "level is updated", nil
+}
+
+// resumeMembership ends the freeze of a membership on the given date, records the freeze and extends the EndDate by frozenDays
+func resumeMembership(membership *Membership, resumedOn time.Time, frozenDays int, reason string) error {
+
+	membershipendDate, _ := time.Parse(dateFormat, membership.EndDate)
+	membership.EndDate = membershipendDate.AddDate(0, 0, frozenDays).Format(dateFormat)
+
+	membership.Freezes = append(membership.Freezes, FreezePeriod{
+		StartDate: membership.FrozenFrom,
+		EndDate:   resumedOn.Format(dateFormat),
+		Days:      frozenDays,
+		Reason:    membership.FreezeReason,
+	})
+
+	err := transitionMembership(membership, StatusActive, resumedOn, reason)
+	if err != nil {
+		return err
+	}
+
+	membership.FrozenFrom = ""
+	membership.FrozenUntil = ""
+	membership.FreezeReason = ""
+
+	return nil
+}
+
+// endElapsedFreeze resumes a frozen membership whose FrozenUntil has passed, as if it was unfrozen on FrozenUntil
+// with the EndDate extended by the planned freeze days. Members who forget UnfreezeMembership are not locked out.
+func endElapsedFreeze(membership *Membership, now time.Time) error {
+
+	if membership.Status != StatusFrozen {
+		return nil
+	}
+
+	frozenUntil, err := time.Parse(dateFormat, membership.FrozenUntil)
+	if err != nil || !now.After(frozenUntil) {
+		return nil
+	}
+
+	frozenFrom, _ := time.Parse(dateFormat, membership.FrozenFrom)
+
+	return resumeMembership(membership, frozenUntil, daysBetween(frozenFrom, frozenUntil), "freeze period ended")
+}
+
+// freezeDaysUsedInYear sums the freeze days of all freezes started in the given calendar year on any membership of the user
+func freezeDaysUsedInYear(ctx contractapi.TransactionContextInterface, userId string, year int) (int, error) {
+
+	userptr, err := getUser(ctx, userId)
+	if err != nil {
+		return 0, err
+	}
+
+	usedDays := 0
+	for _, membershipId := range userptr.Memberships {
+		membershipdetails, err := getMembership(ctx, membershipId)
+		if err != nil {
+			return 0, err
+		}
+
+		for _, freeze := range membershipdetails.Freezes {
+			freezeStart, err := time.Parse(dateFormat, freeze.StartDate)
+			if err == nil && freezeStart.Year() == year {
+				usedDays += freeze.Days
+			}
+		}
+	}
+
+	return usedDays, nil
+}
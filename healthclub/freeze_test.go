@@ -0,0 +1,44 @@
+package healthclub
+
+import (
+	"testing"
+	"time"
+
+	"github.com/hyperledger/fabric-contract-api-go/contractapi"
+)
+
+func TestFreezeAllowanceSpansMembershipsOfTheYear(t *testing.T) {
+
+	c := newTestClub(t)
+	alice := c.member(t, "alice", 1900)
+	c.buy(t, alice, goldlevel)
+
+	// Gold allows 7 freeze days per year, 4 are used on the first membership
+	c.mustInvoke(t, alice, func(ctx contractapi.TransactionContextInterface) error {
+		_, err := c.h.FreezeMembership(ctx, 5, "travel")
+		return err
+	})
+	c.after(4 * 24 * time.Hour)
+	c.mustInvoke(t, alice, func(ctx contractapi.TransactionContextInterface) error {
+		_, err := c.h.UnfreezeMembership(ctx)
+		return err
+	})
+
+	c.after(35 * 24 * time.Hour)
+	membershipId := c.buy(t, alice, goldlevel)
+
+	if _, err := c.invoke(alice, func(ctx contractapi.TransactionContextInterface) error {
+		_, err := c.h.FreezeMembership(ctx, 4, "travel")
+		return err
+	}); err == nil {
+		t.Errorf("new membership frozen beyond the allowance of the year")
+	}
+
+	c.mustInvoke(t, alice, func(ctx contractapi.TransactionContextInterface) error {
+		_, err := c.h.FreezeMembership(ctx, 3, "travel")
+		return err
+	})
+	if status := c.membership(t, membershipId).Status; status != StatusFrozen {
+		t.Errorf("membership is %v, want %v", status, StatusFrozen)
+	}
+}
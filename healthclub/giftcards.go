@@ -0,0 +1,313 @@
+package healthclub
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+
+	"github.com/hyperledger/fabric-contract-api-go/contractapi"
+	erc20 "github.com/varun425/MiniClubChaincode/erc20"
+	"github.com/varun425/MiniClubChaincode/events"
+)
+
+const (
+	giftCardIndex         = "giftcard~CodeHash"
+	giftCardEscrowAccount = "HealthClubGiftCardEscrow"
+	giftCardActive        = "Active"
+	giftCardRedeemed      = "Redeemed"
+	giftCardReclaimed     = "Reclaimed"
+)
+
+// GiftCard holds Amount tokens in escrow until redeemed with the secret code whose sha256 hex digest is CodeHash
+// A card with a Level can also be redeemed for a membership of that level. Unredeemed value returns to the buyer after ExpiresOn
+type GiftCard struct {
+	CodeHash   string `json:"codehash"`
+	BuyerID    string `json:"buyerid"`
+	Amount     int    `json:"amount"`
+	Level      string `json:"level"`
+	CreatedOn  string `json:"createdon"`
+	ExpiresOn  string `json:"expireson"`
+	Status     string `json:"status"`
+	RedeemedBy string `json:"redeemedby"`
+	RedeemedOn string `json:"redeemedon"`
+}
+
+// BuyGiftCard locks amount tokens of the caller into a gift card for validDays days
+// codeHash is the sha256 hex digest of the secret code, which is shared with the recipient off-chain and never stored.
+// level is optional and lets the recipient redeem the card for a membership of that level
+func (h *HealthClub) BuyGiftCard(ctx contractapi.TransactionContextInterface, codeHash string, amount int, level string, validDays int) (string, error) {
+
+	userid, err := ctx.GetClientIdentity().GetID()
+	if err != nil {
+		return "", fmt.Errorf("failed to get userID: %v", err)
+	}
+
+	codeHash = strings.ToLower(codeHash)
+	if _, err := hex.DecodeString(codeHash); err != nil || len(codeHash) != sha256.Size*2 {
+		return "", fmt.Errorf("code hash must be a sha256 hex digest")
+	}
+
+	if amount <= 0 {
+		return "", fmt.Errorf("amount must be a positive integer")
+	}
+
+	if validDays <= 0 {
+		return "", fmt.Errorf("valid days must be a positive integer")
+	}
+
+	if level != "" && level != goldlevel && level != diamondlevel && level != platinumlevel {
+		return "", fmt.Errorf("only Gold, Diamond, and Platinum levels are acceptable")
+	}
+
+	if _, err := getGiftCard(ctx, codeHash); err == nil {
+		return "", fmt.Errorf("gift card already exists, use another code")
+	}
+
+	now, err := getTxTime(ctx)
+	if err != nil {
+		return "", fmt.Errorf("error:%v", err)
+	}
+
+	giftCard := &GiftCard{
+		CodeHash:  codeHash,
+		BuyerID:   userid,
+		Amount:    amount,
+		Level:     level,
+		CreatedOn: now.Format(dateFormat),
+		ExpiresOn: now.AddDate(0, 0, validDays).Format(dateFormat),
+		Status:    giftCardActive,
+	}
+
+	err = putGiftCard(ctx, giftCard)
+	if err != nil {
+		return "", err
+	}
+
+	// hold the value in escrow
+	err = h.Transfer(ctx, giftCardEscrowAccount, amount)
+	if err != nil {
+		return "", fmt.Errorf("err: %v", err)
+	}
+
+	log.Printf("gift card of %v tokens bought, expires on %v", amount, giftCard.ExpiresOn)
+
+	return "gift card is created", nil
+}
+
+// RedeemGiftCard pays the value of the gift card with the given secret code to the caller
+func (h *HealthClub) RedeemGiftCard(ctx contractapi.TransactionContextInterface, code string) (*GiftCard, error) {
+
+	userid, err := ctx.GetClientIdentity().GetID()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get userID: %v", err)
+	}
+
+	giftCard, err := redeemGiftCard(ctx, code, userid)
+	if err != nil {
+		return nil, err
+	}
+
+	batch := erc20.NewTransferBatch(ctx)
+	err = batch.Transfer(giftCardEscrowAccount, userid, giftCard.Amount)
+	if err != nil {
+		return nil, fmt.Errorf("failed to release gift card: %v", err)
+	}
+
+	err = batch.Commit()
+	if err != nil {
+		return nil, fmt.Errorf("error:%v", err)
+	}
+
+	err = emitEvent(ctx, events.GiftCardRedeemed, giftCard)
+	if err != nil {
+		return nil, err
+	}
+
+	return giftCard, nil
+}
+
+// RedeemGiftCardForMembership buys the caller a membership of the level of the gift card with the given secret code
+// The card pays the price, the caller pays any shortfall and receives any value left on the card
+func (h *HealthClub) RedeemGiftCardForMembership(ctx contractapi.TransactionContextInterface, code string) (string, error) {
+
+	userid, err := ctx.GetClientIdentity().GetID()
+	if err != nil {
+		return "", fmt.Errorf("failed to get userID: %v", err)
+	}
+
+	giftCard, err := redeemGiftCard(ctx, code, userid)
+	if err != nil {
+		return "", err
+	}
+
+	if giftCard.Level == "" {
+		return "", fmt.Errorf("gift card is not valid for a membership, use RedeemGiftCard")
+	}
+
+	return h.getNewMemberShip(ctx, giftCard.Level, purchaseOptions{GiftCard: giftCard})
+}
+
+// ReclaimGiftCard returns the value of an expired, unredeemed gift card to the buyer
+func (h *HealthClub) ReclaimGiftCard(ctx contractapi.TransactionContextInterface, codeHash string) (*GiftCard, error) {
+
+	userid, err := ctx.GetClientIdentity().GetID()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get userID: %v", err)
+	}
+
+	giftCard, err := getGiftCard(ctx, strings.ToLower(codeHash))
+	if err != nil {
+		return nil, err
+	}
+
+	if giftCard.BuyerID != userid {
+		return nil, fmt.Errorf("only the buyer can reclaim a gift card")
+	}
+
+	if giftCard.Status != giftCardActive {
+		return nil, fmt.Errorf("gift card is %v", giftCard.Status)
+	}
+
+	now, err := getTxTime(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("error:%v", err)
+	}
+
+	expiresOn, _ := time.Parse(dateFormat, giftCard.ExpiresOn)
+	if now.Before(expiresOn) {
+		return nil, fmt.Errorf("gift card can be reclaimed after it expires on %v", giftCard.ExpiresOn)
+	}
+
+	giftCard.Status = giftCardReclaimed
+
+	err = putGiftCard(ctx, giftCard)
+	if err != nil {
+		return nil, err
+	}
+
+	batch := erc20.NewTransferBatch(ctx)
+	err = batch.Transfer(giftCardEscrowAccount, userid, giftCard.Amount)
+	if err != nil {
+		return nil, fmt.Errorf("failed to release gift card: %v", err)
+	}
+
+	err = batch.Commit()
+	if err != nil {
+		return nil, fmt.Errorf("error:%v", err)
+	}
+
+	return giftCard, nil
+}
+
+// GetGiftCard returns the gift card with the given code hash
+func (h *HealthClub) GetGiftCard(ctx contractapi.TransactionContextInterface, codeHash string) (*GiftCard, error) {
+	return getGiftCard(ctx, strings.ToLower(codeHash))
+}
+
+// redeemGiftCard verifies the secret code against the stored hash and marks the active, unexpired card redeemed by the user
+func redeemGiftCard(ctx contractapi.TransactionContextInterface, code string, userid string) (*GiftCard, error) {
+
+	digest := sha256.Sum256([]byte(code))
+
+	giftCard, err := getGiftCard(ctx, hex.EncodeToString(digest[:]))
+	if err != nil {
+		return nil, fmt.Errorf("invalid gift card code")
+	}
+
+	if giftCard.Status != giftCardActive {
+		return nil, fmt.Errorf("gift card is %v", giftCard.Status)
+	}
+
+	now, err := getTxTime(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("error:%v", err)
+	}
+
+	expiresOn, _ := time.Parse(dateFormat, giftCard.ExpiresOn)
+	if !now.Before(expiresOn) {
+		return nil, fmt.Errorf("gift card expired on %v", giftCard.ExpiresOn)
+	}
+
+	giftCard.Status = giftCardRedeemed
+	giftCard.RedeemedBy = userid
+	giftCard.RedeemedOn = now.Format(dateFormat)
+
+	err = putGiftCard(ctx, giftCard)
+	if err != nil {
+		return nil, err
+	}
+
+	log.Printf("gift card of %v tokens redeemed by %v", giftCard.Amount, userid)
+
+	return giftCard, nil
+}
+
+// payWithGiftCard adds the payment of up to price tokens from the escrowed value of the gift card to the payee account,
+// and any value left to the recipient, to the batch. Returns the tokens paid towards the price
+func payWithGiftCard(batch *erc20.TransferBatch, giftCard *GiftCard, recipient string, payee string, price int) (int, error) {
+
+	paid := giftCard.Amount
+	if paid > price {
+		paid = price
+	}
+
+	if paid > 0 {
+		err := batch.Transfer(giftCardEscrowAccount, payee, paid)
+		if err != nil {
+			return 0, fmt.Errorf("failed to release gift card: %v", err)
+		}
+	}
+
+	if giftCard.Amount > paid {
+		err := batch.Transfer(giftCardEscrowAccount, recipient, giftCard.Amount-paid)
+		if err != nil {
+			return 0, fmt.Errorf("failed to release gift card: %v", err)
+		}
+	}
+
+	return paid, nil
+}
+
+// getGiftCard reads a gift card from the world state
+func getGiftCard(ctx contractapi.TransactionContextInterface, codeHash string) (*GiftCard, error) {
+
+	giftCardKey, err := ctx.GetStub().CreateCompositeKey(giftCardIndex, []string{codeHash})
+	if err != nil {
+		return nil, fmt.Errorf("failed to create the composite key for prefix %s: %v", giftCardIndex, err)
+	}
+
+	giftCardbytes, err := ctx.GetStub().GetState(giftCardKey)
+	if err != nil {
+		return nil, fmt.Errorf("error:%v", err)
+	}
+
+	if giftCardbytes == nil {
+		return nil, fmt.Errorf("gift card not found")
+	}
+
+	giftCard := new(GiftCard)
+	_ = json.Unmarshal(giftCardbytes, &giftCard)
+
+	return giftCard, nil
+}
+
+// putGiftCard writes a gift card to the world state
+func putGiftCard(ctx contractapi.TransactionContextInterface, giftCard *GiftCard) error {
+
+	giftCardKey, err := ctx.GetStub().CreateCompositeKey(giftCardIndex, []string{giftCard.CodeHash})
+	if err != nil {
+		return fmt.Errorf("failed to create the composite key for prefix %s: %v", giftCardIndex, err)
+	}
+
+	giftCardbytes, _ := json.Marshal(giftCard)
+	err = ctx.GetStub().PutState(giftCardKey, giftCardbytes)
+	if err != nil {
+		return fmt.Errorf("error:%v", err)
+	}
+
+	return nil
+}
@@ -0,0 +1,99 @@
+package healthclub
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"testing"
+	"time"
+
+	"github.com/hyperledger/fabric-contract-api-go/contractapi"
+	"github.com/varun425/MiniClubChaincode/internal/chaincodetest"
+)
+
+// buyGiftCard buys a gift card with the secret code for the buyer
+func buyGiftCard(t *testing.T, c *testClub, buyer *chaincodetest.Identity, code string, amount int, level string, validDays int) {
+
+	t.Helper()
+
+	digest := sha256.Sum256([]byte(code))
+	c.mustInvoke(t, buyer, func(ctx contractapi.TransactionContextInterface) error {
+		_, err := c.h.BuyGiftCard(ctx, hex.EncodeToString(digest[:]), amount, level, validDays)
+		return err
+	})
+}
+
+func TestRedeemGiftCardForMembershipOfReferredMember(t *testing.T) {
+
+	c := newTestClub(t)
+	c.mustInvoke(t, c.owner, func(ctx contractapi.TransactionContextInterface) error {
+		return c.h.Mint(ctx, 1000)
+	})
+
+	alice := c.member(t, "alice", 0)
+	bob := chaincodetest.NewIdentity(clientID("bob"), nil)
+	c.mustInvoke(t, bob, func(ctx contractapi.TransactionContextInterface) error {
+		_, err := c.h.RegisterUserWithReferral(ctx, "bob", "bob@example.com", alice.ID)
+		return err
+	})
+
+	carol := c.member(t, "carol", 1100)
+	buyGiftCard(t, c, carol, "happy birthday bob", 1200, goldlevel, 30)
+
+	ctx := c.mustInvoke(t, bob, func(ctx contractapi.TransactionContextInterface) error {
+		_, err := c.h.RedeemGiftCardForMembership(ctx, "happy birthday bob")
+		return err
+	})
+
+	// the card pays the price, bob gets the rest and alice the referral bonus
+	c.assertBalances(t, map[string]int{
+		alice.ID:              300,
+		bob.ID:                300,
+		carol.ID:              0,
+		giftCardEscrowAccount: 0,
+		c.owner.ID:            1800,
+	})
+
+	// every part of the purchase has its own event
+	emitted := map[string]bool{}
+	for _, name := range eventNames(ctx) {
+		emitted[name] = true
+	}
+	for _, name := range []string{"ReferralBonus", "GiftCardRedeemed", "MembershipCreated", "Transfer"} {
+		if !emitted[name] {
+			t.Errorf("%v not emitted, events %v", name, eventNames(ctx))
+		}
+	}
+}
+
+func TestReclaimGiftCard(t *testing.T) {
+
+	c := newTestClub(t)
+
+	carol := c.member(t, "carol", 400)
+	buyGiftCard(t, c, carol, "for anyone", 500, "", 10)
+	c.assertBalances(t, map[string]int{carol.ID: 0, giftCardEscrowAccount: 500})
+
+	digest := sha256.Sum256([]byte("for anyone"))
+	reclaim := func(ctx contractapi.TransactionContextInterface) error {
+		_, err := c.h.ReclaimGiftCard(ctx, hex.EncodeToString(digest[:]))
+		return err
+	}
+
+	if _, err := c.invoke(carol, reclaim); err == nil {
+		t.Fatalf("gift card reclaimed before it expired")
+	}
+
+	c.after(10 * 24 * time.Hour)
+	c.mustInvoke(t, carol, reclaim)
+	c.assertBalances(t, map[string]int{carol.ID: 500, giftCardEscrowAccount: 0})
+
+	bob := c.member(t, "bob", 0)
+	_, err := c.invoke(bob, func(ctx contractapi.TransactionContextInterface) error {
+		_, err := c.h.RedeemGiftCard(ctx, "for anyone")
+		return err
+	})
+	if err == nil {
+		t.Errorf("reclaimed gift card redeemed")
+	}
+	c.assertBalances(t, map[string]int{bob.ID: 100})
+}
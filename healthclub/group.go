@@ -0,0 +1,416 @@
+package healthclub
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+
+	"github.com/hyperledger/fabric-contract-api-go/contractapi"
+)
+
+const groupIndex = "group~PrimaryID"
+
+// MembershipGroup lets the primary holder of a membership share it with dependents, e.g. a family on one Diamond plan
+// Dependents get access while the current membership of the primary grants access, the group counts the primary
+// and its size is limited by the MaxGroupSize of the level of that membership
+type MembershipGroup struct {
+	PrimaryID  string   `json:"primaryid"`
+	Dependents []string `json:"dependents"`
+	Invites    []string `json:"invites"`
+	CreatedOn  string   `json:"createdon"`
+}
+
+// CreateMembershipGroup turns the current membership of the caller into a group membership the caller can invite dependents to
+func (h *HealthClub) CreateMembershipGroup(ctx contractapi.TransactionContextInterface) (string, error) {
+
+	userid, err := ctx.GetClientIdentity().GetID()
+	if err != nil {
+		return "", fmt.Errorf("failed to get userID: %v", err)
+	}
+
+	primaryId := userPrefix + userid
+
+	userptr, err := getUser(ctx, primaryId)
+	if err != nil {
+		return "", err
+	}
+
+	if userptr.GroupOf != "" {
+		return "", fmt.Errorf("dependents of %v cannot create a group", userptr.GroupOf)
+	}
+
+	if _, err := getGroup(ctx, primaryId); err == nil {
+		return "", fmt.Errorf("group of %v already exists", primaryId)
+	}
+
+	_, membershipdetails, err := getCurrentMembership(ctx, primaryId)
+	if err != nil {
+		return "", fmt.Errorf("error:%v", err)
+	}
+
+	now, err := getTxTime(ctx)
+	if err != nil {
+		return "", fmt.Errorf("error:%v", err)
+	}
+
+	err = checkMembershipAccess(membershipdetails, now)
+	if err != nil {
+		return "", fmt.Errorf("cannot create group: %v", err)
+	}
+
+	leveldetails, err := h.GetLevelDetails(ctx, membershipdetails.Level)
+	if err != nil {
+		return "", fmt.Errorf("error:%v", err)
+	}
+
+	if leveldetails.MaxGroupSize <= 1 {
+		return "", fmt.Errorf("%v memberships cannot be shared", membershipdetails.Level)
+	}
+
+	group := &MembershipGroup{
+		PrimaryID:  primaryId,
+		Dependents: []string{},
+		Invites:    []string{},
+		CreatedOn:  now.Format(dateFormat),
+	}
+
+	err = putGroup(ctx, group)
+	if err != nil {
+		return "", err
+	}
+
+	log.Printf("%v created a group for up to %v people", primaryId, leveldetails.MaxGroupSize)
+
+	return "group is created", nil
+}
+
+// InviteDependent invites a registered user to the group of the caller, the user joins on AcceptGroupInvite
+func (h *HealthClub) InviteDependent(ctx contractapi.TransactionContextInterface, userId string) (string, error) {
+
+	userid, err := ctx.GetClientIdentity().GetID()
+	if err != nil {
+		return "", fmt.Errorf("failed to get userID: %v", err)
+	}
+
+	primaryId := userPrefix + userid
+	dependentId := toUserKey(userId)
+
+	if dependentId == primaryId {
+		return "", fmt.Errorf("cannot invite yourself")
+	}
+
+	group, err := getGroup(ctx, primaryId)
+	if err != nil {
+		return "", err
+	}
+
+	dependent, err := getUser(ctx, dependentId)
+	if err != nil {
+		return "", err
+	}
+
+	if dependent.GroupOf != "" {
+		return "", fmt.Errorf("%v is already a dependent of %v", dependentId, dependent.GroupOf)
+	}
+
+	if containsUser(group.Invites, dependentId) || containsUser(group.Dependents, dependentId) {
+		return "", fmt.Errorf("%v is already invited", dependentId)
+	}
+
+	if _, err := getGroup(ctx, dependentId); err == nil {
+		return "", fmt.Errorf("%v holds a group membership", dependentId)
+	}
+
+	_, membershipdetails, err := getCurrentMembership(ctx, primaryId)
+	if err != nil {
+		return "", fmt.Errorf("error:%v", err)
+	}
+
+	leveldetails, err := h.GetLevelDetails(ctx, membershipdetails.Level)
+	if err != nil {
+		return "", fmt.Errorf("error:%v", err)
+	}
+
+	// invites count towards the group size so accepting can never exceed it
+	if 1+len(group.Dependents)+len(group.Invites)+1 > leveldetails.MaxGroupSize {
+		return "", fmt.Errorf("%v memberships cover up to %v people", membershipdetails.Level, leveldetails.MaxGroupSize)
+	}
+
+	group.Invites = append(group.Invites, dependentId)
+
+	err = putGroup(ctx, group)
+	if err != nil {
+		return "", err
+	}
+
+	return "dependent is invited", nil
+}
+
+// AcceptGroupInvite joins the caller to the group of the primary holder that invited the caller
+func (h *HealthClub) AcceptGroupInvite(ctx contractapi.TransactionContextInterface, primaryId string) (string, error) {
+
+	userid, err := ctx.GetClientIdentity().GetID()
+	if err != nil {
+		return "", fmt.Errorf("failed to get userID: %v", err)
+	}
+
+	dependentId := userPrefix + userid
+	primaryId = toUserKey(primaryId)
+
+	group, err := getGroup(ctx, primaryId)
+	if err != nil {
+		return "", err
+	}
+
+	if !containsUser(group.Invites, dependentId) {
+		return "", fmt.Errorf("no invite from %v", primaryId)
+	}
+
+	dependent, err := getUser(ctx, dependentId)
+	if err != nil {
+		return "", err
+	}
+
+	if dependent.GroupOf != "" {
+		return "", fmt.Errorf("already a dependent of %v", dependent.GroupOf)
+	}
+
+	group.Invites = removeUser(group.Invites, dependentId)
+	group.Dependents = append(group.Dependents, dependentId)
+	dependent.GroupOf = primaryId
+
+	err = putGroup(ctx, group)
+	if err != nil {
+		return "", err
+	}
+
+	err = putUser(ctx, dependentId, dependent)
+	if err != nil {
+		return "", err
+	}
+
+	log.Printf("%v joined the group of %v", dependentId, primaryId)
+
+	return "joined group", nil
+}
+
+// RemoveDependent removes a dependent or a pending invite from the group of the caller
+func (h *HealthClub) RemoveDependent(ctx contractapi.TransactionContextInterface, userId string) (string, error) {
+
+	userid, err := ctx.GetClientIdentity().GetID()
+	if err != nil {
+		return "", fmt.Errorf("failed to get userID: %v", err)
+	}
+
+	err = removeFromGroup(ctx, userPrefix+userid, toUserKey(userId))
+	if err != nil {
+		return "", err
+	}
+
+	return "dependent is removed", nil
+}
+
+// LeaveGroup removes the caller from the group of the primary holder, also declining a pending invite
+func (h *HealthClub) LeaveGroup(ctx contractapi.TransactionContextInterface, primaryId string) (string, error) {
+
+	userid, err := ctx.GetClientIdentity().GetID()
+	if err != nil {
+		return "", fmt.Errorf("failed to get userID: %v", err)
+	}
+
+	err = removeFromGroup(ctx, toUserKey(primaryId), userPrefix+userid)
+	if err != nil {
+		return "", err
+	}
+
+	return "left group", nil
+}
+
+// GetMembershipGroup returns the group of the primary holder with its dependents and pending invites
+func (h *HealthClub) GetMembershipGroup(ctx contractapi.TransactionContextInterface, primaryId string) (*MembershipGroup, error) {
+	return getGroup(ctx, toUserKey(primaryId))
+}
+
+// UpdateMembershipLevelGroupSize sets how many people, the primary holder included, can share a membership of the level
+func (h *HealthClub) UpdateMembershipLevelGroupSize(ctx contractapi.TransactionContextInterface, level string, maxGroupSize int) (string, error) {
+
+	err := checkOwner(ctx)
+	if err != nil {
+		return "", err
+	}
+
+	if maxGroupSize < 1 {
+		return "", fmt.Errorf("max group size must be at least 1")
+	}
+
+	if level != goldlevel && level != diamondlevel && level != platinumlevel {
+		return "", fmt.Errorf("only Gold, Diamond, and Platinum levels are acceptable")
+	}
+
+	leveldetails, err := h.GetLevelDetails(ctx, level)
+	if err != nil {
+		return "", fmt.Errorf("error:%v", err)
+	}
+
+	leveldetails.MaxGroupSize = maxGroupSize
+
+	resInBytes, _ := json.Marshal(leveldetails)
+	err = ctx.GetStub().PutState(level, resInBytes)
+	if err != nil {
+		return "", fmt.Errorf("error:%v", err)
+	}
+
+	log.Printf("The %v level is updated to %v people per membership", level, maxGroupSize)
+
+	return "level is updated", nil
+}
+
+// getAccessMembership returns the membership granting the user access: the own current membership if active,
+// otherwise the current membership of the primary holder of the group the user is a dependent of
+func getAccessMembership(ctx contractapi.TransactionContextInterface, userId string) (string, *Membership, error) {
+
+	membershipId, membership, err := getCurrentMembership(ctx, userId)
+	if err == nil && membership.Status == StatusActive {
+		return membershipId, membership, nil
+	}
+
+	userptr, userErr := getUser(ctx, userId)
+	if userErr != nil || userptr.GroupOf == "" {
+		return membershipId, membership, err
+	}
+
+	return getCurrentMembership(ctx, userptr.GroupOf)
+}
+
+// checkGroupFits returns an error if the group of the primary holder, if any, has more people than maxGroupSize
+func checkGroupFits(ctx contractapi.TransactionContextInterface, primaryId string, maxGroupSize int) error {
+
+	group, err := getGroup(ctx, primaryId)
+	if err != nil {
+		// no group, a single holder fits every level
+		return nil
+	}
+
+	size := 1 + len(group.Dependents) + len(group.Invites)
+	if size > maxGroupSize {
+		return fmt.Errorf("group has %v people but the level covers up to %v, remove dependents first", size, maxGroupSize)
+	}
+
+	return nil
+}
+
+// removeFromGroup removes a dependent or a pending invite from the group of the primary holder
+func removeFromGroup(ctx contractapi.TransactionContextInterface, primaryId string, dependentId string) error {
+
+	group, err := getGroup(ctx, primaryId)
+	if err != nil {
+		return err
+	}
+
+	if containsUser(group.Invites, dependentId) {
+		group.Invites = removeUser(group.Invites, dependentId)
+		return putGroup(ctx, group)
+	}
+
+	if !containsUser(group.Dependents, dependentId) {
+		return fmt.Errorf("%v is not in the group of %v", dependentId, primaryId)
+	}
+
+	group.Dependents = removeUser(group.Dependents, dependentId)
+
+	err = putGroup(ctx, group)
+	if err != nil {
+		return err
+	}
+
+	dependent, err := getUser(ctx, dependentId)
+	if err != nil {
+		return err
+	}
+
+	dependent.GroupOf = ""
+
+	return putUser(ctx, dependentId, dependent)
+}
+
+// dissolveGroup ends the group of the primary holder, if any, once the shared membership is cancelled or expired,
+// removing the access of every dependent
+func dissolveGroup(ctx contractapi.TransactionContextInterface, primaryId string, reason string) error {
+
+	group, err := getGroup(ctx, primaryId)
+	if err != nil {
+		return nil
+	}
+
+	for _, dependentId := range group.Dependents {
+		dependent, err := getUser(ctx, dependentId)
+		if err != nil {
+			return err
+		}
+
+		if dependent.GroupOf != primaryId {
+			continue
+		}
+
+		dependent.GroupOf = ""
+
+		err = putUser(ctx, dependentId, dependent)
+		if err != nil {
+			return err
+		}
+	}
+
+	groupKey, err := ctx.GetStub().CreateCompositeKey(groupIndex, []string{primaryId})
+	if err != nil {
+		return fmt.Errorf("failed to create the composite key for prefix %s: %v", groupIndex, err)
+	}
+
+	err = ctx.GetStub().DelState(groupKey)
+	if err != nil {
+		return fmt.Errorf("error:%v", err)
+	}
+
+	log.Printf("group of %v dissolved with %v dependents: %v", primaryId, len(group.Dependents), reason)
+
+	return nil
+}
+
+// getGroup reads the group of the primary holder from the world state
+func getGroup(ctx contractapi.TransactionContextInterface, primaryId string) (*MembershipGroup, error) {
+
+	groupKey, err := ctx.GetStub().CreateCompositeKey(groupIndex, []string{primaryId})
+	if err != nil {
+		return nil, fmt.Errorf("failed to create the composite key for prefix %s: %v", groupIndex, err)
+	}
+
+	groupbytes, err := ctx.GetStub().GetState(groupKey)
+	if err != nil {
+		return nil, fmt.Errorf("error:%v", err)
+	}
+
+	if groupbytes == nil {
+		return nil, fmt.Errorf("group of %v not found", primaryId)
+	}
+
+	group := new(MembershipGroup)
+	_ = json.Unmarshal(groupbytes, &group)
+
+	return group, nil
+}
+
+// putGroup writes a group to the world state
+func putGroup(ctx contractapi.TransactionContextInterface, group *MembershipGroup) error {
+
+	groupKey, err := ctx.GetStub().CreateCompositeKey(groupIndex, []string{group.PrimaryID})
+	if err != nil {
+		return fmt.Errorf("failed to create the composite key for prefix %s: %v", groupIndex, err)
+	}
+
+	groupbytes, _ := json.Marshal(group)
+	err = ctx.GetStub().PutState(groupKey, groupbytes)
+	if err != nil {
+		return fmt.Errorf("error:%v", err)
+	}
+
+	return nil
+}
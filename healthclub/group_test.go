@@ -0,0 +1,163 @@
+package healthclub
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/hyperledger/fabric-contract-api-go/contractapi"
+	"github.com/varun425/MiniClubChaincode/internal/chaincodetest"
+)
+
+// newGroup buys a Diamond membership for the primary holder and creates its group
+func newGroup(t *testing.T, c *testClub) *chaincodetest.Identity {
+
+	t.Helper()
+
+	primary := c.member(t, "pat", 7900)
+	c.buy(t, primary, diamondlevel)
+	c.mustInvoke(t, primary, func(ctx contractapi.TransactionContextInterface) error {
+		_, err := c.h.CreateMembershipGroup(ctx)
+		return err
+	})
+
+	return primary
+}
+
+// invite invites the user to the group of the primary holder and returns the error of the transaction
+func invite(c *testClub, primary *chaincodetest.Identity, user *chaincodetest.Identity) error {
+
+	_, err := c.invoke(primary, func(ctx contractapi.TransactionContextInterface) error {
+		_, err := c.h.InviteDependent(ctx, user.ID)
+		return err
+	})
+
+	return err
+}
+
+// acceptInvite accepts the invite of the primary holder as the user and returns the error of the transaction
+func acceptInvite(c *testClub, user *chaincodetest.Identity, primary *chaincodetest.Identity) error {
+
+	_, err := c.invoke(user, func(ctx contractapi.TransactionContextInterface) error {
+		_, err := c.h.AcceptGroupInvite(ctx, primary.ID)
+		return err
+	})
+
+	return err
+}
+
+func TestGroupInvitesAreAcceptedWithinTheGroupSize(t *testing.T) {
+
+	c := newTestClub(t)
+
+	gold := c.member(t, "gil", 900)
+	c.buy(t, gold, goldlevel)
+	_, err := c.invoke(gold, func(ctx contractapi.TransactionContextInterface) error {
+		_, err := c.h.CreateMembershipGroup(ctx)
+		return err
+	})
+	if err == nil {
+		t.Errorf("Gold membership shared")
+	}
+
+	pat := newGroup(t, c)
+	ann := c.member(t, "ann", 0)
+	ben := c.member(t, "ben", 0)
+	cat := c.member(t, "cat", 0)
+	dan := c.member(t, "dan", 0)
+
+	// Diamond covers the primary holder and 3 dependents, pending invites included
+	for _, user := range []*chaincodetest.Identity{ann, ben, cat} {
+		if err := invite(c, pat, user); err != nil {
+			t.Fatalf("%v not invited: %v", user.ID, err)
+		}
+	}
+	if err := invite(c, pat, dan); err == nil {
+		t.Errorf("invited beyond the group size")
+	}
+	if err := acceptInvite(c, dan, pat); err == nil {
+		t.Errorf("user joined without invite")
+	}
+
+	if err := acceptInvite(c, ann, pat); err != nil {
+		t.Fatal(err)
+	}
+
+	// dependents enter with the membership of the primary holder, invites do not
+	if err := checkIn(c, ann, "gym"); err != nil {
+		t.Errorf("dependent not checked in: %v", err)
+	}
+	if err := checkIn(c, ben, "gym"); err == nil {
+		t.Errorf("invited user checked in before accepting")
+	}
+
+	// removing a pending invite frees its place
+	c.mustInvoke(t, pat, func(ctx contractapi.TransactionContextInterface) error {
+		_, err := c.h.RemoveDependent(ctx, ben.ID)
+		return err
+	})
+	if err := invite(c, pat, dan); err != nil {
+		t.Errorf("place of the removed invite not freed: %v", err)
+	}
+
+	var group *MembershipGroup
+	c.mustInvoke(t, pat, func(ctx contractapi.TransactionContextInterface) error {
+		var err error
+		group, err = c.h.GetMembershipGroup(ctx, pat.ID)
+		return err
+	})
+	if !reflect.DeepEqual(group.Dependents, []string{userPrefix + ann.ID}) || !reflect.DeepEqual(group.Invites, []string{userPrefix + cat.ID, userPrefix + dan.ID}) {
+		t.Errorf("dependents %v and invites %v, want ann and the invites of cat and dan", group.Dependents, group.Invites)
+	}
+
+	c.after(time.Hour)
+	c.mustInvoke(t, ann, func(ctx contractapi.TransactionContextInterface) error {
+		_, err := c.h.CheckOut(ctx)
+		return err
+	})
+	c.mustInvoke(t, ann, func(ctx contractapi.TransactionContextInterface) error {
+		_, err := c.h.LeaveGroup(ctx, pat.ID)
+		return err
+	})
+	if err := checkIn(c, ann, "gym"); err == nil {
+		t.Errorf("user checked in after leaving the group")
+	}
+}
+
+func TestCancellingTheSharedMembershipDissolvesTheGroup(t *testing.T) {
+
+	c := newTestClub(t)
+	pat := newGroup(t, c)
+	ann := c.member(t, "ann", 0)
+
+	if err := invite(c, pat, ann); err != nil {
+		t.Fatal(err)
+	}
+	if err := acceptInvite(c, ann, pat); err != nil {
+		t.Fatal(err)
+	}
+	if c.user(t, userPrefix+ann.ID).GroupOf != userPrefix+pat.ID {
+		t.Fatalf("ann is not a dependent of pat")
+	}
+
+	c.after(24 * time.Hour)
+	c.mustInvoke(t, pat, func(ctx contractapi.TransactionContextInterface) error {
+		_, err := c.h.CancelMembership(ctx)
+		return err
+	})
+
+	_, err := c.invoke(pat, func(ctx contractapi.TransactionContextInterface) error {
+		_, err := c.h.GetMembershipGroup(ctx, pat.ID)
+		return err
+	})
+	if err == nil {
+		t.Errorf("group of the cancelled membership still exists")
+	}
+
+	if groupOf := c.user(t, userPrefix+ann.ID).GroupOf; groupOf != "" {
+		t.Errorf("ann is still a dependent of %v", groupOf)
+	}
+	if err := checkIn(c, ann, "gym"); err == nil {
+		t.Errorf("dependent checked in after the shared membership was cancelled")
+	}
+}
@@ -8,8 +8,10 @@ import (
 	"strings"
 	"time"
 
-	erc20 "github.com/VisheshSolu/MiniClubChaincode/erc20"
 	"github.com/hyperledger/fabric-contract-api-go/contractapi"
+	erc20 "github.com/varun425/MiniClubChaincode/erc20"
+	"github.com/varun425/MiniClubChaincode/events"
+	"github.com/varun425/MiniClubChaincode/events/envelope"
 )
 
 type HealthClub struct {
@@ -17,27 +19,63 @@ type HealthClub struct {
 	erc20.SmartContract
 }
 
+// GetEvaluateTransactions returns the transactions that only read the world state, including the token ones
+// They are tagged evaluate in the contract metadata, the others submit
+func (h *HealthClub) GetEvaluateTransactions() []string {
+	return append(h.SmartContract.GetEvaluateTransactions(),
+		"GetAttendance", "GetDailyOccupancy", "GetBranch", "GetClassDetails", "GetCorporateAccount", "GetCorporateStatement",
+		"GetDowngradePolicy", "GetGiftCard", "GetMembershipGroup", "GetAllMembershipByLevel", "GetAllMembershipsofUser",
+		"GetMembershipDetails", "GetUserDetails", "GetLevelDetails", "GetParticularMemberByLevel", "GetAllMembershipsOfUsers",
+		"GetAllUsers", "VerifyMembershipAccess", "GetNoShowStrikes", "GetNoShowPolicy", "GetPasses", "GetDayPassPrice",
+		"GetPromoCode", "GetPromoRedemptions", "GetMembershipsPage", "GetUsersPage", "QueryMemberships", "QueryUsers",
+		"GetReferrals", "GetReferralPolicy", "RevenueReport", "GetRewardRules", "PreviewRewards", "GetTrainer",
+		"GetSessionOffer", "GetSessionBooking", "GetTrainerCommission", "QuoteUpgrade")
+}
+
 type User struct {
 	Memberships []string `json:"memberships"`
 	Name        string   `json:"name"`
 	Email       string   `json:"email"`
+	ReferredBy  string   `json:"referredby"`
+	GroupOf     string   `json:"groupof"`
+	CorporateID string   `json:"corporateid"`
+	DocType     string   `json:"docType"`
 }
 
 type Level struct {
-	EntryPrizeTokens int `json:"entryprizetokens"`
-	Months           int `json:"months"`
+	EntryPrizeTokens int  `json:"entryprizetokens"`
+	Months           int  `json:"months"`
+	MaxFreezeDays    int  `json:"maxfreezedays"`
+	MaxGroupSize     int  `json:"maxgroupsize"`
+	GuestPasses      int  `json:"guestpasses"`
+	AllAccess        bool `json:"allaccess"`
 }
 
 type Membership struct {
-	Level          string `json:"level"`
-	TokenDeposited int
-	IsCompleted    bool
-	IsCancelled    bool
-	IsUpdated      bool
-	StartDate      string
-	EndDate        string
-	RefundAmount   int
-	UserID         string
+	Level           string `json:"level"`
+	TokenDeposited  int
+	Status          string
+	StatusHistory   []StatusChange
+	UpgradeHistory  []TierChange
+	StartDate       string
+	EndDate         string
+	RefundAmount    int
+	UserID          string
+	AutoRenew       bool
+	RenewedBy       string
+	FrozenFrom      string
+	FrozenUntil     string
+	FreezeReason    string
+	Freezes         []FreezePeriod
+	PromoCode       string
+	DiscountApplied int
+	SponsoredBy     string
+	SponsoredAmount int
+	BranchID        string
+	AllAccess       bool
+	StartDateKey    string
+	EndDateKey      string
+	DocType         string `json:"docType"`
 }
 
 const (
@@ -46,6 +84,9 @@ const (
 	goldlevel        = "Gold"
 	platinumlevel    = "Platinum"
 	diamondlevel     = "Diamond"
+	dateFormat       = "01-02-2006"
+	timeFormat       = "01-02-2006 15:04:05"
+	keyDateFormat    = "2006-01-02"
 )
 
 func (h *HealthClub) InitializeContract(ctx contractapi.TransactionContextInterface) error {
@@ -87,17 +128,17 @@ func (h *HealthClub) InitializeContract(ctx contractapi.TransactionContextInterf
 		return fmt.Errorf("not able to initialize contract")
 	}
 
-	err = setMembershipLevelToken(ctx, "Gold", 1, 1000)
+	err = setMembershipLevelToken(ctx, "Gold", 1, 1000, 7, 1, 0)
 	if err != nil {
 		return fmt.Errorf("error: %v", err)
 	}
 
-	err = setMembershipLevelToken(ctx, "Platinum", 6, 5000)
+	err = setMembershipLevelToken(ctx, "Platinum", 6, 5000, 30, 1, 2)
 	if err != nil {
 		return fmt.Errorf("error: %v", err)
 	}
 
-	err = setMembershipLevelToken(ctx, "Diamond", 12, 8000)
+	err = setMembershipLevelToken(ctx, "Diamond", 12, 8000, 60, 4, 4)
 	if err != nil {
 		return fmt.Errorf("error: %v", err)
 	}
@@ -111,6 +152,11 @@ func (h *HealthClub) InitializeContract(ctx contractapi.TransactionContextInterf
 }
 
 func (h *HealthClub) RegisterUser(ctx contractapi.TransactionContextInterface, name string, email string) (string, error) {
+	return h.registerUser(ctx, name, email, "")
+}
+
+// registerUser registers the caller, referredBy is the ledger key of the referring user or empty
+func (h *HealthClub) registerUser(ctx contractapi.TransactionContextInterface, name string, email string, referredBy string) (string, error) {
 
 	userid, err := ctx.GetClientIdentity().GetID()
 
@@ -135,6 +181,8 @@ func (h *HealthClub) RegisterUser(ctx contractapi.TransactionContextInterface, n
 		Memberships: []string{},
 		Name:        name,
 		Email:       email,
+		ReferredBy:  referredBy,
+		DocType:     userDocType,
 	}
 
 	userdetailsbytes, _ := json.Marshal(userdetails)
@@ -150,17 +198,36 @@ func (h *HealthClub) RegisterUser(ctx contractapi.TransactionContextInterface, n
 		return "", fmt.Errorf("error:%v", err)
 	}
 
+	now, err := getTxTime(ctx)
+	if err != nil {
+		return "", fmt.Errorf("error:%v", err)
+	}
+
+	err = emitEvent(ctx, events.UserRegistered, events.UserRegisteredEvent{
+		UserID:     newUserId,
+		Name:       name,
+		Email:      email,
+		ReferredBy: referredBy,
+		Date:       now.Format(dateFormat),
+	})
+	if err != nil {
+		return "", err
+	}
+
 	log.Printf("%v registered successfully", newUserId)
 	return "User registered successfully", nil
 }
 
-func setMembershipLevelToken(ctx contractapi.TransactionContextInterface, level string, months int, entryPrizeTokens int) error {
+func setMembershipLevelToken(ctx contractapi.TransactionContextInterface, level string, months int, entryPrizeTokens int, maxFreezeDays int, maxGroupSize int, guestPasses int) error {
 
 	if level == goldlevel || level == diamondlevel || level == platinumlevel {
 
 		temp := Level{
 			EntryPrizeTokens: entryPrizeTokens,
 			Months:           months,
+			MaxFreezeDays:    maxFreezeDays,
+			MaxGroupSize:     maxGroupSize,
+			GuestPasses:      guestPasses,
 		}
 
 		resInBytes, err := json.Marshal(temp)
@@ -173,7 +240,7 @@ func setMembershipLevelToken(ctx contractapi.TransactionContextInterface, level
 			return fmt.Errorf("error:%v", err)
 		}
 
-		log.Printf("The %v level is set at %v tokens for %v months ", level, entryPrizeTokens, months)
+		log.Printf("The %v level is set at %v tokens for %v months with %v freeze days per year for up to %v people and %v guest passes per month", level, entryPrizeTokens, months, maxFreezeDays, maxGroupSize, guestPasses)
 
 	} else {
 		return fmt.Errorf("only Gold, Diamond, and Platinum levels are acceptable")
@@ -183,6 +250,30 @@ func setMembershipLevelToken(ctx contractapi.TransactionContextInterface, level
 }
 
 func (h *HealthClub) GetNewMemberShip(ctx contractapi.TransactionContextInterface, level string) (string, error) {
+	return h.getNewMemberShip(ctx, level, purchaseOptions{})
+}
+
+// GetNewMemberShipWithPromo buys a membership like GetNewMemberShip with the price discounted by a promo code
+func (h *HealthClub) GetNewMemberShipWithPromo(ctx contractapi.TransactionContextInterface, level string, promoCode string) (string, error) {
+
+	if promoCode == "" {
+		return "", fmt.Errorf("promo code is required")
+	}
+
+	return h.getNewMemberShip(ctx, level, purchaseOptions{PromoCode: promoCode})
+}
+
+// purchaseOptions are the optional parts of a membership purchase
+// A redeemed GiftCard pays the price from its escrowed value, any value left is paid out to the buyer.
+// BranchID is the home branch, whose price applies and whose treasury receives the revenue
+type purchaseOptions struct {
+	PromoCode string
+	GiftCard  *GiftCard
+	BranchID  string
+}
+
+// getNewMemberShip buys a membership of the level for the caller
+func (h *HealthClub) getNewMemberShip(ctx contractapi.TransactionContextInterface, level string, opts purchaseOptions) (string, error) {
 
 	userid, err := ctx.GetClientIdentity().GetID()
 
@@ -216,41 +307,31 @@ func (h *HealthClub) GetNewMemberShip(ctx contractapi.TransactionContextInterfac
 		levelptr := new(Level)
 		_ = json.Unmarshal(levelbytes, &levelptr)
 
-		currentTime := time.Now()
-		endTime := currentTime.AddDate(0, levelptr.Months, 0)
-
-		membership := Membership{
-			Level:          level,
-			TokenDeposited: levelptr.EntryPrizeTokens,
-			IsCompleted:    false,
-			IsCancelled:    false,
-			IsUpdated:      false,
-			StartDate:      currentTime.Format("01-02-2006"),
-			EndDate:        endTime.Format("01-02-2006"),
-			RefundAmount:   0,
-			UserID:         userId,
+		currentTime, err := getTxTime(ctx)
+		if err != nil {
+			return "", fmt.Errorf("error:%v", err)
 		}
 
-		// create new membership
-		membershipAsBytes, _ := json.Marshal(membership)
-
-		TotalMembershipsbytes, err := ctx.GetStub().GetState("TotalMemberships")
-
+		price, err := levelPrice(ctx, level, levelptr, opts.BranchID)
 		if err != nil {
-			return "", fmt.Errorf("err: %v", err)
+			return "", err
 		}
 
-		TotalMemberships, _ := strconv.Atoi(string(TotalMembershipsbytes))
+		membership := newMembership(userId, level, levelptr, currentTime)
+		membership.TokenDeposited = price
+		membership.BranchID = opts.BranchID
+		membership.AllAccess = levelptr.AllAccess
 
-		membershipID := membershipPrefix + strconv.Itoa(TotalMemberships+1)
-		ctx.GetStub().PutState(membershipID, membershipAsBytes)
-
-		updatedTotalMemberships := TotalMemberships + 1
-
-		err = ctx.GetStub().PutState("TotalMemberships", []byte(strconv.Itoa(updatedTotalMemberships)))
+		if opts.PromoCode != "" {
+			discount, err := redeemPromoCode(ctx, opts.PromoCode, userId, level, price, currentTime)
+			if err != nil {
+				return "", err
+			}
 
-		if err != nil {
-			return "", fmt.Errorf("err: %v", err)
+			price = price - discount
+			membership.TokenDeposited = price
+			membership.PromoCode = opts.PromoCode
+			membership.DiscountApplied = discount
 		}
 
 		// update user memberships
@@ -261,58 +342,56 @@ func (h *HealthClub) GetNewMemberShip(ctx contractapi.TransactionContextInterfac
 
 		if len(userptr.Memberships) != 0 {
 			currentmembershipId := userptr.Memberships[len(userptr.Memberships)-1]
-			membershipdetailsBytes, err := ctx.GetStub().GetState(currentmembershipId)
+			membershipdetails, err := getMembership(ctx, currentmembershipId)
 			if err != nil {
 				return "", fmt.Errorf("error: %v", err)
 			}
 
-			membershipdetails := new(Membership)
-			_ = json.Unmarshal(membershipdetailsBytes, &membershipdetails)
-			now := time.Now()
 			membershipendDate, _ := time.Parse("01-02-2006", membershipdetails.EndDate)
-			compareTime := now.After(membershipendDate)
+			compareTime := currentTime.After(membershipendDate)
 
-			if compareTime {
-				membershipdetails.IsCompleted = true
-				updatedmembership, _ := json.Marshal(membershipdetails)
-				err = ctx.GetStub().PutState(currentmembershipId, updatedmembership)
+			if membershipdetails.Status == StatusActive && compareTime {
+				membershipdetails.AutoRenew = false
+				err = transitionMembership(membershipdetails, StatusExpired, currentTime, "membership ended")
 				if err != nil {
 					return "", fmt.Errorf("error:%v", err)
 				}
-			} else if membershipdetails.IsCancelled {
+
+				err = putMembership(ctx, currentmembershipId, membershipdetails)
+				if err != nil {
+					return "", err
+				}
+
+				err = setAutoRenewIndex(ctx, currentmembershipId, userId, false)
+				if err != nil {
+					return "", fmt.Errorf("error:%v", err)
+				}
+
+				err = dissolveGroup(ctx, userId, "primary membership expired")
+				if err != nil {
+					return "", err
+				}
+
+				err = emitMembershipExpired(ctx, currentmembershipId, membershipdetails)
+				if err != nil {
+					return "", err
+				}
+			} else if membershipdetails.Status == StatusCancelled || membershipdetails.Status == StatusExpired {
 				// do nothing
 			} else {
 				return "", fmt.Errorf("memebership not ended, Please wait for current membership to end")
 			}
 		}
 
-		userptr.Memberships = append(userptr.Memberships, membershipID)
-
-		userdetailsbytes, _ := json.Marshal(userptr)
-		err = ctx.GetStub().PutState(userId, userdetailsbytes)
+		TotalMembershipsbytes, err := ctx.GetStub().GetState("TotalMemberships")
 
 		if err != nil {
-			return "", fmt.Errorf("error:%v", err)
+			return "", fmt.Errorf("err: %v", err)
 		}
 
-		// Update the state of the smart contract by adding the allowanceKey and value
-
-		var index string = "level~UserID"
-		//userdetailsbytes, _ := json.Marshal(userptr)
-		// ctx.GetStub().PutState(level, userdetailsbytes)
-		userLevelIndexKey, err := ctx.GetStub().CreateCompositeKey(index, []string{level, userId})
-		if err != nil {
-			return "", fmt.Errorf("failed to create the composite key for prefix %s: %v", index, err)
-		}
-		// value := []byte{0x00}
-		// ctx.GetStub().PutState(userLevelIndexKey, value)
-		// fmt.Println("Added", userId)
-		err = ctx.GetStub().PutState(userLevelIndexKey, userdetailsbytes)
-		if err != nil {
-			return "", fmt.Errorf("error %v", err)
-		}
+		TotalMemberships, _ := strconv.Atoi(string(TotalMembershipsbytes))
 
-		log.Printf("user memberships updated successfully")
+		membershipID := membershipPrefix + strconv.Itoa(TotalMemberships+1)
 
 		adminidbytes, err := ctx.GetStub().GetState("owner")
 
@@ -326,421 +405,325 @@ func (h *HealthClub) GetNewMemberShip(ctx contractapi.TransactionContextInterfac
 
 		adminID := string(adminidbytes)
 
-		err = h.Transfer(ctx, adminID, levelptr.EntryPrizeTokens)
-
+		// membership revenue goes to the treasury of the home branch, the owner account without branch
+		payee, err := revenueAccount(ctx, opts.BranchID)
 		if err != nil {
-			return "", fmt.Errorf("err: %v", err)
+			return "", err
 		}
 
-		return "Successfully get new Membership", nil
-		// transfer tokens from user to admin
+		// several accounts are debited when an employer sponsors the membership or a referrer earns a bonus,
+		// a batch is used as the world state does not reflect earlier writes of the same transaction
+		batch := erc20.NewTransferBatch(ctx)
+		var sponsorship *CorporateCharge
+		var referral *Referral
+		giftCardPaid := 0
 
-	} else {
-		return "", fmt.Errorf("invalid level")
-	}
-}
-
-func (h *HealthClub) GetAllMembershipByLevel(ctx contractapi.TransactionContextInterface, level string) ([]string, error) {
-	var index string = "level~UserID"
-	levelUsers := []string{}
-
-	//return bytes memory address of first state
-	levelIterator, err := ctx.GetStub().GetStateByPartialCompositeKey(index, []string{level})
-	if err != nil {
-		return nil, fmt.Errorf("error:%v", err.Error())
-	}
-
-	defer levelIterator.Close()
+		if userptr.CorporateID != "" && price > 0 {
+			sponsorship, err = chargeCorporate(ctx, batch, userptr.CorporateID, userId, membershipID, level, price, adminID, payee, currentTime)
+			if err != nil {
+				return "", err
+			}
 
-	// Iterate through result set and for each level found
-	var i int
-	for i = 0; levelIterator.HasNext(); i++ {
-		responseRange, err := levelIterator.Next()
-		if err != nil {
-			return nil, fmt.Errorf("error:%v", err.Error())
+			membership.SponsoredBy = userptr.CorporateID
+			membership.SponsoredAmount = sponsorship.Amount
 		}
-		// get the level and userID from defined index composite key
-		_, compositeKeyParts, err := ctx.GetStub().SplitCompositeKey(responseRange.Key)
+
+		err = addMembershipToUser(ctx, membershipID, &membership, userId, userptr)
 		if err != nil {
-			return nil, fmt.Errorf("error:%v", err.Error())
+			return "", fmt.Errorf("error:%v", err)
 		}
-		returnedLevelUserID := compositeKeyParts[1]
-		levelUsers = append(levelUsers, returnedLevelUserID)
-	}
-	return levelUsers, nil
-}
-
-func (h *HealthClub) CancelMembership(ctx contractapi.TransactionContextInterface) (string, error) {
-
-	userid, err := ctx.GetClientIdentity().GetID()
-
-	if err != nil {
-		return "", fmt.Errorf("failed to get userID: %v", err)
-	}
-
-	userId := userPrefix + userid
-
-	user, err := ctx.GetStub().GetState(userId)
-	if err != nil {
-		return "", fmt.Errorf("error:%v", err)
-	}
-
-	if user == nil {
-		return "", fmt.Errorf("user not found")
-	}
-
-	userptr := new(User)
-	_ = json.Unmarshal(user, &userptr)
-
-	log.Printf("user details: %v", (userptr))
 
-	if len(userptr.Memberships) == 0 {
-		return "", fmt.Errorf(("no membership found"))
-	} else {
-		currentmembershipId := userptr.Memberships[len(userptr.Memberships)-1]
-		membershipdetailsBytes, err := ctx.GetStub().GetState(currentmembershipId)
-
-		log.Printf("mid", currentmembershipId)
-
-		membershipdetails := new(Membership)
-		_ = json.Unmarshal(membershipdetailsBytes, &membershipdetails)
+		updatedTotalMemberships := TotalMemberships + 1
 
-		log.Printf("mdetails", membershipdetails)
+		err = ctx.GetStub().PutState("TotalMemberships", []byte(strconv.Itoa(updatedTotalMemberships)))
 
-		if membershipdetails.Level == goldlevel {
+		if err != nil {
+			return "", fmt.Errorf("err: %v", err)
+		}
 
-			refundamount := 0
-			log.Printf("refund amount will be %v", refundamount)
+		log.Printf("user memberships updated successfully")
 
+		// the referrer earns a bonus on the first paid membership, the referral stays pending until then
+		if userptr.ReferredBy != "" && price > 0 {
+			referral, err = payReferralBonus(ctx, batch, userptr.ReferredBy, userId, adminID, currentTime)
 			if err != nil {
-				return "", fmt.Errorf("error: %v", err)
+				return "", err
 			}
+		}
 
-			membershipdetails.RefundAmount = refundamount
-			membershipdetails.IsCancelled = true
-			membershipdetails.IsCompleted = true
-
-			updatedmembership, _ := json.Marshal(membershipdetails)
-			err = ctx.GetStub().PutState(currentmembershipId, updatedmembership)
+		if opts.GiftCard != nil {
+			giftCardPaid, err = payWithGiftCard(batch, opts.GiftCard, userid, payee, price-membership.SponsoredAmount)
 			if err != nil {
-				return "", fmt.Errorf("error:%v", err)
+				return "", err
 			}
+		}
 
-			return "Successfully Cancel gold Membership", nil
-
-		} else if membershipdetails.Level == platinumlevel {
-			membershipstartDate, _ := time.Parse("01-02-2006", membershipdetails.StartDate)
-			now := time.Now()
-
-			onemonthplusbuffer := membershipstartDate.AddDate(0, 1, 7)
-			twomonthplusbuffer := membershipstartDate.AddDate(0, 2, 7)
-			threemonthplusbuffer := membershipstartDate.AddDate(0, 3, 7)
-			fourmonthplusbuffer := membershipstartDate.AddDate(0, 4, 7)
-
-			if now.Before(onemonthplusbuffer) {
-
-				refundamount, err := calculaterefundamount(ctx, membershipdetails.TokenDeposited, platinumlevel, "1")
-
-				log.Printf("refund amount will be %v", refundamount)
-
-				if err != nil {
-					return "", fmt.Errorf("error: %v", err)
-				}
-
-				membershipdetails.RefundAmount = refundamount
-				membershipdetails.IsCancelled = true
-				membershipdetails.IsCompleted = true
-
-				updatedmembership, _ := json.Marshal(membershipdetails)
-				err = ctx.GetStub().PutState(currentmembershipId, updatedmembership)
-				if err != nil {
-					return "", fmt.Errorf("error:%v", err)
-				}
-
-				// transfer remaining tokens back to user
-				err = h.Mint(ctx, refundamount)
+		// branch sales pay the royalty from the treasury in the same batch
+		if sponsorship != nil || referral != nil || opts.GiftCard != nil || opts.BranchID != "" {
+			if price-membership.SponsoredAmount-giftCardPaid > 0 {
+				err = batch.Transfer(userid, payee, price-membership.SponsoredAmount-giftCardPaid)
 				if err != nil {
-					return "", fmt.Errorf("error:%v", err)
+					return "", fmt.Errorf("err: %v", err)
 				}
+			}
 
-				return "Successfully Cancel Membership", nil
-
-			} else if now.Before(twomonthplusbuffer) {
-
-				refundamount, err := calculaterefundamount(ctx, membershipdetails.TokenDeposited, platinumlevel, "2")
+			if price > 0 {
+				err = splitRevenue(ctx, batch, opts.BranchID, revenueMembership, userId, price, currentTime)
 				if err != nil {
-					return "", fmt.Errorf("error: %v", err)
+					return "", err
 				}
-				log.Printf("refund amount will be %v", refundamount)
-				membershipdetails.RefundAmount = refundamount
-				membershipdetails.IsCancelled = true
-				membershipdetails.IsCompleted = true
+			}
 
-				updatedmembership, _ := json.Marshal(membershipdetails)
-				err = ctx.GetStub().PutState(currentmembershipId, updatedmembership)
-				if err != nil {
-					return "", fmt.Errorf("error:%v", err)
-				}
+			err = batch.Commit()
+			if err != nil {
+				return "", fmt.Errorf("err: %v", err)
+			}
 
-				// transfer remaining tokens back to user
-				err = h.Mint(ctx, refundamount)
+			if referral != nil {
+				err = emitEvent(ctx, events.ReferralBonus, referral)
 				if err != nil {
-					return "", fmt.Errorf("error:%v", err)
+					return "", err
 				}
+			}
 
-				return "Successfully Cancel Membership", nil
-
-			} else if now.Before(threemonthplusbuffer) {
-
-				refundamount, err := calculaterefundamount(ctx, membershipdetails.TokenDeposited, platinumlevel, "3")
+			if sponsorship != nil {
+				err = emitEvent(ctx, events.CorporateMembershipCharged, sponsorship)
 				if err != nil {
-					return "", fmt.Errorf("error: %v", err)
+					return "", err
 				}
-				log.Printf("refund amount will be %v", refundamount)
-				membershipdetails.RefundAmount = refundamount
-				membershipdetails.IsCancelled = true
-				membershipdetails.IsCompleted = true
+			}
 
-				updatedmembership, _ := json.Marshal(membershipdetails)
-				err = ctx.GetStub().PutState(currentmembershipId, updatedmembership)
+			if opts.GiftCard != nil {
+				err = emitEvent(ctx, events.GiftCardRedeemed, opts.GiftCard)
 				if err != nil {
-					return "", fmt.Errorf("error:%v", err)
+					return "", err
 				}
+			}
 
-				// transfer remaining tokens back to user
-				err = h.Mint(ctx, refundamount)
-				if err != nil {
-					return "", fmt.Errorf("error:%v", err)
-				}
+			err = emitMembershipCreated(ctx, membershipID, &membership, "")
+			if err != nil {
+				return "", err
+			}
 
-				return "Successfully Cancel Membership", nil
+			return "Successfully get new Membership", nil
+		}
 
-			} else if now.Before(fourmonthplusbuffer) {
+		if price > 0 {
+			err = h.Transfer(ctx, payee, price)
 
-				refundamount, err := calculaterefundamount(ctx, membershipdetails.TokenDeposited, platinumlevel, "4")
-				if err != nil {
-					return "", fmt.Errorf("error: %v", err)
-				}
-				log.Printf("refund amount will be %v", refundamount)
-				membershipdetails.RefundAmount = refundamount
-				membershipdetails.IsCancelled = true
-				membershipdetails.IsCompleted = true
+			if err != nil {
+				return "", fmt.Errorf("err: %v", err)
+			}
 
-				updatedmembership, _ := json.Marshal(membershipdetails)
-				err = ctx.GetStub().PutState(currentmembershipId, updatedmembership)
-				if err != nil {
-					return "", fmt.Errorf("error:%v", err)
-				}
+			// no royalty without branch, the sale is only booked
+			err = splitRevenue(ctx, batch, "", revenueMembership, userId, price, currentTime)
+			if err != nil {
+				return "", err
+			}
+		}
 
-				// transfer remaining tokens back to user
-				err = h.Mint(ctx, refundamount)
-				if err != nil {
-					return "", fmt.Errorf("error:%v", err)
-				}
+		err = emitMembershipCreated(ctx, membershipID, &membership, "")
+		if err != nil {
+			return "", err
+		}
 
-				return "Successfully Cancel Membership", nil
+		return "Successfully get new Membership", nil
+		// transfer tokens from user to admin
 
-			} else {
-				return "", fmt.Errorf("cannot cancel Platinum memberhsip after 4 months")
-			}
+	} else {
+		return "", fmt.Errorf("invalid level")
+	}
+}
 
-		} else {
-			membershipstartDate, _ := time.Parse("01-02-2006", membershipdetails.StartDate)
-			now := time.Now()
-			// for testing purpose
-			now = now.AddDate(0, 3, 0)
-
-			onemonthplusbuffer := membershipstartDate.AddDate(0, 1, 7)
-			twomonthplusbuffer := membershipstartDate.AddDate(0, 2, 7)
-			threemonthplusbuffer := membershipstartDate.AddDate(0, 3, 7)
-			fourmonthplusbuffer := membershipstartDate.AddDate(0, 4, 7)
-			fivemonthplusbuffer := membershipstartDate.AddDate(0, 5, 7)
-			sixmonthplusbuffer := membershipstartDate.AddDate(0, 6, 7)
-			sevenmonthplusbuffer := membershipstartDate.AddDate(0, 7, 7)
-
-			if now.Before(onemonthplusbuffer) {
-
-				refundamount, err := calculaterefundamount(ctx, membershipdetails.TokenDeposited, diamondlevel, "1")
-				if err != nil {
-					return "", fmt.Errorf("error: %v", err)
-				}
-				log.Printf("refund amount will be %v", refundamount)
-				membershipdetails.RefundAmount = refundamount
-				membershipdetails.IsCancelled = true
-				membershipdetails.IsCompleted = true
+// newMembership returns a membership of the given level for the user starting at the given time
+func newMembership(userId string, level string, leveldetails *Level, start time.Time) Membership {
 
-				updatedmembership, _ := json.Marshal(membershipdetails)
-				err = ctx.GetStub().PutState(currentmembershipId, updatedmembership)
-				if err != nil {
-					return "", fmt.Errorf("error:%v", err)
-				}
+	endTime := start.AddDate(0, leveldetails.Months, 0)
 
-				// transfer remaining tokens back to user
-				err = h.Mint(ctx, refundamount)
-				if err != nil {
-					return "", fmt.Errorf("error:%v", err)
-				}
+	membership := Membership{
+		Level:          level,
+		TokenDeposited: leveldetails.EntryPrizeTokens,
+		StartDate:      start.Format(dateFormat),
+		EndDate:        endTime.Format(dateFormat),
+		RefundAmount:   0,
+		UserID:         userId,
+	}
 
-				return "Successfully Cancel Membership", nil
+	_ = transitionMembership(&membership, StatusActive, start, "membership purchased")
 
-			} else if now.Before(twomonthplusbuffer) {
+	return membership
+}
 
-				refundamount, err := calculaterefundamount(ctx, membershipdetails.TokenDeposited, diamondlevel, "2")
-				if err != nil {
-					return "", fmt.Errorf("error: %v", err)
-				}
-				log.Printf("refund amount will be %v", refundamount)
-				membershipdetails.RefundAmount = refundamount
-				membershipdetails.IsCancelled = true
-				membershipdetails.IsCompleted = true
+// addMembershipToUser stores the membership under membershipID, appends it to the memberships of the user
+// and adds the user to the level~UserID index. The caller is responsible for updating TotalMemberships.
+func addMembershipToUser(ctx contractapi.TransactionContextInterface, membershipID string, membership *Membership, userId string, userptr *User) error {
 
-				updatedmembership, _ := json.Marshal(membershipdetails)
-				err = ctx.GetStub().PutState(currentmembershipId, updatedmembership)
-				if err != nil {
-					return "", fmt.Errorf("error:%v", err)
-				}
+	err := putMembership(ctx, membershipID, membership)
+	if err != nil {
+		return err
+	}
 
-				// transfer remaining tokens back to user
-				err = h.Mint(ctx, refundamount)
-				if err != nil {
-					return "", fmt.Errorf("error:%v", err)
-				}
+	userptr.Memberships = append(userptr.Memberships, membershipID)
+	userptr.DocType = userDocType
 
-				return "Successfully Cancel Membership", nil
+	userdetailsbytes, _ := json.Marshal(userptr)
+	err = ctx.GetStub().PutState(userId, userdetailsbytes)
+	if err != nil {
+		return fmt.Errorf("error:%v", err)
+	}
 
-			} else if now.Before(threemonthplusbuffer) {
+	userLevelIndexKey, err := ctx.GetStub().CreateCompositeKey(levelIndex, []string{membership.Level, userId})
+	if err != nil {
+		return fmt.Errorf("failed to create the composite key for prefix %s: %v", levelIndex, err)
+	}
 
-				refundamount, err := calculaterefundamount(ctx, membershipdetails.TokenDeposited, diamondlevel, "3")
-				if err != nil {
-					return "", fmt.Errorf("error: %v", err)
-				}
-				log.Printf("refund amount will be %v", refundamount)
-				membershipdetails.RefundAmount = refundamount
-				membershipdetails.IsCancelled = true
-				membershipdetails.IsCompleted = true
+	err = ctx.GetStub().PutState(userLevelIndexKey, userdetailsbytes)
+	if err != nil {
+		return fmt.Errorf("error %v", err)
+	}
 
-				updatedmembership, _ := json.Marshal(membershipdetails)
-				err = ctx.GetStub().PutState(currentmembershipId, updatedmembership)
-				if err != nil {
-					return "", fmt.Errorf("error:%v", err)
-				}
+	return nil
+}
 
-				// transfer remaining tokens back to user
-				err = h.Mint(ctx, refundamount)
-				if err != nil {
-					return "", fmt.Errorf("error:%v", err)
-				}
+func (h *HealthClub) GetAllMembershipByLevel(ctx contractapi.TransactionContextInterface, level string) ([]string, error) {
+	var index string = "level~UserID"
+	levelUsers := []string{}
 
-				return "Successfully Cancel Membership", nil
+	//return bytes memory address of first state
+	levelIterator, err := ctx.GetStub().GetStateByPartialCompositeKey(index, []string{level})
+	if err != nil {
+		return nil, fmt.Errorf("error:%v", err.Error())
+	}
 
-			} else if now.Before(fourmonthplusbuffer) {
+	defer levelIterator.Close()
 
-				refundamount, err := calculaterefundamount(ctx, membershipdetails.TokenDeposited, diamondlevel, "4")
-				if err != nil {
-					return "", fmt.Errorf("error: %v", err)
-				}
-				log.Printf("refund amount will be %v", refundamount)
-				membershipdetails.RefundAmount = refundamount
-				membershipdetails.IsCancelled = true
-				membershipdetails.IsCompleted = true
+	// Iterate through result set and for each level found
+	var i int
+	for i = 0; levelIterator.HasNext(); i++ {
+		responseRange, err := levelIterator.Next()
+		if err != nil {
+			return nil, fmt.Errorf("error:%v", err.Error())
+		}
+		// get the level and userID from defined index composite key
+		_, compositeKeyParts, err := ctx.GetStub().SplitCompositeKey(responseRange.Key)
+		if err != nil {
+			return nil, fmt.Errorf("error:%v", err.Error())
+		}
+		returnedLevelUserID := compositeKeyParts[1]
+		levelUsers = append(levelUsers, returnedLevelUserID)
+	}
+	return levelUsers, nil
+}
 
-				updatedmembership, _ := json.Marshal(membershipdetails)
-				err = ctx.GetStub().PutState(currentmembershipId, updatedmembership)
-				if err != nil {
-					return "", fmt.Errorf("error:%v", err)
-				}
+func (h *HealthClub) CancelMembership(ctx contractapi.TransactionContextInterface) (string, error) {
 
-				// transfer remaining tokens back to user
-				err = h.Mint(ctx, refundamount)
-				if err != nil {
-					return "", fmt.Errorf("error:%v", err)
-				}
+	userid, err := ctx.GetClientIdentity().GetID()
 
-				return "Successfully Cancel Membership", nil
+	if err != nil {
+		return "", fmt.Errorf("failed to get userID: %v", err)
+	}
 
-			} else if now.Before(fivemonthplusbuffer) {
+	userId := userPrefix + userid
 
-				refundamount, err := calculaterefundamount(ctx, membershipdetails.TokenDeposited, diamondlevel, "5")
-				if err != nil {
-					return "", fmt.Errorf("error: %v", err)
-				}
-				log.Printf("refund amount will be %v", refundamount)
+	currentmembershipId, membershipdetails, err := getCurrentMembership(ctx, userId)
+	if err != nil {
+		return "", fmt.Errorf("error:%v", err)
+	}
 
-				membershipdetails.RefundAmount = refundamount
-				membershipdetails.IsCancelled = true
-				membershipdetails.IsCompleted = true
+	log.Printf("cancelling membership %v: %v", currentmembershipId, membershipdetails)
 
-				updatedmembership, _ := json.Marshal(membershipdetails)
-				err = ctx.GetStub().PutState(currentmembershipId, updatedmembership)
-				if err != nil {
-					return "", fmt.Errorf("error:%v", err)
-				}
+	if membershipdetails.SponsoredBy != "" {
+		return "", fmt.Errorf("membership is sponsored by %v, leave its roster to cancel it", membershipdetails.SponsoredBy)
+	}
 
-				// transfer remaining tokens back to user
-				err = h.Mint(ctx, refundamount)
-				if err != nil {
-					return "", fmt.Errorf("error:%v", err)
-				}
+	now, err := getTxTime(ctx)
+	if err != nil {
+		return "", fmt.Errorf("error:%v", err)
+	}
 
-				return "Successfully Cancel Membership", nil
+	refundamount := 0
 
-			} else if now.Before(sixmonthplusbuffer) {
+	if membershipdetails.Level != goldlevel {
+		membershipstartDate, _ := time.Parse("01-02-2006", membershipdetails.StartDate)
 
-				refundamount, err := calculaterefundamount(ctx, membershipdetails.TokenDeposited, diamondlevel, "6")
-				if err != nil {
-					return "", fmt.Errorf("error: %v", err)
-				}
-				log.Printf("refund amount will be %v", refundamount)
+		// refunds decrease every month, with a one week buffer, until the last refundable month
+		refundableMonths := 4
+		if membershipdetails.Level != platinumlevel {
+			refundableMonths = 7
+		}
 
-				membershipdetails.RefundAmount = refundamount
-				membershipdetails.IsCancelled = true
-				membershipdetails.IsCompleted = true
+		month := 0
+		for i := 1; i <= refundableMonths; i++ {
+			if now.Before(membershipstartDate.AddDate(0, i, 7)) {
+				month = i
+				break
+			}
+		}
 
-				updatedmembership, _ := json.Marshal(membershipdetails)
-				err = ctx.GetStub().PutState(currentmembershipId, updatedmembership)
-				if err != nil {
-					return "", fmt.Errorf("error:%v", err)
-				}
+		if month == 0 {
+			return "", fmt.Errorf("cannot cancel %v memberhsip after %v months", membershipdetails.Level, refundableMonths)
+		}
 
-				// transfer remaining tokens back to user
-				err = h.Mint(ctx, refundamount)
-				if err != nil {
-					return "", fmt.Errorf("error:%v", err)
-				}
+		refundamount, err = calculaterefundamount(membershipdetails.TokenDeposited, membershipdetails.Level, strconv.Itoa(month))
+		if err != nil {
+			return "", fmt.Errorf("error: %v", err)
+		}
+	}
 
-				return "Successfully Cancel Membership", nil
+	log.Printf("refund amount will be %v", refundamount)
 
-			} else if now.Before(sevenmonthplusbuffer) {
+	membershipdetails.RefundAmount = refundamount
+	membershipdetails.AutoRenew = false
 
-				refundamount, err := calculaterefundamount(ctx, membershipdetails.TokenDeposited, diamondlevel, "7")
-				if err != nil {
-					return "", fmt.Errorf("error: %v", err)
-				}
-				log.Printf("refund amount will be %v", refundamount)
+	err = transitionMembership(membershipdetails, StatusCancelled, now, "cancelled by member")
+	if err != nil {
+		return "", fmt.Errorf("error:%v", err)
+	}
 
-				membershipdetails.RefundAmount = refundamount
-				membershipdetails.IsCancelled = true
-				membershipdetails.IsCompleted = true
+	err = putMembership(ctx, currentmembershipId, membershipdetails)
+	if err != nil {
+		return "", err
+	}
 
-				updatedmembership, _ := json.Marshal(membershipdetails)
-				err = ctx.GetStub().PutState(currentmembershipId, updatedmembership)
-				if err != nil {
-					return "", fmt.Errorf("error:%v", err)
-				}
+	err = setAutoRenewIndex(ctx, currentmembershipId, userId, false)
+	if err != nil {
+		return "", fmt.Errorf("error:%v", err)
+	}
 
-				// transfer remaining tokens back to user
-				err = h.Mint(ctx, refundamount)
-				if err != nil {
-					return "", fmt.Errorf("error:%v", err)
-				}
+	err = dissolveGroup(ctx, userId, "primary membership cancelled")
+	if err != nil {
+		return "", err
+	}
+
+	err = emitMembershipCancelled(ctx, currentmembershipId, membershipdetails, "cancelled by member", now)
+	if err != nil {
+		return "", err
+	}
 
-				return "Successfully Cancel Membership", nil
+	if membershipdetails.Level == goldlevel {
+		return "Successfully Cancel gold Membership", nil
+	}
 
-			} else {
-				return "", fmt.Errorf("cannot cancel Diamond memberhsip after 7 months")
-			}
+	// transfer remaining tokens back to user
+	if refundamount > 0 {
+		err = h.Mint(ctx, refundamount)
+		if err != nil {
+			return "", fmt.Errorf("error:%v", err)
+		}
+
+		// the refund is minted, the branch treasury and the royalty are left as they are
+		err = putRevenueEntry(ctx, &RevenueEntry{
+			BranchID: membershipdetails.BranchID,
+			Source:   revenueCancel,
+			UserID:   userId,
+			Refund:   refundamount,
+		}, now)
+		if err != nil {
+			return "", err
 		}
 	}
+
+	return "Successfully Cancel Membership", nil
 }
 
 func calculaterefundamount(amount int, level string, month string) (int, error) {
@@ -825,38 +808,43 @@ func (h *HealthClub) GetAllMembershipsofUser(ctx contractapi.TransactionContextI
 	}
 }
 
-func (h *HealthClub) GetMembershipDetails(ctx contractapi.TransactionContextInterface, membershipId string) (*Membership, error) {
+func (h *HealthClub) GetMembershipDetails(ctx contractapi.TransactionContextInterface, membershipId string) (*MembershipView, error) {
 
-	memberhsipbytes, err := ctx.GetStub().GetState(membershipId)
+	memberhsipdetails, err := getMembership(ctx, membershipId)
 	if err != nil {
-		return nil, fmt.Errorf("error:%v", err)
+		return nil, err
 	}
 
-	if memberhsipbytes == nil {
-		return nil, fmt.Errorf("membership not found")
+	now, err := getTxTime(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("error:%v", err)
 	}
 
-	memberhsipdetails := new(Membership)
-	_ = json.Unmarshal(memberhsipbytes, &memberhsipdetails)
+	view := newMembershipView(membershipId, memberhsipdetails, now)
 
-	return memberhsipdetails, nil
+	return &view, nil
 }
 
-func (h *HealthClub) GetUserDetails(ctx contractapi.TransactionContextInterface, userId string) (*User, error) {
+func (h *HealthClub) GetUserDetails(ctx contractapi.TransactionContextInterface, userId string) (*UserView, error) {
 
-	userbytes, err := ctx.GetStub().GetState(userId)
+	userId = toUserKey(userId)
+
+	userdetails, err := getUser(ctx, userId)
 	if err != nil {
-		return nil, fmt.Errorf("error:%v", err)
+		return nil, err
 	}
 
-	if userbytes == nil {
-		return nil, fmt.Errorf("user not found")
+	now, err := getTxTime(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("error:%v", err)
 	}
 
-	userdetails := new(User)
-	_ = json.Unmarshal(userbytes, &userdetails)
+	view, err := newUserView(ctx, userId, userdetails, now)
+	if err != nil {
+		return nil, err
+	}
 
-	return userdetails, nil
+	return &view, nil
 }
 
 func (h *HealthClub) GetLevelDetails(ctx contractapi.TransactionContextInterface, level string) (*Level, error) {
@@ -876,7 +864,24 @@ func (h *HealthClub) GetLevelDetails(ctx contractapi.TransactionContextInterface
 	return leveldetails, nil
 }
 
+// UpgradeMembership moves the current membership of the caller to a more expensive level
+// The unused value of the current term is credited by day and the new term starts today, see QuoteUpgrade
 func (h *HealthClub) UpgradeMembership(ctx contractapi.TransactionContextInterface, level string) (string, error) {
+	return h.upgradeMembership(ctx, level, "")
+}
+
+// UpgradeMembershipWithPromo upgrades like UpgradeMembership with the amount due discounted by a promo code
+func (h *HealthClub) UpgradeMembershipWithPromo(ctx contractapi.TransactionContextInterface, level string, promoCode string) (string, error) {
+
+	if promoCode == "" {
+		return "", fmt.Errorf("promo code is required")
+	}
+
+	return h.upgradeMembership(ctx, level, promoCode)
+}
+
+// upgradeMembership upgrades the current membership of the caller, promoCode is optional
+func (h *HealthClub) upgradeMembership(ctx contractapi.TransactionContextInterface, level string, promoCode string) (string, error) {
 
 	// get unique user id
 	userId, err := ctx.GetClientIdentity().GetID()
@@ -888,155 +893,81 @@ func (h *HealthClub) UpgradeMembership(ctx contractapi.TransactionContextInterfa
 		return "", fmt.Errorf("userID not exist")
 	}
 
-	userDetails := new(User)
-	resInBytes1, err := ctx.GetStub().GetState(userPrefix + userId)
-	if err != nil {
-		return "", fmt.Errorf("error:%v", err.Error())
-	}
-
-	if resInBytes1 == nil {
-		return "", fmt.Errorf("empty userDetails")
-	}
-	_ = json.Unmarshal(resInBytes1, &userDetails)
-
-	level_ := new(Level)
-	resInBytes2, err := ctx.GetStub().GetState(level)
+	currentMembershipID, membership, err := getCurrentMembership(ctx, userPrefix+userId)
 	if err != nil {
-		return "", fmt.Errorf("error:%v", err.Error())
-	}
-	if resInBytes1 == nil {
-		return "", fmt.Errorf("empty level")
+		return "", fmt.Errorf("error:%v", err)
 	}
-	_ = json.Unmarshal(resInBytes2, &level_)
-
-	membership := new(Membership)
-
-	a := len(userDetails.Memberships)
-
-	currentMembershipID := userDetails.Memberships[a-1]
-
-	resInBytes3, err := ctx.GetStub().GetState(currentMembershipID)
 
+	currentTime, err := getTxTime(ctx)
 	if err != nil {
-		return "", fmt.Errorf("error:%v", err.Error())
+		return "", fmt.Errorf("error:%v", err)
 	}
-	_ = json.Unmarshal(resInBytes3, &membership)
 
+	quote, err := h.quoteUpgrade(ctx, currentMembershipID, membership, level, currentTime)
 	if err != nil {
-		return "", fmt.Errorf("error:::%v", err.Error())
-	}
-
-	oldLevelcopy := membership.Level
-
-	currentTime := time.Now()
-
-	endTime, _ := time.Parse("01-02-2006", membership.EndDate)
-
-	checkExpire := (endTime.Sub(currentTime)).Hours()
-
-	if level != goldlevel && level != diamondlevel && level != platinumlevel {
-		return "", fmt.Errorf("level not matched,expected Gold,Platinum,Diamond but given: %v", level)
-	}
-
-	if int(checkExpire) <= 0 {
-		return "", fmt.Errorf("un-expected time difference: %v", checkExpire)
-	}
-
-	if checkExpire <= 24 {
-		return "", fmt.Errorf("membership expired at %v cannot update", checkExpire)
-	}
-
-	months := (checkExpire / 730)
-
-	if int(months) >= level_.Months {
-		return "", fmt.Errorf("cannot de-grade membership from %v to %v", membership.Level, level)
-	}
-
-	tokens := level_.EntryPrizeTokens - membership.TokenDeposited
-
-	if tokens <= 0 {
-		return "", fmt.Errorf("already on %v level", level)
+		return "", err
 	}
 
-	newMonths := int(months) + (level_.Months - 1)
-	membership.EndDate = currentTime.AddDate(0, int(newMonths), 0).Format("01-02-2006")
-	membership.TokenDeposited = tokens + membership.TokenDeposited
-	membership.IsCompleted = false
-	membership.IsUpdated = true
-	membership.Level = level
-
-	resInBytes, _ := json.Marshal(membership)
+	if promoCode != "" {
+		discount, err := redeemPromoCode(ctx, promoCode, userPrefix+userId, level, quote.AmountDue, currentTime)
+		if err != nil {
+			return "", err
+		}
 
-	if err != nil {
-		return "", fmt.Errorf("err: %v", err)
+		quote.AmountDue = quote.AmountDue - discount
+		membership.PromoCode = promoCode
+		membership.DiscountApplied = discount
 	}
 
-	err = ctx.GetStub().PutState(currentMembershipID, resInBytes)
-	if err != nil {
-		return "", fmt.Errorf("error:%v", err.Error())
-	}
+	membership.UpgradeHistory = append(membership.UpgradeHistory, TierChange{
+		FromLevel:  quote.FromLevel,
+		ToLevel:    quote.ToLevel,
+		Date:       quote.StartDate,
+		Credit:     quote.UnusedCredit,
+		AmountPaid: quote.AmountDue,
+	})
 
-	userdetailsbytes, _ := json.Marshal(userDetails)
-	err = ctx.GetStub().PutState(userPrefix+userId, userdetailsbytes)
+	newLevel, err := h.GetLevelDetails(ctx, level)
 	if err != nil {
 		return "", fmt.Errorf("error:%v", err)
 	}
 
-	checkForCompositeKey, err := h.GetParticularMemberByLevel(ctx, level, userId)
+	membership.Level = level
+	membership.AllAccess = newLevel.AllAccess
+	membership.StartDate = quote.StartDate
+	membership.EndDate = quote.EndDate
+	membership.TokenDeposited = quote.UnusedCredit + quote.AmountDue
 
+	err = putMembership(ctx, currentMembershipID, membership)
 	if err != nil {
-		return "", fmt.Errorf("error:%v", err.Error())
-	}
-	var index string = "level~UserID"
-	if !checkForCompositeKey {
-
-		userLevelIndexKey, err := ctx.GetStub().CreateCompositeKey(index, []string{level, userId})
-		if err != nil {
-			return "", fmt.Errorf("failed to create the composite key for prefix %s: %v", index, err)
-		}
-
-		err = ctx.GetStub().PutState(userLevelIndexKey, userdetailsbytes)
-		if err != nil {
-			return "", fmt.Errorf("error %v", err)
-		}
+		return "", err
 	}
 
-	checkForCompositeKey, err = h.GetParticularMemberByLevel(ctx, oldLevelcopy, userId)
-
+	err = moveLevelIndex(ctx, userPrefix+userId, quote.FromLevel, level)
 	if err != nil {
-		return "", fmt.Errorf("error:%v", err.Error())
+		return "", err
 	}
 
-	if checkForCompositeKey {
+	log.Printf("membership updated from %v to %v at %v tokens", membership.StartDate, membership.EndDate, membership.TokenDeposited)
 
-		userLevelIndexKey, err := ctx.GetStub().CreateCompositeKey(index, []string{oldLevelcopy, userPrefix + userId})
-		if err != nil {
-			return "", fmt.Errorf("failed to create the composite key for prefix %s: %v", index, err)
-		}
-		err = ctx.GetStub().DelState(userLevelIndexKey)
+	if quote.AmountDue > 0 {
+		err = h.collectRevenue(ctx, membership.BranchID, revenueUpgrade, quote.AmountDue, currentTime)
 		if err != nil {
-			return "", fmt.Errorf("error in del state for %v level composite key", oldLevelcopy)
+			return "", err
 		}
 	}
 
-	log.Printf("membership updated from %v to %v at %v tokens", membership.StartDate, membership.EndDate, membership.TokenDeposited)
-
-	adminidbytes, err := ctx.GetStub().GetState("owner")
-
-	if err != nil {
-		return "", fmt.Errorf("err: %v", err)
-	}
-
-	if adminidbytes == nil {
-		return "", fmt.Errorf("AdminID not set")
-	}
-
-	adminID := string(adminidbytes)
-
-	err = h.Transfer(ctx, adminID, tokens)
-
+	err = emitEvent(ctx, events.MembershipUpgraded, events.MembershipUpgradedEvent{
+		MembershipID: currentMembershipID,
+		UserID:       membership.UserID,
+		FromLevel:    quote.FromLevel,
+		ToLevel:      quote.ToLevel,
+		Credit:       quote.UnusedCredit,
+		AmountPaid:   quote.AmountDue,
+		EndDate:      quote.EndDate,
+	})
 	if err != nil {
-		return "", fmt.Errorf("err: %v", err)
+		return "", err
 	}
 
 	return "Membership Updated", nil
@@ -1076,11 +1007,9 @@ func (h *HealthClub) GetParticularMemberByLevel(ctx contractapi.TransactionConte
 	return resultBool, nil
 }
 
-func (h *HealthClub) GetAllMembershipsOfUsers(ctx contractapi.TransactionContextInterface) ([]Membership, error) {
+func (h *HealthClub) GetAllMembershipsOfUsers(ctx contractapi.TransactionContextInterface) ([]MembershipView, error) {
 
-	membership := new(Membership)
-
-	arr := []Membership{}
+	arr := []MembershipView{}
 
 	resInBytes, err := ctx.GetStub().GetState("TotalMemberships")
 	breakingPoint, _ := strconv.Atoi(string(resInBytes))
@@ -1089,7 +1018,12 @@ func (h *HealthClub) GetAllMembershipsOfUsers(ctx contractapi.TransactionContext
 		return nil, fmt.Errorf("error1:%v", err.Error())
 	}
 
-	for i := 0; i <= breakingPoint; i++ {
+	now, err := getTxTime(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("error:%v", err)
+	}
+
+	for i := 1; i <= breakingPoint; i++ {
 
 		key := membershipPrefix + strconv.Itoa(i)
 		resInBytes, err = ctx.GetStub().GetState(key)
@@ -1099,18 +1033,23 @@ func (h *HealthClub) GetAllMembershipsOfUsers(ctx contractapi.TransactionContext
 		}
 
 		if resInBytes == nil {
-
+			continue
 		}
 
+		membership := new(Membership)
 		_ = json.Unmarshal(resInBytes, &membership)
 
-		arr = append(arr, *membership)
+		if membership.Status == "" {
+			migrateMembershipStatus(resInBytes, membership)
+		}
+
+		arr = append(arr, newMembershipView(key, membership, now))
 
 	}
-	return arr[1:], nil
+	return arr, nil
 }
 
-func (h *HealthClub) GetAllUsers(ctx contractapi.TransactionContextInterface) ([]User, error) {
+func (h *HealthClub) GetAllUsers(ctx contractapi.TransactionContextInterface) ([]UserView, error) {
 	startKey := ""
 	endKey := ""
 
@@ -1122,7 +1061,12 @@ func (h *HealthClub) GetAllUsers(ctx contractapi.TransactionContextInterface) ([
 	}
 	defer resultsIterator.Close()
 
-	results := []User{}
+	now, err := getTxTime(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("error:%v", err)
+	}
+
+	results := []UserView{}
 
 	for resultsIterator.HasNext() {
 		queryResponse, err := resultsIterator.Next()
@@ -1133,7 +1077,12 @@ func (h *HealthClub) GetAllUsers(ctx contractapi.TransactionContextInterface) ([
 		if checkPrefix == true {
 			club := new(User)
 			_ = json.Unmarshal(queryResponse.Value, club)
-			results = append(results, *club)
+
+			view, err := newUserView(ctx, queryResponse.Key, club, now)
+			if err != nil {
+				return nil, err
+			}
+			results = append(results, view)
 
 		}
 
@@ -1209,3 +1158,190 @@ func (h *HealthClub) UpdateMembershipLevelToken(ctx contractapi.TransactionConte
 
 	return "level is updated", nil
 }
+
+// VerifyMembershipAccess checks whether the given user currently holds a membership that grants entry to the club,
+// either their own or, for dependents, the group membership of the primary holder
+// returns an error describing the reason when access is denied, e.g. while the membership is frozen
+func (h *HealthClub) VerifyMembershipAccess(ctx contractapi.TransactionContextInterface, userId string) error {
+
+	_, membership, err := getAccessMembership(ctx, toUserKey(userId))
+	if err != nil {
+		return fmt.Errorf("access denied: %v", err)
+	}
+
+	now, err := getTxTime(ctx)
+	if err != nil {
+		return fmt.Errorf("error:%v", err)
+	}
+
+	err = checkMembershipAccess(membership, now)
+	if err != nil {
+		return fmt.Errorf("access denied: %v", err)
+	}
+
+	return nil
+}
+
+// checkOwner returns an error if the caller is not the owner that initialized the contract
+func checkOwner(ctx contractapi.TransactionContextInterface) error {
+
+	userId, err := ctx.GetClientIdentity().GetID()
+	if err != nil {
+		return fmt.Errorf("error:%v", err.Error())
+	}
+
+	adminidbytes, err := ctx.GetStub().GetState("owner")
+	if err != nil {
+		return fmt.Errorf("err: %v", err)
+	}
+
+	if adminidbytes == nil {
+		return fmt.Errorf("adminID not set")
+	}
+
+	if string(adminidbytes) != userId {
+		return fmt.Errorf("only owner can perform this operation")
+	}
+
+	return nil
+}
+
+// checkStaff returns an error if the caller is neither the owner nor has the staff or admin role attribute
+func checkStaff(ctx contractapi.TransactionContextInterface) error {
+
+	role, found, err := ctx.GetClientIdentity().GetAttributeValue("role")
+	if err != nil {
+		return fmt.Errorf("error:%v", err)
+	}
+
+	if found && (role == "staff" || role == "admin") {
+		return nil
+	}
+
+	if checkOwner(ctx) == nil {
+		return nil
+	}
+
+	return fmt.Errorf("current role is :%v ,but requires staff or admin role", role)
+}
+
+// getOwnerID returns the client id of the owner, whose account is the treasury of the club
+func getOwnerID(ctx contractapi.TransactionContextInterface) (string, error) {
+
+	adminidbytes, err := ctx.GetStub().GetState("owner")
+	if err != nil {
+		return "", fmt.Errorf("err: %v", err)
+	}
+
+	if adminidbytes == nil {
+		return "", fmt.Errorf("AdminID not set")
+	}
+
+	return string(adminidbytes), nil
+}
+
+// emitEvent adds the event to the event envelope of the transaction, see the envelope package
+// Without the envelope transaction context only the last event set in a transaction is kept
+func emitEvent(ctx contractapi.TransactionContextInterface, name string, payload interface{}) error {
+	return envelope.Emit(ctx, name, payload)
+}
+
+// getTxTime returns the timestamp of the current transaction, which unlike time.Now() is the same on every endorsing peer
+func getTxTime(ctx contractapi.TransactionContextInterface) (time.Time, error) {
+
+	timestamp, err := ctx.GetStub().GetTxTimestamp()
+	if err != nil {
+		return time.Time{}, fmt.Errorf("failed to get transaction timestamp: %v", err)
+	}
+
+	return time.Unix(timestamp.Seconds, int64(timestamp.Nanos)).UTC(), nil
+}
+
+// getCurrentMembership returns the id and details of the latest membership of a registered user
+// userId is the ledger key of the user, i.e. prefixed with "User-"
+func getCurrentMembership(ctx contractapi.TransactionContextInterface, userId string) (string, *Membership, error) {
+
+	userbytes, err := ctx.GetStub().GetState(userId)
+	if err != nil {
+		return "", nil, fmt.Errorf("error:%v", err)
+	}
+
+	if userbytes == nil {
+		return "", nil, fmt.Errorf("user not found")
+	}
+
+	userptr := new(User)
+	_ = json.Unmarshal(userbytes, &userptr)
+
+	if len(userptr.Memberships) == 0 {
+		return "", nil, fmt.Errorf("no membership found")
+	}
+
+	currentmembershipId := userptr.Memberships[len(userptr.Memberships)-1]
+	membershipdetails, err := getMembership(ctx, currentmembershipId)
+	if err != nil {
+		return "", nil, err
+	}
+
+	return currentmembershipId, membershipdetails, nil
+}
+
+// getUser reads a registered user, userId is the ledger key of the user
+func getUser(ctx contractapi.TransactionContextInterface, userId string) (*User, error) {
+
+	userbytes, err := ctx.GetStub().GetState(userId)
+	if err != nil {
+		return nil, fmt.Errorf("error:%v", err)
+	}
+
+	if userbytes == nil {
+		return nil, fmt.Errorf("user %v not found", userId)
+	}
+
+	userptr := new(User)
+	_ = json.Unmarshal(userbytes, &userptr)
+
+	return userptr, nil
+}
+
+// putUser writes a user under the ledger key of the user
+func putUser(ctx contractapi.TransactionContextInterface, userId string, userptr *User) error {
+
+	userptr.DocType = userDocType
+
+	userdetailsbytes, _ := json.Marshal(userptr)
+	err := ctx.GetStub().PutState(userId, userdetailsbytes)
+	if err != nil {
+		return fmt.Errorf("error:%v", err)
+	}
+
+	return nil
+}
+
+// checkMembershipAccess returns an error if the membership does not grant entry to the club at the given time
+func checkMembershipAccess(membership *Membership, now time.Time) error {
+
+	if membership.Status == StatusFrozen {
+		return fmt.Errorf("membership is frozen since %v", membership.FrozenFrom)
+	}
+
+	if membership.Status != StatusActive {
+		return fmt.Errorf("membership is %v", membership.Status)
+	}
+
+	membershipendDate, err := time.Parse(dateFormat, membership.EndDate)
+	if err != nil {
+		return fmt.Errorf("invalid membership end date %v", membership.EndDate)
+	}
+
+	if now.After(membershipendDate) {
+		return fmt.Errorf("membership ended on %v", membership.EndDate)
+	}
+
+	return nil
+}
+
+// daysBetween returns the number of whole days from start to end
+func daysBetween(start time.Time, end time.Time) int {
+	return int(end.Sub(start).Hours() / 24)
+}
@@ -0,0 +1,159 @@
+package healthclub
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/hyperledger/fabric-contract-api-go/contractapi"
+	"github.com/varun425/MiniClubChaincode/events/envelope"
+	"github.com/varun425/MiniClubChaincode/internal/chaincodetest"
+)
+
+// testStart is the timestamp of the first transaction of every test club
+var testStart = time.Date(2026, time.March, 2, 10, 0, 0, 0, time.UTC)
+
+// clientID returns the client ID of the identity with the common name, as GetID formats it before base64 encoding
+func clientID(name string) string {
+	return "x509::CN=" + name + "::CN=ca.org1.example.com"
+}
+
+// testClub is an initialized HealthClub on an in-memory world state
+type testClub struct {
+	*chaincodetest.World
+	h     *HealthClub
+	owner *chaincodetest.Identity
+	staff *chaincodetest.Identity
+}
+
+// newTestClub initializes the contract as owner with the default levels
+func newTestClub(t *testing.T) *testClub {
+
+	t.Helper()
+
+	c := &testClub{
+		World: chaincodetest.NewWorld(testStart),
+		h:     new(HealthClub),
+		owner: chaincodetest.NewIdentity(clientID("owner"), map[string]string{"role": "admin"}),
+		staff: chaincodetest.NewIdentity(clientID("staff"), map[string]string{"role": "staff"}),
+	}
+
+	c.mustInvoke(t, c.owner, func(ctx contractapi.TransactionContextInterface) error {
+		return c.h.InitializeContract(ctx)
+	})
+
+	return c
+}
+
+// invoke runs fn as one transaction of the caller
+func (c *testClub) invoke(caller *chaincodetest.Identity, fn func(ctx contractapi.TransactionContextInterface) error) (*envelope.TransactionContext, error) {
+	return c.World.Invoke(caller, fn)
+}
+
+// mustInvoke runs fn as one transaction of the caller and fails the test if it returns an error
+func (c *testClub) mustInvoke(t *testing.T, caller *chaincodetest.Identity, fn func(ctx contractapi.TransactionContextInterface) error) *envelope.TransactionContext {
+
+	t.Helper()
+
+	ctx, err := c.invoke(caller, fn)
+	if err != nil {
+		t.Fatalf("transaction of %v failed: %v", caller.ID, err)
+	}
+
+	return ctx
+}
+
+// member registers the user named name and mints tokens on top of the registration bonus
+func (c *testClub) member(t *testing.T, name string, tokens int) *chaincodetest.Identity {
+
+	t.Helper()
+
+	member := chaincodetest.NewIdentity(clientID(name), nil)
+	c.mustInvoke(t, member, func(ctx contractapi.TransactionContextInterface) error {
+		_, err := c.h.RegisterUser(ctx, name, name+"@example.com")
+		return err
+	})
+
+	if tokens > 0 {
+		c.mustInvoke(t, member, func(ctx contractapi.TransactionContextInterface) error {
+			return c.h.Mint(ctx, tokens)
+		})
+	}
+
+	return member
+}
+
+// buy buys a membership of the level for the member and returns its ID
+func (c *testClub) buy(t *testing.T, member *chaincodetest.Identity, level string) string {
+
+	t.Helper()
+
+	c.mustInvoke(t, member, func(ctx contractapi.TransactionContextInterface) error {
+		_, err := c.h.GetNewMemberShip(ctx, level)
+		return err
+	})
+
+	memberships := c.user(t, userPrefix+member.ID).Memberships
+
+	return memberships[len(memberships)-1]
+}
+
+// after moves the timestamp of the next transactions forward
+func (c *testClub) after(d time.Duration) {
+	c.Now = c.Now.Add(d)
+}
+
+// membership returns the committed membership
+func (c *testClub) membership(t *testing.T, membershipId string) *Membership {
+
+	t.Helper()
+
+	membershipbytes := c.Get(membershipId)
+	if membershipbytes == nil {
+		t.Fatalf("membership %v not found", membershipId)
+	}
+
+	membership := new(Membership)
+	_ = json.Unmarshal(membershipbytes, membership)
+
+	return membership
+}
+
+// user returns the committed user
+func (c *testClub) user(t *testing.T, userId string) *User {
+
+	t.Helper()
+
+	userbytes := c.Get(userId)
+	if userbytes == nil {
+		t.Fatalf("user %v not found", userId)
+	}
+
+	user := new(User)
+	_ = json.Unmarshal(userbytes, user)
+
+	return user
+}
+
+// assertBalances fails the test unless every account has the expected committed balance
+func (c *testClub) assertBalances(t *testing.T, expected map[string]int) {
+
+	t.Helper()
+
+	for account, balance := range expected {
+		if got := c.Balance(account); got != balance {
+			t.Errorf("balance of %v = %v, want %v", account, got, balance)
+		}
+	}
+}
+
+// eventNames returns the names of the events in the envelope of the transaction
+func eventNames(ctx *envelope.TransactionContext) []string {
+
+	names := []string{}
+	for _, event := range ctx.Envelope().Events {
+		names = append(names, event.Name)
+	}
+
+	return names
+}
@@ -0,0 +1,44 @@
+package healthclub
+
+import (
+	"time"
+
+	"github.com/hyperledger/fabric-contract-api-go/contractapi"
+	"github.com/varun425/MiniClubChaincode/events"
+)
+
+// emitMembershipCreated adds a MembershipCreated event for a purchased membership, or a renewal of renewalOf, to the transaction
+func emitMembershipCreated(ctx contractapi.TransactionContextInterface, membershipId string, membership *Membership, renewalOf string) error {
+	return emitEvent(ctx, events.MembershipCreated, events.MembershipCreatedEvent{
+		MembershipID: membershipId,
+		UserID:       membership.UserID,
+		Level:        membership.Level,
+		BranchID:     membership.BranchID,
+		Price:        membership.TokenDeposited,
+		StartDate:    membership.StartDate,
+		EndDate:      membership.EndDate,
+		RenewalOf:    renewalOf,
+	})
+}
+
+// emitMembershipExpired adds a MembershipExpired event to the transaction
+func emitMembershipExpired(ctx contractapi.TransactionContextInterface, membershipId string, membership *Membership) error {
+	return emitEvent(ctx, events.MembershipExpired, events.MembershipExpiredEvent{
+		MembershipID: membershipId,
+		UserID:       membership.UserID,
+		Level:        membership.Level,
+		EndDate:      membership.EndDate,
+	})
+}
+
+// emitMembershipCancelled adds a MembershipCancelled event with the refund of the membership to the transaction
+func emitMembershipCancelled(ctx contractapi.TransactionContextInterface, membershipId string, membership *Membership, reason string, now time.Time) error {
+	return emitEvent(ctx, events.MembershipCancelled, events.MembershipCancelledEvent{
+		MembershipID: membershipId,
+		UserID:       membership.UserID,
+		Level:        membership.Level,
+		Refund:       membership.RefundAmount,
+		Reason:       reason,
+		Date:         now.Format(dateFormat),
+	})
+}
@@ -0,0 +1,334 @@
+package healthclub
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+
+	"github.com/hyperledger/fabric-contract-api-go/contractapi"
+	erc20 "github.com/varun425/MiniClubChaincode/erc20"
+	"github.com/varun425/MiniClubChaincode/events"
+)
+
+const (
+	noShowPolicyKey = "NoShowPolicy"
+	strikeIndex     = "strikes~UserID"
+	forfeitMode     = "forfeit"
+	penaltyMode     = "penalty"
+)
+
+// NoShowPolicy configures the settlement of booked members that did not attend a class.
+// In forfeit mode the booking fee is kept, in penalty mode PenaltyAmount is also charged through the
+// allowance the member granted to the club account. MaxStrikes no-shows block booking for BlockDays days.
+type NoShowPolicy struct {
+	Mode          string `json:"mode"`
+	PenaltyAmount int    `json:"penaltyamount"`
+	MaxStrikes    int    `json:"maxstrikes"`
+	BlockDays     int    `json:"blockdays"`
+}
+
+// Strikes counts the no-shows of a user since the last block
+// UnpaidPenalty is penalty that could not be charged and must be paid before booking again
+type Strikes struct {
+	UserID        string `json:"userid"`
+	Strikes       int    `json:"strikes"`
+	BlockedUntil  string `json:"blockeduntil"`
+	UnpaidPenalty int    `json:"unpaidpenalty"`
+}
+
+// ClassSettlement is the outcome of settling a class
+type ClassSettlement struct {
+	ClassID          string    `json:"classid"`
+	Attended         []string  `json:"attended"`
+	NoShows          []Strikes `json:"noshows"`
+	WaitlistRefunded []string  `json:"waitlistrefunded"`
+}
+
+var defaultNoShowPolicy = NoShowPolicy{
+	Mode:          forfeitMode,
+	PenaltyAmount: 0,
+	MaxStrikes:    3,
+	BlockDays:     14,
+}
+
+// MarkAttendance records which booked members attended a class that has started and settles the class:
+// every other booked member is a no-show and gets a strike and, in penalty mode, is charged the penalty.
+// Members still on the waitlist are refunded. A class is settled once.
+// This function triggers a ClassSettled event
+func (h *HealthClub) MarkAttendance(ctx contractapi.TransactionContextInterface, classId string, userIds []string) (*ClassSettlement, error) {
+
+	err := checkStaff(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	class, err := getClass(ctx, classId)
+	if err != nil {
+		return nil, err
+	}
+
+	if class.Settled {
+		return nil, fmt.Errorf("class %v is already settled", classId)
+	}
+
+	now, err := getTxTime(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("error:%v", err)
+	}
+
+	classStart, _ := time.Parse(timeFormat, class.StartTime)
+	if now.Before(classStart) {
+		return nil, fmt.Errorf("class %v has not started yet", classId)
+	}
+
+	for _, userId := range userIds {
+		userId = toUserKey(userId)
+		if !containsUser(class.Bookings, userId) {
+			return nil, fmt.Errorf("%v is not booked in class %v", userId, classId)
+		}
+		if !containsUser(class.Attended, userId) {
+			class.Attended = append(class.Attended, userId)
+		}
+	}
+
+	policy, err := h.GetNoShowPolicy(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	adminID, err := getOwnerID(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	batch := erc20.NewTransferBatch(ctx)
+	settlement := &ClassSettlement{
+		ClassID:          classId,
+		Attended:         class.Attended,
+		NoShows:          []Strikes{},
+		WaitlistRefunded: []string{},
+	}
+
+	for _, userId := range class.Bookings {
+
+		if containsUser(class.Attended, userId) {
+			continue
+		}
+
+		strikes, err := getStrikes(ctx, userId)
+		if err != nil {
+			return nil, err
+		}
+
+		if policy.Mode == penaltyMode && policy.PenaltyAmount > 0 {
+			err = batch.TransferFrom(strings.TrimPrefix(userId, userPrefix), adminID, adminID, policy.PenaltyAmount)
+			if err != nil {
+				log.Printf("penalty of %v could not be charged: %v", userId, err)
+				strikes.UnpaidPenalty = strikes.UnpaidPenalty + policy.PenaltyAmount
+			}
+		}
+
+		strikes.Strikes = strikes.Strikes + 1
+		if policy.MaxStrikes > 0 && strikes.Strikes >= policy.MaxStrikes {
+			strikes.BlockedUntil = now.AddDate(0, 0, policy.BlockDays).Format(dateFormat)
+			strikes.Strikes = 0
+		}
+
+		err = putStrikes(ctx, strikes)
+		if err != nil {
+			return nil, err
+		}
+
+		settlement.NoShows = append(settlement.NoShows, *strikes)
+	}
+
+	if class.Price > 0 {
+		branchId, err := getFacilityBranch(ctx, class.Room)
+		if err != nil {
+			return nil, err
+		}
+
+		for _, userId := range class.Waitlist {
+			err = refundRevenue(ctx, batch, branchId, revenueClass, userId, strings.TrimPrefix(userId, userPrefix), class.Price, now)
+			if err != nil {
+				return nil, fmt.Errorf("failed to refund waitlist: %v", err)
+			}
+			settlement.WaitlistRefunded = append(settlement.WaitlistRefunded, userId)
+		}
+	}
+
+	err = batch.Commit()
+	if err != nil {
+		return nil, fmt.Errorf("error:%v", err)
+	}
+
+	class.Waitlist = []string{}
+	class.Settled = true
+
+	err = putClass(ctx, class)
+	if err != nil {
+		return nil, err
+	}
+
+	err = emitEvent(ctx, events.ClassSettled, settlement)
+	if err != nil {
+		return nil, err
+	}
+
+	log.Printf("class %v settled with %v attended and %v no-shows", classId, len(class.Attended), len(settlement.NoShows))
+
+	return settlement, nil
+}
+
+// PayNoShowPenalty pays the outstanding no-show penalty of the caller to the club account
+func (h *HealthClub) PayNoShowPenalty(ctx contractapi.TransactionContextInterface) (string, error) {
+
+	userid, err := ctx.GetClientIdentity().GetID()
+	if err != nil {
+		return "", fmt.Errorf("failed to get userID: %v", err)
+	}
+
+	strikes, err := getStrikes(ctx, userPrefix+userid)
+	if err != nil {
+		return "", err
+	}
+
+	if strikes.UnpaidPenalty == 0 {
+		return "", fmt.Errorf("no outstanding penalty")
+	}
+
+	unpaid := strikes.UnpaidPenalty
+	strikes.UnpaidPenalty = 0
+
+	err = putStrikes(ctx, strikes)
+	if err != nil {
+		return "", err
+	}
+
+	adminID, err := getOwnerID(ctx)
+	if err != nil {
+		return "", err
+	}
+
+	err = h.Transfer(ctx, adminID, unpaid)
+	if err != nil {
+		return "", fmt.Errorf("err: %v", err)
+	}
+
+	return "Penalty paid", nil
+}
+
+// GetNoShowStrikes returns the no-show strikes and booking block of a user
+func (h *HealthClub) GetNoShowStrikes(ctx contractapi.TransactionContextInterface, userId string) (*Strikes, error) {
+	return getStrikes(ctx, toUserKey(userId))
+}
+
+// SetNoShowPolicy configures how no-shows are settled, mode is forfeit or penalty
+func (h *HealthClub) SetNoShowPolicy(ctx contractapi.TransactionContextInterface, mode string, penaltyAmount int, maxStrikes int, blockDays int) (string, error) {
+
+	err := checkOwner(ctx)
+	if err != nil {
+		return "", err
+	}
+
+	if mode != forfeitMode && mode != penaltyMode {
+		return "", fmt.Errorf("mode must be forfeit or penalty but given: %v", mode)
+	}
+
+	if penaltyAmount < 0 || maxStrikes < 0 || blockDays < 0 {
+		return "", fmt.Errorf("penalty amount, max strikes and block days cannot be negative")
+	}
+
+	policy := NoShowPolicy{
+		Mode:          mode,
+		PenaltyAmount: penaltyAmount,
+		MaxStrikes:    maxStrikes,
+		BlockDays:     blockDays,
+	}
+
+	policybytes, _ := json.Marshal(policy)
+	err = ctx.GetStub().PutState(noShowPolicyKey, policybytes)
+	if err != nil {
+		return "", fmt.Errorf("error:%v", err)
+	}
+
+	return "no-show policy is updated", nil
+}
+
+// GetNoShowPolicy returns the no-show policy, or the default policy if the owner has not set one
+func (h *HealthClub) GetNoShowPolicy(ctx contractapi.TransactionContextInterface) (*NoShowPolicy, error) {
+
+	policybytes, err := ctx.GetStub().GetState(noShowPolicyKey)
+	if err != nil {
+		return nil, fmt.Errorf("error:%v", err)
+	}
+
+	policy := defaultNoShowPolicy
+	if policybytes != nil {
+		_ = json.Unmarshal(policybytes, &policy)
+	}
+
+	return &policy, nil
+}
+
+// checkBookingAllowed returns an error if the user is blocked from booking or has an unpaid penalty
+func checkBookingAllowed(ctx contractapi.TransactionContextInterface, userId string, now time.Time) error {
+
+	strikes, err := getStrikes(ctx, userId)
+	if err != nil {
+		return err
+	}
+
+	if strikes.UnpaidPenalty > 0 {
+		return fmt.Errorf("no-show penalty of %v tokens is unpaid, call PayNoShowPenalty first", strikes.UnpaidPenalty)
+	}
+
+	if strikes.BlockedUntil != "" {
+		blockedUntil, _ := time.Parse(dateFormat, strikes.BlockedUntil)
+		if now.Before(blockedUntil) {
+			return fmt.Errorf("booking is blocked until %v after repeated no-shows", strikes.BlockedUntil)
+		}
+	}
+
+	return nil
+}
+
+// getStrikes reads the strikes of a user, a user without no-shows has none
+func getStrikes(ctx contractapi.TransactionContextInterface, userId string) (*Strikes, error) {
+
+	strikeKey, err := ctx.GetStub().CreateCompositeKey(strikeIndex, []string{userId})
+	if err != nil {
+		return nil, fmt.Errorf("failed to create the composite key for prefix %s: %v", strikeIndex, err)
+	}
+
+	strikebytes, err := ctx.GetStub().GetState(strikeKey)
+	if err != nil {
+		return nil, fmt.Errorf("error:%v", err)
+	}
+
+	strikes := &Strikes{UserID: userId}
+	if strikebytes != nil {
+		_ = json.Unmarshal(strikebytes, &strikes)
+	}
+
+	return strikes, nil
+}
+
+// putStrikes writes the strikes of a user
+func putStrikes(ctx contractapi.TransactionContextInterface, strikes *Strikes) error {
+
+	strikeKey, err := ctx.GetStub().CreateCompositeKey(strikeIndex, []string{strikes.UserID})
+	if err != nil {
+		return fmt.Errorf("failed to create the composite key for prefix %s: %v", strikeIndex, err)
+	}
+
+	strikebytes, _ := json.Marshal(strikes)
+	err = ctx.GetStub().PutState(strikeKey, strikebytes)
+	if err != nil {
+		return fmt.Errorf("error:%v", err)
+	}
+
+	return nil
+}
@@ -0,0 +1,171 @@
+package healthclub
+
+import (
+	"testing"
+	"time"
+
+	"github.com/hyperledger/fabric-contract-api-go/contractapi"
+	"github.com/varun425/MiniClubChaincode/internal/chaincodetest"
+)
+
+// settle marks the attendance of the class as staff and returns the error of the transaction
+func settle(c *testClub, classId string, attended ...*chaincodetest.Identity) (*ClassSettlement, error) {
+
+	userIds := []string{}
+	for _, user := range attended {
+		userIds = append(userIds, user.ID)
+	}
+
+	var settlement *ClassSettlement
+	_, err := c.invoke(c.staff, func(ctx contractapi.TransactionContextInterface) error {
+		var err error
+		settlement, err = c.h.MarkAttendance(ctx, classId, userIds)
+		return err
+	})
+
+	return settlement, err
+}
+
+// strikes returns the no-show strikes of the user
+func strikes(t *testing.T, c *testClub, user *chaincodetest.Identity) *Strikes {
+
+	t.Helper()
+
+	var strikes *Strikes
+	c.mustInvoke(t, c.staff, func(ctx contractapi.TransactionContextInterface) error {
+		var err error
+		strikes, err = c.h.GetNoShowStrikes(ctx, user.ID)
+		return err
+	})
+
+	return strikes
+}
+
+func TestNoShowsAreStruckAndBlockedFromBooking(t *testing.T) {
+
+	c := newTestClub(t)
+	c.mustInvoke(t, c.owner, func(ctx contractapi.TransactionContextInterface) error {
+		_, err := c.h.SetNoShowPolicy(ctx, forfeitMode, 0, 2, 7)
+		return err
+	})
+
+	alice := c.member(t, "alice", 950)
+	bob := c.member(t, "bob", 950)
+	carol := c.member(t, "carol", 950)
+	for _, member := range []*chaincodetest.Identity{alice, bob, carol} {
+		c.buy(t, member, goldlevel)
+	}
+
+	newClass(t, c, "spin", 2, nil, 10)
+	for _, member := range []*chaincodetest.Identity{alice, carol, bob} {
+		if _, err := bookClass(c, member, "spin"); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if _, err := settle(c, "spin", carol); err == nil {
+		t.Errorf("class settled before it started")
+	}
+
+	c.after(25 * time.Hour)
+	if _, err := settle(c, "spin", bob); err == nil {
+		t.Errorf("attendance marked for a member on the waitlist")
+	}
+
+	settlement, err := settle(c, "spin", carol)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(settlement.NoShows) != 1 || settlement.NoShows[0].UserID != userPrefix+alice.ID || settlement.NoShows[0].Strikes != 1 {
+		t.Errorf("no-shows %+v, want one strike of alice", settlement.NoShows)
+	}
+	if len(settlement.WaitlistRefunded) != 1 || settlement.WaitlistRefunded[0] != userPrefix+bob.ID {
+		t.Errorf("waitlist refunded %v, want bob", settlement.WaitlistRefunded)
+	}
+
+	// the no-show forfeits the booking fee, the waitlist gets it back
+	c.assertBalances(t, map[string]int{alice.ID: 40, bob.ID: 50, carol.ID: 40})
+
+	if _, err := settle(c, "spin"); err == nil {
+		t.Errorf("class settled twice")
+	}
+
+	// the second strike blocks booking for 7 days and starts the count again
+	newClass(t, c, "yoga", 10, nil, 0)
+	if _, err := bookClass(c, alice, "yoga"); err != nil {
+		t.Fatal(err)
+	}
+	c.after(25 * time.Hour)
+	if _, err := settle(c, "yoga"); err != nil {
+		t.Fatal(err)
+	}
+
+	struck := strikes(t, c, alice)
+	if struck.Strikes != 0 || struck.BlockedUntil != c.Now.AddDate(0, 0, 7).Format(dateFormat) {
+		t.Errorf("strikes %+v, want booking blocked for 7 days", struck)
+	}
+
+	newClass(t, c, "pilates", 10, nil, 0)
+	if _, err := bookClass(c, alice, "pilates"); err == nil {
+		t.Errorf("blocked member booked a class")
+	}
+
+	c.after(7 * 24 * time.Hour)
+	newClass(t, c, "boxing", 10, nil, 0)
+	if _, err := bookClass(c, alice, "boxing"); err != nil {
+		t.Errorf("member still blocked after 7 days: %v", err)
+	}
+}
+
+func TestNoShowPenaltyIsChargedOrOwed(t *testing.T) {
+
+	c := newTestClub(t)
+	c.mustInvoke(t, c.owner, func(ctx contractapi.TransactionContextInterface) error {
+		_, err := c.h.SetNoShowPolicy(ctx, penaltyMode, 30, 3, 14)
+		return err
+	})
+
+	alice := c.member(t, "alice", 950)
+	bob := c.member(t, "bob", 950)
+	c.buy(t, alice, goldlevel)
+	c.buy(t, bob, goldlevel)
+
+	// only alice allows the club account to charge penalties
+	c.mustInvoke(t, alice, func(ctx contractapi.TransactionContextInterface) error {
+		return c.h.Approve(ctx, c.owner.ID, 30)
+	})
+
+	newClass(t, c, "spin", 10, nil, 0)
+	for _, member := range []*chaincodetest.Identity{alice, bob} {
+		if _, err := bookClass(c, member, "spin"); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	ownerBalance := c.Balance(c.owner.ID)
+	c.after(25 * time.Hour)
+	if _, err := settle(c, "spin"); err != nil {
+		t.Fatal(err)
+	}
+
+	c.assertBalances(t, map[string]int{alice.ID: 20, bob.ID: 50, c.owner.ID: ownerBalance + 30})
+	if owed := strikes(t, c, bob).UnpaidPenalty; owed != 30 {
+		t.Errorf("bob owes %v, want 30", owed)
+	}
+
+	// an unpaid penalty blocks booking until it is paid
+	newClass(t, c, "yoga", 10, nil, 0)
+	if _, err := bookClass(c, bob, "yoga"); err == nil {
+		t.Errorf("member with an unpaid penalty booked a class")
+	}
+
+	c.mustInvoke(t, bob, func(ctx contractapi.TransactionContextInterface) error {
+		_, err := c.h.PayNoShowPenalty(ctx)
+		return err
+	})
+
+	if _, err := bookClass(c, bob, "yoga"); err != nil {
+		t.Errorf("member not booked after paying the penalty: %v", err)
+	}
+	c.assertBalances(t, map[string]int{bob.ID: 20, c.owner.ID: ownerBalance + 60})
+}
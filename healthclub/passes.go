@@ -0,0 +1,356 @@
+package healthclub
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"strconv"
+	"time"
+
+	"github.com/hyperledger/fabric-contract-api-go/contractapi"
+)
+
+const (
+	passIndex           = "pass~HolderID~PassID"
+	guestPassCountIndex = "guestpasscount~HostID~Month"
+	dayPassPriceKey     = "DayPassPrice"
+	defaultDayPassPrice = 50
+	guestPass           = "guest"
+	dayPass             = "day"
+)
+
+// Pass admits its holder for one visit without a membership until ValidUntil (MM-DD-YYYY, inclusive)
+// Guest passes are issued by a member (the host) from the monthly allowance of the level, day passes are bought.
+// A day pass bought at a branch is valid at the facilities of the branch only
+type Pass struct {
+	PassID     string `json:"passid"`
+	Type       string `json:"type"`
+	HolderID   string `json:"holderid"`
+	HostID     string `json:"hostid"`
+	IssuedOn   string `json:"issuedon"`
+	ValidUntil string `json:"validuntil"`
+	Price      int    `json:"price"`
+	Used       bool   `json:"used"`
+	UsedOn     string `json:"usedon"`
+	FacilityID string `json:"facilityid"`
+	BranchID   string `json:"branchid"`
+}
+
+// IssueGuestPass issues a guest pass for a registered user from the monthly guest pass allowance of the level of the caller
+// The pass is valid until the end of the month and used when the guest checks in. Returns the pass id
+func (h *HealthClub) IssueGuestPass(ctx contractapi.TransactionContextInterface, guestId string) (string, error) {
+
+	userid, err := ctx.GetClientIdentity().GetID()
+	if err != nil {
+		return "", fmt.Errorf("failed to get userID: %v", err)
+	}
+
+	hostId := userPrefix + userid
+	guestId = toUserKey(guestId)
+
+	if guestId == hostId {
+		return "", fmt.Errorf("cannot issue a guest pass to yourself")
+	}
+
+	if _, err := getUser(ctx, guestId); err != nil {
+		return "", err
+	}
+
+	now, err := getTxTime(ctx)
+	if err != nil {
+		return "", fmt.Errorf("error:%v", err)
+	}
+
+	_, membershipdetails, err := getCurrentMembership(ctx, hostId)
+	if err != nil {
+		return "", fmt.Errorf("error:%v", err)
+	}
+
+	err = checkMembershipAccess(membershipdetails, now)
+	if err != nil {
+		return "", fmt.Errorf("cannot issue guest pass: %v", err)
+	}
+
+	leveldetails, err := h.GetLevelDetails(ctx, membershipdetails.Level)
+	if err != nil {
+		return "", fmt.Errorf("error:%v", err)
+	}
+
+	month := now.Format("2006-01")
+	countKey, err := ctx.GetStub().CreateCompositeKey(guestPassCountIndex, []string{hostId, month})
+	if err != nil {
+		return "", fmt.Errorf("failed to create the composite key for prefix %s: %v", guestPassCountIndex, err)
+	}
+
+	countbytes, err := ctx.GetStub().GetState(countKey)
+	if err != nil {
+		return "", fmt.Errorf("error:%v", err)
+	}
+
+	issued, _ := strconv.Atoi(string(countbytes))
+	if issued >= leveldetails.GuestPasses {
+		return "", fmt.Errorf("%v memberships include %v guest passes per month, %v already issued", membershipdetails.Level, leveldetails.GuestPasses, issued)
+	}
+
+	err = ctx.GetStub().PutState(countKey, []byte(strconv.Itoa(issued+1)))
+	if err != nil {
+		return "", fmt.Errorf("error:%v", err)
+	}
+
+	monthStart := time.Date(now.Year(), now.Month(), 1, 0, 0, 0, 0, time.UTC)
+
+	pass := &Pass{
+		PassID:     ctx.GetStub().GetTxID(),
+		Type:       guestPass,
+		HolderID:   guestId,
+		HostID:     hostId,
+		IssuedOn:   now.Format(dateFormat),
+		ValidUntil: monthStart.AddDate(0, 1, -1).Format(dateFormat),
+	}
+
+	err = putPass(ctx, pass)
+	if err != nil {
+		return "", err
+	}
+
+	log.Printf("%v issued guest pass %v to %v", hostId, pass.PassID, guestId)
+
+	return pass.PassID, nil
+}
+
+// BuyDayPass buys the caller a single visit on the given date (MM-DD-YYYY) at the day pass price. Returns the pass id
+func (h *HealthClub) BuyDayPass(ctx contractapi.TransactionContextInterface, date string) (string, error) {
+	return h.buyDayPass(ctx, date, "")
+}
+
+// buyDayPass buys the caller a day pass of the branch, paid to the revenue account of the branch
+func (h *HealthClub) buyDayPass(ctx contractapi.TransactionContextInterface, date string, branchId string) (string, error) {
+
+	userid, err := ctx.GetClientIdentity().GetID()
+	if err != nil {
+		return "", fmt.Errorf("failed to get userID: %v", err)
+	}
+
+	userId := userPrefix + userid
+
+	if _, err := getUser(ctx, userId); err != nil {
+		return "", err
+	}
+
+	day, err := time.Parse(dateFormat, date)
+	if err != nil {
+		return "", fmt.Errorf("invalid date %v, expected MM-DD-YYYY", date)
+	}
+
+	now, err := getTxTime(ctx)
+	if err != nil {
+		return "", fmt.Errorf("error:%v", err)
+	}
+
+	if day.Format(keyDateFormat) < now.Format(keyDateFormat) {
+		return "", fmt.Errorf("day pass date %v has passed", date)
+	}
+
+	price, err := h.GetDayPassPrice(ctx)
+	if err != nil {
+		return "", err
+	}
+
+	pass := &Pass{
+		PassID:     ctx.GetStub().GetTxID(),
+		Type:       dayPass,
+		HolderID:   userId,
+		IssuedOn:   now.Format(dateFormat),
+		ValidUntil: date,
+		Price:      price,
+		BranchID:   branchId,
+	}
+
+	err = putPass(ctx, pass)
+	if err != nil {
+		return "", err
+	}
+
+	if price > 0 {
+		err = h.collectRevenue(ctx, branchId, revenueDayPass, price, now)
+		if err != nil {
+			return "", err
+		}
+	}
+
+	log.Printf("%v bought day pass %v for %v", userId, pass.PassID, date)
+
+	return pass.PassID, nil
+}
+
+// GetPasses returns the guest and day passes held by the user
+func (h *HealthClub) GetPasses(ctx contractapi.TransactionContextInterface, userId string) ([]Pass, error) {
+	return getPasses(ctx, toUserKey(userId))
+}
+
+// SetDayPassPrice sets the price of a day pass in tokens
+func (h *HealthClub) SetDayPassPrice(ctx contractapi.TransactionContextInterface, price int) (string, error) {
+
+	err := checkOwner(ctx)
+	if err != nil {
+		return "", err
+	}
+
+	if price < 0 {
+		return "", fmt.Errorf("price cannot be negative")
+	}
+
+	err = ctx.GetStub().PutState(dayPassPriceKey, []byte(strconv.Itoa(price)))
+	if err != nil {
+		return "", fmt.Errorf("error:%v", err)
+	}
+
+	return "day pass price is updated", nil
+}
+
+// GetDayPassPrice returns the price of a day pass in tokens
+func (h *HealthClub) GetDayPassPrice(ctx contractapi.TransactionContextInterface) (int, error) {
+
+	pricebytes, err := ctx.GetStub().GetState(dayPassPriceKey)
+	if err != nil {
+		return 0, fmt.Errorf("error:%v", err)
+	}
+
+	if pricebytes == nil {
+		return defaultDayPassPrice, nil
+	}
+
+	price, _ := strconv.Atoi(string(pricebytes))
+
+	return price, nil
+}
+
+// UpdateMembershipLevelGuestPasses sets the number of guest passes members of the level can issue per month
+func (h *HealthClub) UpdateMembershipLevelGuestPasses(ctx contractapi.TransactionContextInterface, level string, guestPasses int) (string, error) {
+
+	err := checkOwner(ctx)
+	if err != nil {
+		return "", err
+	}
+
+	if guestPasses < 0 {
+		return "", fmt.Errorf("guest passes cannot be negative")
+	}
+
+	if level != goldlevel && level != diamondlevel && level != platinumlevel {
+		return "", fmt.Errorf("only Gold, Diamond, and Platinum levels are acceptable")
+	}
+
+	leveldetails, err := h.GetLevelDetails(ctx, level)
+	if err != nil {
+		return "", fmt.Errorf("error:%v", err)
+	}
+
+	leveldetails.GuestPasses = guestPasses
+
+	resInBytes, _ := json.Marshal(leveldetails)
+	err = ctx.GetStub().PutState(level, resInBytes)
+	if err != nil {
+		return "", fmt.Errorf("error:%v", err)
+	}
+
+	log.Printf("The %v level is updated to %v guest passes per month", level, guestPasses)
+
+	return "level is updated", nil
+}
+
+// usePass marks the first unused pass of the holder that is valid at the given time as used at the facility
+func usePass(ctx contractapi.TransactionContextInterface, holderId string, facilityId string, now time.Time) (*Pass, error) {
+
+	passes, err := getPasses(ctx, holderId)
+	if err != nil {
+		return nil, err
+	}
+
+	today := now.Format(keyDateFormat)
+
+	for _, pass := range passes {
+
+		if pass.Used {
+			continue
+		}
+
+		validUntil, _ := time.Parse(dateFormat, pass.ValidUntil)
+		if today > validUntil.Format(keyDateFormat) {
+			continue
+		}
+
+		// day passes are only valid on their day
+		if pass.Type == dayPass && today != validUntil.Format(keyDateFormat) {
+			continue
+		}
+
+		if pass.BranchID != "" {
+			facilityBranch, err := getFacilityBranch(ctx, facilityId)
+			if err != nil {
+				return nil, err
+			}
+
+			if facilityBranch != "" && facilityBranch != pass.BranchID {
+				continue
+			}
+		}
+
+		pass.Used = true
+		pass.UsedOn = now.Format(timeFormat)
+		pass.FacilityID = facilityId
+
+		err = putPass(ctx, &pass)
+		if err != nil {
+			return nil, err
+		}
+
+		log.Printf("%v used %v pass %v", holderId, pass.Type, pass.PassID)
+
+		return &pass, nil
+	}
+
+	return nil, fmt.Errorf("no valid pass")
+}
+
+// getPasses reads the passes held by the user
+func getPasses(ctx contractapi.TransactionContextInterface, holderId string) ([]Pass, error) {
+
+	passIterator, err := ctx.GetStub().GetStateByPartialCompositeKey(passIndex, []string{holderId})
+	if err != nil {
+		return nil, fmt.Errorf("error:%v", err.Error())
+	}
+
+	defer passIterator.Close()
+
+	passes := []Pass{}
+	for passIterator.HasNext() {
+		responseRange, err := passIterator.Next()
+		if err != nil {
+			return nil, fmt.Errorf("error:%v", err.Error())
+		}
+
+		pass := new(Pass)
+		_ = json.Unmarshal(responseRange.Value, &pass)
+		passes = append(passes, *pass)
+	}
+
+	return passes, nil
+}
+
+// putPass writes a pass to the world state
+func putPass(ctx contractapi.TransactionContextInterface, pass *Pass) error {
+
+	passKey, err := ctx.GetStub().CreateCompositeKey(passIndex, []string{pass.HolderID, pass.PassID})
+	if err != nil {
+		return fmt.Errorf("failed to create the composite key for prefix %s: %v", passIndex, err)
+	}
+
+	passbytes, _ := json.Marshal(pass)
+	err = ctx.GetStub().PutState(passKey, passbytes)
+	if err != nil {
+		return fmt.Errorf("error:%v", err)
+	}
+
+	return nil
+}
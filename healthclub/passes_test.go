@@ -0,0 +1,86 @@
+package healthclub
+
+import (
+	"testing"
+	"time"
+
+	"github.com/hyperledger/fabric-contract-api-go/contractapi"
+)
+
+func TestGuestPassesAdmitTheGuestsOfTheHost(t *testing.T) {
+
+	c := newTestClub(t)
+	bob := c.member(t, "bob", 4900)
+	dave := c.member(t, "dave", 0)
+	c.buy(t, bob, platinumlevel)
+
+	// Platinum includes 2 guest passes per month
+	for i := 0; i < 3; i++ {
+		_, err := c.invoke(bob, func(ctx contractapi.TransactionContextInterface) error {
+			_, err := c.h.IssueGuestPass(ctx, dave.ID)
+			return err
+		})
+		if i < 2 && err != nil {
+			t.Fatalf("guest pass %v not issued: %v", i+1, err)
+		}
+		if i == 2 && err == nil {
+			t.Errorf("guest pass issued beyond the monthly allowance")
+		}
+	}
+
+	for i := 0; i < 2; i++ {
+		visit(t, c, dave, "gym")
+	}
+
+	if err := checkIn(c, dave, "gym"); err == nil {
+		t.Errorf("guest checked in after using every pass")
+	}
+
+	attendance := visits(t, c, dave)
+	if len(attendance) != 2 {
+		t.Fatalf("guest has %v visits, want 2", len(attendance))
+	}
+	for _, visit := range attendance {
+		if visit.PassID == "" || visit.HostedBy != userPrefix+bob.ID || visit.MembershipID != "" {
+			t.Errorf("guest visit on pass %q hosted by %q with membership %q, want a pass hosted by %v", visit.PassID, visit.HostedBy, visit.MembershipID, bob.ID)
+		}
+	}
+}
+
+func TestDayPassIsValidOnItsDayOnly(t *testing.T) {
+
+	c := newTestClub(t)
+	dave := c.member(t, "dave", 0)
+
+	if _, err := c.invoke(dave, func(ctx contractapi.TransactionContextInterface) error {
+		_, err := c.h.BuyDayPass(ctx, testStart.AddDate(0, 0, -1).Format(dateFormat))
+		return err
+	}); err == nil {
+		t.Errorf("day pass bought for a day that has passed")
+	}
+
+	c.mustInvoke(t, dave, func(ctx contractapi.TransactionContextInterface) error {
+		_, err := c.h.BuyDayPass(ctx, testStart.AddDate(0, 0, 1).Format(dateFormat))
+		return err
+	})
+	c.assertBalances(t, map[string]int{dave.ID: 100 - defaultDayPassPrice, c.owner.ID: defaultDayPassPrice})
+
+	if err := checkIn(c, dave, "gym"); err == nil {
+		t.Errorf("day pass used the day before its day")
+	}
+
+	c.after(24 * time.Hour)
+	if err := checkIn(c, dave, "gym"); err != nil {
+		t.Errorf("day pass refused on its day: %v", err)
+	}
+
+	var summary *RevenueSummary
+	c.mustInvoke(t, c.owner, func(ctx contractapi.TransactionContextInterface) error {
+		var err error
+		summary, err = c.h.RevenueReport(ctx, "", testStart.Format(dateFormat), testStart.Format(dateFormat))
+		return err
+	})
+	if summary.Gross != defaultDayPassPrice {
+		t.Errorf("day pass sales of %v booked, want %v", summary.Gross, defaultDayPassPrice)
+	}
+}
@@ -0,0 +1,276 @@
+package healthclub
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/hyperledger/fabric-contract-api-go/contractapi"
+)
+
+const (
+	promoCodeIndex       = "promocode~Code"
+	promoRedemptionIndex = "promoredemption~Code~UserID"
+)
+
+// PromoCode discounts membership purchases and upgrades of the Levels (all levels if empty) between ValidFrom and ValidTo
+// Either PercentOff or FixedOff tokens is taken off the price, each user can redeem a code once
+type PromoCode struct {
+	Code           string   `json:"code"`
+	PercentOff     int      `json:"percentoff"`
+	FixedOff       int      `json:"fixedoff"`
+	Levels         []string `json:"levels"`
+	MaxRedemptions int      `json:"maxredemptions"`
+	Redemptions    int      `json:"redemptions"`
+	ValidFrom      string   `json:"validfrom"`
+	ValidTo        string   `json:"validto"`
+	Active         bool     `json:"active"`
+}
+
+// PromoRedemption records a user redeeming a promo code
+type PromoRedemption struct {
+	Code     string `json:"code"`
+	UserID   string `json:"userid"`
+	Level    string `json:"level"`
+	Discount int    `json:"discount"`
+	Date     string `json:"date"`
+}
+
+// CreatePromoCode creates a promo code taking either percentOff percent or fixedOff tokens off the price
+// levels restricts the code to those levels, an empty list applies it to every level. validFrom and validTo are MM-DD-YYYY, both inclusive
+func (h *HealthClub) CreatePromoCode(ctx contractapi.TransactionContextInterface, code string, percentOff int, fixedOff int, levels []string, maxRedemptions int, validFrom string, validTo string) (string, error) {
+
+	err := checkOwner(ctx)
+	if err != nil {
+		return "", err
+	}
+
+	if code == "" {
+		return "", fmt.Errorf("code is required")
+	}
+
+	if (percentOff > 0) == (fixedOff > 0) || percentOff < 0 || fixedOff < 0 {
+		return "", fmt.Errorf("exactly one of percent off and fixed off must be a positive integer")
+	}
+
+	if percentOff > 100 {
+		return "", fmt.Errorf("percent off cannot exceed 100")
+	}
+
+	if maxRedemptions <= 0 {
+		return "", fmt.Errorf("max redemptions must be a positive integer")
+	}
+
+	for _, level := range levels {
+		if level != goldlevel && level != diamondlevel && level != platinumlevel {
+			return "", fmt.Errorf("only Gold, Diamond, and Platinum levels are acceptable")
+		}
+	}
+
+	fromDate, err := time.Parse(dateFormat, validFrom)
+	if err != nil {
+		return "", fmt.Errorf("invalid valid from date %v, expected MM-DD-YYYY", validFrom)
+	}
+
+	toDate, err := time.Parse(dateFormat, validTo)
+	if err != nil {
+		return "", fmt.Errorf("invalid valid to date %v, expected MM-DD-YYYY", validTo)
+	}
+
+	if toDate.Before(fromDate) {
+		return "", fmt.Errorf("valid to date cannot be before valid from date")
+	}
+
+	if _, err := getPromoCode(ctx, code); err == nil {
+		return "", fmt.Errorf("promo code %v already exists", code)
+	}
+
+	promo := &PromoCode{
+		Code:           code,
+		PercentOff:     percentOff,
+		FixedOff:       fixedOff,
+		Levels:         levels,
+		MaxRedemptions: maxRedemptions,
+		ValidFrom:      validFrom,
+		ValidTo:        validTo,
+		Active:         true,
+	}
+
+	err = putPromoCode(ctx, promo)
+	if err != nil {
+		return "", err
+	}
+
+	log.Printf("promo code %v valid from %v to %v for %v redemptions", code, validFrom, validTo, maxRedemptions)
+
+	return "promo code is created", nil
+}
+
+// DisablePromoCode stops a promo code from being redeemed
+func (h *HealthClub) DisablePromoCode(ctx contractapi.TransactionContextInterface, code string) (string, error) {
+
+	err := checkOwner(ctx)
+	if err != nil {
+		return "", err
+	}
+
+	promo, err := getPromoCode(ctx, code)
+	if err != nil {
+		return "", err
+	}
+
+	promo.Active = false
+
+	err = putPromoCode(ctx, promo)
+	if err != nil {
+		return "", err
+	}
+
+	return "promo code is disabled", nil
+}
+
+// GetPromoCode returns a promo code with its number of redemptions
+func (h *HealthClub) GetPromoCode(ctx contractapi.TransactionContextInterface, code string) (*PromoCode, error) {
+	return getPromoCode(ctx, code)
+}
+
+// GetPromoRedemptions returns the redemptions of a promo code
+func (h *HealthClub) GetPromoRedemptions(ctx contractapi.TransactionContextInterface, code string) ([]PromoRedemption, error) {
+
+	redemptionIterator, err := ctx.GetStub().GetStateByPartialCompositeKey(promoRedemptionIndex, []string{code})
+	if err != nil {
+		return nil, fmt.Errorf("error:%v", err.Error())
+	}
+
+	defer redemptionIterator.Close()
+
+	redemptions := []PromoRedemption{}
+	for redemptionIterator.HasNext() {
+		responseRange, err := redemptionIterator.Next()
+		if err != nil {
+			return nil, fmt.Errorf("error:%v", err.Error())
+		}
+
+		redemption := new(PromoRedemption)
+		_ = json.Unmarshal(responseRange.Value, &redemption)
+		redemptions = append(redemptions, *redemption)
+	}
+
+	return redemptions, nil
+}
+
+// redeemPromoCode validates the promo code for the user and level, records the redemption and returns the discount on the price
+func redeemPromoCode(ctx contractapi.TransactionContextInterface, code string, userId string, level string, price int, now time.Time) (int, error) {
+
+	promo, err := getPromoCode(ctx, code)
+	if err != nil {
+		return 0, err
+	}
+
+	if !promo.Active {
+		return 0, fmt.Errorf("promo code %v is disabled", code)
+	}
+
+	fromDate, _ := time.Parse(dateFormat, promo.ValidFrom)
+	toDate, _ := time.Parse(dateFormat, promo.ValidTo)
+	if now.Before(fromDate) || !now.Before(toDate.AddDate(0, 0, 1)) {
+		return 0, fmt.Errorf("promo code %v is valid from %v to %v", code, promo.ValidFrom, promo.ValidTo)
+	}
+
+	if len(promo.Levels) != 0 && !containsUser(promo.Levels, level) {
+		return 0, fmt.Errorf("promo code %v does not apply to %v level", code, level)
+	}
+
+	if promo.Redemptions >= promo.MaxRedemptions {
+		return 0, fmt.Errorf("promo code %v is fully redeemed", code)
+	}
+
+	redemptionKey, err := ctx.GetStub().CreateCompositeKey(promoRedemptionIndex, []string{code, userId})
+	if err != nil {
+		return 0, fmt.Errorf("failed to create the composite key for prefix %s: %v", promoRedemptionIndex, err)
+	}
+
+	existing, err := ctx.GetStub().GetState(redemptionKey)
+	if err != nil {
+		return 0, fmt.Errorf("error:%v", err)
+	}
+
+	if existing != nil {
+		return 0, fmt.Errorf("promo code %v is already redeemed by %v", code, userId)
+	}
+
+	discount := promo.FixedOff
+	if promo.PercentOff > 0 {
+		discount = (price * promo.PercentOff) / 100
+	}
+
+	if discount > price {
+		discount = price
+	}
+
+	redemption := PromoRedemption{
+		Code:     code,
+		UserID:   userId,
+		Level:    level,
+		Discount: discount,
+		Date:     now.Format(dateFormat),
+	}
+
+	redemptionbytes, _ := json.Marshal(redemption)
+	err = ctx.GetStub().PutState(redemptionKey, redemptionbytes)
+	if err != nil {
+		return 0, fmt.Errorf("error:%v", err)
+	}
+
+	promo.Redemptions = promo.Redemptions + 1
+
+	err = putPromoCode(ctx, promo)
+	if err != nil {
+		return 0, err
+	}
+
+	log.Printf("%v redeemed promo code %v for %v tokens off", userId, code, discount)
+
+	return discount, nil
+}
+
+// getPromoCode reads a promo code from the world state
+func getPromoCode(ctx contractapi.TransactionContextInterface, code string) (*PromoCode, error) {
+
+	promoKey, err := ctx.GetStub().CreateCompositeKey(promoCodeIndex, []string{code})
+	if err != nil {
+		return nil, fmt.Errorf("failed to create the composite key for prefix %s: %v", promoCodeIndex, err)
+	}
+
+	promobytes, err := ctx.GetStub().GetState(promoKey)
+	if err != nil {
+		return nil, fmt.Errorf("error:%v", err)
+	}
+
+	if promobytes == nil {
+		return nil, fmt.Errorf("promo code %v not found", code)
+	}
+
+	promo := new(PromoCode)
+	_ = json.Unmarshal(promobytes, &promo)
+
+	return promo, nil
+}
+
+// putPromoCode writes a promo code to the world state
+func putPromoCode(ctx contractapi.TransactionContextInterface, promo *PromoCode) error {
+
+	promoKey, err := ctx.GetStub().CreateCompositeKey(promoCodeIndex, []string{promo.Code})
+	if err != nil {
+		return fmt.Errorf("failed to create the composite key for prefix %s: %v", promoCodeIndex, err)
+	}
+
+	promobytes, _ := json.Marshal(promo)
+	err = ctx.GetStub().PutState(promoKey, promobytes)
+	if err != nil {
+		return fmt.Errorf("error:%v", err)
+	}
+
+	return nil
+}
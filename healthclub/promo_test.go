@@ -0,0 +1,141 @@
+package healthclub
+
+import (
+	"testing"
+	"time"
+
+	"github.com/hyperledger/fabric-contract-api-go/contractapi"
+	"github.com/varun425/MiniClubChaincode/internal/chaincodetest"
+)
+
+// newPromoCode creates a promo code valid for 30 days from the current timestamp
+func newPromoCode(t *testing.T, c *testClub, code string, percentOff int, fixedOff int, levels []string, maxRedemptions int) {
+
+	t.Helper()
+
+	c.mustInvoke(t, c.owner, func(ctx contractapi.TransactionContextInterface) error {
+		_, err := c.h.CreatePromoCode(ctx, code, percentOff, fixedOff, levels, maxRedemptions, c.Now.Format(dateFormat), c.Now.AddDate(0, 0, 30).Format(dateFormat))
+		return err
+	})
+}
+
+// buyWithPromo buys a membership of the level with the promo code and returns the error of the transaction
+func buyWithPromo(c *testClub, member *chaincodetest.Identity, level string, code string) error {
+
+	_, err := c.invoke(member, func(ctx contractapi.TransactionContextInterface) error {
+		_, err := c.h.GetNewMemberShipWithPromo(ctx, level, code)
+		return err
+	})
+
+	return err
+}
+
+func TestPromoCodeDiscountsPurchasesWithinItsLimits(t *testing.T) {
+
+	c := newTestClub(t)
+	newPromoCode(t, c, "SPRING", 20, 0, []string{goldlevel}, 2)
+
+	alice := c.member(t, "alice", 900)
+	bob := c.member(t, "bob", 4900)
+	carol := c.member(t, "carol", 900)
+
+	if err := buyWithPromo(c, bob, platinumlevel, "SPRING"); err == nil {
+		t.Errorf("Gold promo code redeemed on a Platinum membership")
+	}
+
+	for _, member := range []*chaincodetest.Identity{alice, bob} {
+		if err := buyWithPromo(c, member, goldlevel, "SPRING"); err != nil {
+			t.Fatalf("promo code not redeemed by %v: %v", member.ID, err)
+		}
+	}
+
+	if err := buyWithPromo(c, carol, goldlevel, "SPRING"); err == nil {
+		t.Errorf("promo code redeemed beyond its 2 redemptions")
+	}
+
+	// 20% off the 1000 tokens of Gold
+	c.assertBalances(t, map[string]int{alice.ID: 200, bob.ID: 4200, carol.ID: 1000})
+
+	membershipId := c.user(t, userPrefix+alice.ID).Memberships[0]
+	membership := c.membership(t, membershipId)
+	if membership.PromoCode != "SPRING" || membership.DiscountApplied != 200 || membership.TokenDeposited != 800 {
+		t.Errorf("membership with promo %v, discount %v and deposit %v, want SPRING, 200 and 800", membership.PromoCode, membership.DiscountApplied, membership.TokenDeposited)
+	}
+
+	var redemptions []PromoRedemption
+	c.mustInvoke(t, c.owner, func(ctx contractapi.TransactionContextInterface) error {
+		var err error
+		redemptions, err = c.h.GetPromoRedemptions(ctx, "SPRING")
+		return err
+	})
+	if len(redemptions) != 2 {
+		t.Errorf("%v redemptions, want 2", len(redemptions))
+	}
+}
+
+func TestPromoCodeIsRedeemedOnceByEachUserWhileValid(t *testing.T) {
+
+	c := newTestClub(t)
+	newPromoCode(t, c, "WELCOME", 0, 300, nil, 10)
+
+	alice := c.member(t, "alice", 4900)
+	bob := c.member(t, "bob", 900)
+
+	if err := buyWithPromo(c, alice, goldlevel, "WELCOME"); err != nil {
+		t.Fatal(err)
+	}
+
+	// the same user cannot take the discount again on an upgrade
+	_, err := c.invoke(alice, func(ctx contractapi.TransactionContextInterface) error {
+		_, err := c.h.UpgradeMembershipWithPromo(ctx, platinumlevel, "WELCOME")
+		return err
+	})
+	if err == nil {
+		t.Errorf("promo code redeemed twice by the same user")
+	}
+
+	c.mustInvoke(t, c.owner, func(ctx contractapi.TransactionContextInterface) error {
+		_, err := c.h.DisablePromoCode(ctx, "WELCOME")
+		return err
+	})
+	if err := buyWithPromo(c, bob, goldlevel, "WELCOME"); err == nil {
+		t.Errorf("disabled promo code redeemed")
+	}
+
+	newPromoCode(t, c, "LATE", 0, 100, nil, 10)
+	c.after(31 * 24 * time.Hour)
+	if err := buyWithPromo(c, bob, goldlevel, "LATE"); err == nil {
+		t.Errorf("promo code redeemed after its validity")
+	}
+
+	c.assertBalances(t, map[string]int{alice.ID: 4300, bob.ID: 1000})
+}
+
+func TestPromoCodeDiscountsTheAmountDueOnUpgrade(t *testing.T) {
+
+	c := newTestClub(t)
+	newPromoCode(t, c, "UPGRADE", 50, 0, []string{platinumlevel}, 10)
+
+	alice := c.member(t, "alice", 4900)
+	c.buy(t, alice, goldlevel)
+
+	var quote *UpgradeQuote
+	c.mustInvoke(t, alice, func(ctx contractapi.TransactionContextInterface) error {
+		var err error
+		quote, err = c.h.QuoteUpgrade(ctx, platinumlevel)
+		return err
+	})
+
+	c.mustInvoke(t, alice, func(ctx contractapi.TransactionContextInterface) error {
+		_, err := c.h.UpgradeMembershipWithPromo(ctx, platinumlevel, "UPGRADE")
+		return err
+	})
+
+	discount := quote.AmountDue / 2
+	c.assertBalances(t, map[string]int{alice.ID: 4000 - quote.AmountDue + discount})
+
+	membership := c.membership(t, c.user(t, userPrefix+alice.ID).Memberships[0])
+	if membership.Level != platinumlevel || membership.DiscountApplied != discount {
+		t.Errorf("%v membership with discount %v, want Platinum with %v", membership.Level, membership.DiscountApplied, discount)
+	}
+}
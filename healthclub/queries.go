@@ -0,0 +1,516 @@
+package healthclub
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/hyperledger/fabric-contract-api-go/contractapi"
+)
+
+const (
+	// maxPageSize caps the records read by one page of a paginated query
+	maxPageSize = 200
+
+	// docType values stored on users and memberships so that rich queries can tell them apart
+	userDocType       = "user"
+	membershipDocType = "membership"
+)
+
+// membershipQueryFields and userQueryFields whitelist the fields a rich query selector may use, the indexes shipped in
+// META-INF/statedb/couchdb/indexes cover the common searches. Dates are stored as MM-DD-YYYY, ranges of dates are
+// selected on the YYYY-MM-DD copies StartDateKey and EndDateKey
+var (
+	membershipQueryFields = []string{"level", "Status", "StartDate", "EndDate", "StartDateKey", "EndDateKey", "UserID", "BranchID", "AutoRenew", "SponsoredBy"}
+	userQueryFields       = []string{"name", "email", "referredby", "groupof", "corporateid"}
+	queryOperators        = []string{"$eq", "$ne", "$gt", "$gte", "$lt", "$lte", "$in", "$nin", "$exists"}
+)
+
+// UserMigration is the outcome of one MigrateUsers page
+// Bookmark is the last user key scanned and is empty once all users have been scanned
+type UserMigration struct {
+	Migrated int    `json:"migrated"`
+	Bookmark string `json:"bookmark"`
+}
+
+// MembershipPage is one page of memberships matching a filter
+// FetchedRecordsCount is the number of records read for the page, including those the filter rejected.
+// Bookmark continues the query on the next page and is empty after the last page
+type MembershipPage struct {
+	Memberships         []MembershipView `json:"memberships"`
+	FetchedRecordsCount int              `json:"fetchedrecordscount"`
+	Bookmark            string           `json:"bookmark"`
+}
+
+// UserPage is one page of users whose current membership matches a filter
+type UserPage struct {
+	Users               []UserView `json:"users"`
+	FetchedRecordsCount int        `json:"fetchedrecordscount"`
+	Bookmark            string     `json:"bookmark"`
+}
+
+// membershipFilter selects memberships by level, status and a range of start dates (YYYY-MM-DD), empty fields match everything
+type membershipFilter struct {
+	Level  string
+	Status string
+	From   string
+	To     string
+}
+
+// GetMembershipsPage returns up to pageSize memberships after the bookmark, empty for the first page, that match the filter, staff only
+// level and status are optional, from and to (MM-DD-YYYY, inclusive) optionally restrict the start date
+func (h *HealthClub) GetMembershipsPage(ctx contractapi.TransactionContextInterface, level string, status string, from string, to string, pageSize int, bookmark string) (*MembershipPage, error) {
+
+	err := checkStaff(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	filter, err := newMembershipFilter(level, status, from, to)
+	if err != nil {
+		return nil, err
+	}
+
+	if pageSize <= 0 || pageSize > maxPageSize {
+		return nil, fmt.Errorf("page size must be between 1 and %v", maxPageSize)
+	}
+
+	membershipIterator, metadata, err := ctx.GetStub().GetStateByRangeWithPagination(membershipPrefix, membershipPrefix+string(utf8.MaxRune), int32(pageSize), bookmark)
+	if err != nil {
+		return nil, fmt.Errorf("error:%v", err.Error())
+	}
+
+	defer membershipIterator.Close()
+
+	now, err := getTxTime(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("error:%v", err)
+	}
+
+	page := &MembershipPage{
+		Memberships:         []MembershipView{},
+		FetchedRecordsCount: int(metadata.FetchedRecordsCount),
+		Bookmark:            metadata.Bookmark,
+	}
+
+	for membershipIterator.HasNext() {
+		responseRange, err := membershipIterator.Next()
+		if err != nil {
+			return nil, fmt.Errorf("error:%v", err.Error())
+		}
+
+		membership := new(Membership)
+		_ = json.Unmarshal(responseRange.Value, &membership)
+
+		if membership.Status == "" {
+			migrateMembershipStatus(responseRange.Value, membership)
+		}
+
+		if filter.matches(membership) {
+			page.Memberships = append(page.Memberships, newMembershipView(responseRange.Key, membership, now))
+		}
+	}
+
+	return page, nil
+}
+
+// GetUsersPage returns up to pageSize users after the bookmark, empty for the first page, whose current membership matches the filter, staff only
+// Without level, status, from and to every user matches, including users without membership
+func (h *HealthClub) GetUsersPage(ctx contractapi.TransactionContextInterface, level string, status string, from string, to string, pageSize int, bookmark string) (*UserPage, error) {
+
+	err := checkStaff(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	filter, err := newMembershipFilter(level, status, from, to)
+	if err != nil {
+		return nil, err
+	}
+
+	if pageSize <= 0 || pageSize > maxPageSize {
+		return nil, fmt.Errorf("page size must be between 1 and %v", maxPageSize)
+	}
+
+	userIterator, metadata, err := ctx.GetStub().GetStateByRangeWithPagination(userPrefix, userPrefix+string(utf8.MaxRune), int32(pageSize), bookmark)
+	if err != nil {
+		return nil, fmt.Errorf("error:%v", err.Error())
+	}
+
+	defer userIterator.Close()
+
+	now, err := getTxTime(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("error:%v", err)
+	}
+
+	page := &UserPage{
+		Users:               []UserView{},
+		FetchedRecordsCount: int(metadata.FetchedRecordsCount),
+		Bookmark:            metadata.Bookmark,
+	}
+
+	for userIterator.HasNext() {
+		responseRange, err := userIterator.Next()
+		if err != nil {
+			return nil, fmt.Errorf("error:%v", err.Error())
+		}
+
+		user := new(User)
+		_ = json.Unmarshal(responseRange.Value, &user)
+
+		if !filter.isEmpty() {
+			if len(user.Memberships) == 0 {
+				continue
+			}
+
+			membership, err := getMembership(ctx, user.Memberships[len(user.Memberships)-1])
+			if err != nil {
+				return nil, err
+			}
+
+			if !filter.matches(membership) {
+				continue
+			}
+		}
+
+		view, err := newUserView(ctx, responseRange.Key, user, now)
+		if err != nil {
+			return nil, err
+		}
+
+		page.Users = append(page.Users, view)
+	}
+
+	return page, nil
+}
+
+// QueryMemberships returns up to pageSize memberships after the bookmark that match a CouchDB selector, staff only
+// selectorJSON may only use whitelisted fields, operators and $and, $or, $not,
+// e.g. {"level":"Diamond","EndDateKey":{"$gte":"2026-11-01","$lt":"2026-12-01"}}.
+// Memberships written before docType and the date keys were introduced are found once migrated, see MigrateMemberships
+func (h *HealthClub) QueryMemberships(ctx contractapi.TransactionContextInterface, selectorJSON string, pageSize int, bookmark string) (*MembershipPage, error) {
+
+	query, err := buildRichQuery(ctx, selectorJSON, membershipDocType, membershipQueryFields, pageSize)
+	if err != nil {
+		return nil, err
+	}
+
+	membershipIterator, metadata, err := ctx.GetStub().GetQueryResultWithPagination(query, int32(pageSize), bookmark)
+	if err != nil {
+		return nil, fmt.Errorf("error:%v", err.Error())
+	}
+
+	defer membershipIterator.Close()
+
+	now, err := getTxTime(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("error:%v", err)
+	}
+
+	page := &MembershipPage{
+		Memberships:         []MembershipView{},
+		FetchedRecordsCount: int(metadata.FetchedRecordsCount),
+		Bookmark:            metadata.Bookmark,
+	}
+
+	for membershipIterator.HasNext() {
+		responseRange, err := membershipIterator.Next()
+		if err != nil {
+			return nil, fmt.Errorf("error:%v", err.Error())
+		}
+
+		if !strings.HasPrefix(responseRange.Key, membershipPrefix) {
+			continue
+		}
+
+		membership := new(Membership)
+		_ = json.Unmarshal(responseRange.Value, &membership)
+		page.Memberships = append(page.Memberships, newMembershipView(responseRange.Key, membership, now))
+	}
+
+	return page, nil
+}
+
+// QueryUsers returns up to pageSize users after the bookmark that match a CouchDB selector, staff only
+// selectorJSON may only use whitelisted fields, operators and $and, $or, $not, e.g. {"corporateid":"acme"}.
+// Users written before docType was introduced are found once migrated, see MigrateUsers
+func (h *HealthClub) QueryUsers(ctx contractapi.TransactionContextInterface, selectorJSON string, pageSize int, bookmark string) (*UserPage, error) {
+
+	query, err := buildRichQuery(ctx, selectorJSON, userDocType, userQueryFields, pageSize)
+	if err != nil {
+		return nil, err
+	}
+
+	userIterator, metadata, err := ctx.GetStub().GetQueryResultWithPagination(query, int32(pageSize), bookmark)
+	if err != nil {
+		return nil, fmt.Errorf("error:%v", err.Error())
+	}
+
+	defer userIterator.Close()
+
+	now, err := getTxTime(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("error:%v", err)
+	}
+
+	page := &UserPage{
+		Users:               []UserView{},
+		FetchedRecordsCount: int(metadata.FetchedRecordsCount),
+		Bookmark:            metadata.Bookmark,
+	}
+
+	for userIterator.HasNext() {
+		responseRange, err := userIterator.Next()
+		if err != nil {
+			return nil, fmt.Errorf("error:%v", err.Error())
+		}
+
+		// the level~UserID index holds copies of the user records
+		if !strings.HasPrefix(responseRange.Key, userPrefix) {
+			continue
+		}
+
+		user := new(User)
+		_ = json.Unmarshal(responseRange.Value, &user)
+
+		view, err := newUserView(ctx, responseRange.Key, user, now)
+		if err != nil {
+			return nil, err
+		}
+
+		page.Users = append(page.Users, view)
+	}
+
+	return page, nil
+}
+
+// buildRichQuery checks the caller is staff, validates the selector against the allowed fields and
+// returns the CouchDB query restricted to records of the docType
+func buildRichQuery(ctx contractapi.TransactionContextInterface, selectorJSON string, docType string, fields []string, pageSize int) (string, error) {
+
+	err := checkStaff(ctx)
+	if err != nil {
+		return "", err
+	}
+
+	if pageSize <= 0 || pageSize > maxPageSize {
+		return "", fmt.Errorf("page size must be between 1 and %v", maxPageSize)
+	}
+
+	selector := map[string]interface{}{}
+	if selectorJSON != "" {
+		err = json.Unmarshal([]byte(selectorJSON), &selector)
+		if err != nil {
+			return "", fmt.Errorf("selector must be a JSON object: %v", err)
+		}
+	}
+
+	err = checkSelector(selector, fields)
+	if err != nil {
+		return "", err
+	}
+
+	selector["docType"] = docType
+
+	querybytes, _ := json.Marshal(map[string]interface{}{"selector": selector})
+
+	return string(querybytes), nil
+}
+
+// checkSelector returns an error if the selector uses a field or operator that is not allowed
+func checkSelector(selector map[string]interface{}, fields []string) error {
+
+	for key, value := range selector {
+
+		switch key {
+		case "$and", "$or":
+			clauses, ok := value.([]interface{})
+			if !ok {
+				return fmt.Errorf("%v expects a list of selectors", key)
+			}
+			for _, clause := range clauses {
+				clauseSelector, ok := clause.(map[string]interface{})
+				if !ok {
+					return fmt.Errorf("%v expects a list of selectors", key)
+				}
+				err := checkSelector(clauseSelector, fields)
+				if err != nil {
+					return err
+				}
+			}
+
+		case "$not":
+			clauseSelector, ok := value.(map[string]interface{})
+			if !ok {
+				return fmt.Errorf("$not expects a selector")
+			}
+			err := checkSelector(clauseSelector, fields)
+			if err != nil {
+				return err
+			}
+
+		default:
+			if !containsUser(fields, key) {
+				return fmt.Errorf("field %v cannot be queried, allowed fields are %v", key, strings.Join(fields, ", "))
+			}
+
+			// a condition is either a value to match or operators applied to the field
+			conditions, ok := value.(map[string]interface{})
+			if !ok {
+				continue
+			}
+			for operator := range conditions {
+				if !containsUser(queryOperators, operator) {
+					return fmt.Errorf("operator %v is not allowed, allowed operators are %v", operator, strings.Join(queryOperators, ", "))
+				}
+			}
+		}
+	}
+
+	return nil
+}
+
+// newMembershipFilter validates the filter arguments of a query, dates are MM-DD-YYYY
+func newMembershipFilter(level string, status string, from string, to string) (*membershipFilter, error) {
+
+	if level != "" && level != goldlevel && level != diamondlevel && level != platinumlevel {
+		return nil, fmt.Errorf("only Gold, Diamond, and Platinum levels are acceptable")
+	}
+
+	switch status {
+	case "", StatusActive, StatusFrozen, StatusExpired, StatusCancelled:
+	default:
+		return nil, fmt.Errorf("unknown membership status %v", status)
+	}
+
+	filter := &membershipFilter{Level: level, Status: status}
+
+	if from != "" {
+		fromDate, err := time.Parse(dateFormat, from)
+		if err != nil {
+			return nil, fmt.Errorf("invalid date %v, expected MM-DD-YYYY", from)
+		}
+		filter.From = fromDate.Format(keyDateFormat)
+	}
+
+	if to != "" {
+		toDate, err := time.Parse(dateFormat, to)
+		if err != nil {
+			return nil, fmt.Errorf("invalid date %v, expected MM-DD-YYYY", to)
+		}
+		filter.To = toDate.Format(keyDateFormat)
+	}
+
+	return filter, nil
+}
+
+// isEmpty reports whether the filter matches every membership
+func (f *membershipFilter) isEmpty() bool {
+	return f.Level == "" && f.Status == "" && f.From == "" && f.To == ""
+}
+
+// matches reports whether the membership passes the filter
+func (f *membershipFilter) matches(membership *Membership) bool {
+
+	if f.Level != "" && membership.Level != f.Level {
+		return false
+	}
+
+	if f.Status != "" && membership.Status != f.Status {
+		return false
+	}
+
+	startDate, _ := time.Parse(dateFormat, membership.StartDate)
+	start := startDate.Format(keyDateFormat)
+
+	if f.From != "" && start < f.From {
+		return false
+	}
+
+	if f.To != "" && start > f.To {
+		return false
+	}
+
+	return true
+}
+
+// MigrateUsers rewrites up to count users after the bookmark, empty for the first page, that were stored
+// before docType was introduced so that QueryUsers finds them
+// Fabric only supports paginated range queries in read-only transactions, so the bookmark returned is
+// the last user key scanned and the next page starts right after it.
+func (h *HealthClub) MigrateUsers(ctx contractapi.TransactionContextInterface, count int, bookmark string) (*UserMigration, error) {
+
+	err := checkOwner(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	if count <= 0 {
+		return nil, fmt.Errorf("count must be a positive integer")
+	}
+
+	if bookmark != "" && !strings.HasPrefix(bookmark, userPrefix) {
+		return nil, fmt.Errorf("invalid bookmark %v", bookmark)
+	}
+
+	startKey := userPrefix
+	if bookmark != "" {
+		startKey = bookmark + "\x00"
+	}
+
+	userIterator, err := ctx.GetStub().GetStateByRange(startKey, userPrefix+string(utf8.MaxRune))
+	if err != nil {
+		return nil, fmt.Errorf("error:%v", err.Error())
+	}
+
+	defer userIterator.Close()
+
+	result := &UserMigration{}
+	scanned := 0
+	for userIterator.HasNext() && scanned < count {
+		responseRange, err := userIterator.Next()
+		if err != nil {
+			return nil, fmt.Errorf("error:%v", err.Error())
+		}
+
+		scanned++
+		result.Bookmark = responseRange.Key
+
+		user := new(User)
+		_ = json.Unmarshal(responseRange.Value, &user)
+
+		if user.DocType != "" {
+			continue
+		}
+
+		err = putUser(ctx, responseRange.Key, user)
+		if err != nil {
+			return nil, err
+		}
+
+		result.Migrated++
+	}
+
+	if !userIterator.HasNext() {
+		result.Bookmark = ""
+	}
+
+	log.Printf("%v users migrated", result.Migrated)
+
+	return result, nil
+}
+
+// toDateKey returns the MM-DD-YYYY date as YYYY-MM-DD, which sorts in date order, or an empty string if it is not a date
+func toDateKey(date string) string {
+
+	day, err := time.Parse(dateFormat, date)
+	if err != nil {
+		return ""
+	}
+
+	return day.Format(keyDateFormat)
+}
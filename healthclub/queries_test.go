@@ -0,0 +1,194 @@
+package healthclub
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/hyperledger/fabric-contract-api-go/contractapi"
+)
+
+// stripFields rewrites the committed record without the given fields, as it was stored before they were introduced
+func stripFields(c *testClub, key string, fields ...string) {
+
+	record := map[string]interface{}{}
+	_ = json.Unmarshal(c.Get(key), &record)
+
+	for _, field := range fields {
+		delete(record, field)
+	}
+
+	recordbytes, _ := json.Marshal(record)
+	c.Put(key, recordbytes)
+}
+
+func TestCheckSelector(t *testing.T) {
+
+	for _, selectorJSON := range []string{
+		`{"level":"Diamond","EndDateKey":{"$gte":"2026-11-01","$lt":"2026-12-01"}}`,
+		`{"$or":[{"Status":"Active"},{"Status":{"$in":["Frozen"]}}]}`,
+		`{"$not":{"SponsoredBy":{"$exists":true}}}`,
+	} {
+		selector := map[string]interface{}{}
+		_ = json.Unmarshal([]byte(selectorJSON), &selector)
+		if err := checkSelector(selector, membershipQueryFields); err != nil {
+			t.Errorf("selector %v rejected: %v", selectorJSON, err)
+		}
+	}
+
+	for _, selectorJSON := range []string{
+		`{"TokenDeposited":{"$gt":0}}`,
+		`{"EndDate":{"$regex":"^11-.*-2026$"}}`,
+		`{"level":{"$where":"true"}}`,
+		`{"$or":[{"Status":"Active"},{"docType":"user"}]}`,
+		`{"$and":{"Status":"Active"}}`,
+		`{"$not":[{"Status":"Active"}]}`,
+	} {
+		selector := map[string]interface{}{}
+		_ = json.Unmarshal([]byte(selectorJSON), &selector)
+		if err := checkSelector(selector, membershipQueryFields); err == nil {
+			t.Errorf("selector %v accepted", selectorJSON)
+		}
+	}
+}
+
+func TestQueryRecordsAreMigrated(t *testing.T) {
+
+	c := newTestClub(t)
+	alice := c.member(t, "alice", 900)
+	bob := c.member(t, "bob", 0)
+	carol := c.member(t, "carol", 0)
+	membershipId := c.buy(t, alice, goldlevel)
+
+	membership := c.membership(t, membershipId)
+	if membership.StartDateKey != "2026-03-02" || membership.EndDateKey != "2026-04-02" {
+		t.Errorf("membership of %v to %v stored with date keys %q and %q", membership.StartDate, membership.EndDate, membership.StartDateKey, membership.EndDateKey)
+	}
+
+	stripFields(c, membershipId, "docType", "StartDateKey", "EndDateKey")
+	for _, user := range []string{alice.ID, bob.ID, carol.ID} {
+		stripFields(c, userPrefix+user, "docType")
+	}
+
+	c.mustInvoke(t, c.owner, func(ctx contractapi.TransactionContextInterface) error {
+		migrated, err := c.h.MigrateMemberships(ctx, 1, 10)
+		if err == nil && migrated != 1 {
+			t.Errorf("%v memberships migrated, want 1", migrated)
+		}
+		return err
+	})
+
+	membership = c.membership(t, membershipId)
+	if membership.DocType != membershipDocType || membership.StartDateKey != "2026-03-02" || membership.EndDateKey != "2026-04-02" {
+		t.Errorf("migrated membership has docType %q and date keys %q and %q", membership.DocType, membership.StartDateKey, membership.EndDateKey)
+	}
+
+	// two pages of two users
+	bookmark := ""
+	migrated := 0
+	for page := 0; page < 2; page++ {
+		c.mustInvoke(t, c.owner, func(ctx contractapi.TransactionContextInterface) error {
+			result, err := c.h.MigrateUsers(ctx, 2, bookmark)
+			if err == nil {
+				bookmark = result.Bookmark
+				migrated += result.Migrated
+			}
+			return err
+		})
+	}
+	if migrated != 3 || bookmark != "" {
+		t.Errorf("%v users migrated with bookmark %q, want 3 without bookmark", migrated, bookmark)
+	}
+
+	for _, user := range []string{alice.ID, bob.ID, carol.ID} {
+		if docType := c.user(t, userPrefix+user).DocType; docType != userDocType {
+			t.Errorf("user %v migrated with docType %q", user, docType)
+		}
+	}
+
+	if _, err := c.invoke(c.staff, func(ctx contractapi.TransactionContextInterface) error {
+		_, err := c.h.MigrateUsers(ctx, 2, "")
+		return err
+	}); err == nil {
+		t.Errorf("users migrated by staff")
+	}
+}
+
+func TestPagesFollowTheBookmark(t *testing.T) {
+
+	c := newTestClub(t)
+	alice := c.member(t, "alice", 900)
+	bob := c.member(t, "bob", 4900)
+	carol := c.member(t, "carol", 900)
+	c.buy(t, alice, goldlevel)
+	c.buy(t, bob, platinumlevel)
+	c.buy(t, carol, goldlevel)
+
+	membershipsPage := func(level string, pageSize int, bookmark string) *MembershipPage {
+		t.Helper()
+
+		var page *MembershipPage
+		c.mustInvoke(t, c.staff, func(ctx contractapi.TransactionContextInterface) error {
+			var err error
+			page, err = c.h.GetMembershipsPage(ctx, level, "", "", "", pageSize, bookmark)
+			return err
+		})
+
+		return page
+	}
+
+	first := membershipsPage("", 2, "")
+	if len(first.Memberships) != 2 || first.Bookmark == "" {
+		t.Fatalf("first page has %v memberships and bookmark %q, want 2 and a bookmark", len(first.Memberships), first.Bookmark)
+	}
+
+	last := membershipsPage("", 2, first.Bookmark)
+	if len(last.Memberships) != 1 || last.Bookmark != "" {
+		t.Errorf("last page has %v memberships and bookmark %q, want 1 without bookmark", len(last.Memberships), last.Bookmark)
+	}
+
+	// the filter applies to the records of the page
+	gold := membershipsPage(goldlevel, 3, "")
+	if len(gold.Memberships) != 2 || gold.FetchedRecordsCount != 3 {
+		t.Errorf("Gold page has %v of %v records, want 2 of 3", len(gold.Memberships), gold.FetchedRecordsCount)
+	}
+
+	var users *UserPage
+	c.mustInvoke(t, c.staff, func(ctx contractapi.TransactionContextInterface) error {
+		var err error
+		users, err = c.h.GetUsersPage(ctx, platinumlevel, StatusActive, "", "", 10, "")
+		return err
+	})
+	if len(users.Users) != 1 || users.Users[0].UserID != userPrefix+bob.ID {
+		t.Errorf("users with an active Platinum membership are %+v, want bob", users.Users)
+	}
+
+	for _, pageSize := range []int{0, maxPageSize + 1} {
+		if _, err := c.invoke(c.staff, func(ctx contractapi.TransactionContextInterface) error {
+			_, err := c.h.GetMembershipsPage(ctx, "", "", "", "", pageSize, "")
+			return err
+		}); err == nil {
+			t.Errorf("page of %v memberships returned", pageSize)
+		}
+	}
+}
+
+func TestPagesAreStaffOnly(t *testing.T) {
+
+	c := newTestClub(t)
+	alice := c.member(t, "alice", 900)
+	c.buy(t, alice, goldlevel)
+
+	if _, err := c.invoke(alice, func(ctx contractapi.TransactionContextInterface) error {
+		_, err := c.h.GetMembershipsPage(ctx, "", "", "", "", 10, "")
+		return err
+	}); err == nil {
+		t.Errorf("member read a page of memberships")
+	}
+
+	if _, err := c.invoke(alice, func(ctx contractapi.TransactionContextInterface) error {
+		_, err := c.h.GetUsersPage(ctx, "", "", "", "", 10, "")
+		return err
+	}); err == nil {
+		t.Errorf("member read a page of users")
+	}
+}
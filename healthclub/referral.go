@@ -0,0 +1,279 @@
+package healthclub
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/hyperledger/fabric-contract-api-go/contractapi"
+	erc20 "github.com/varun425/MiniClubChaincode/erc20"
+)
+
+const (
+	referralIndex        = "referral~ReferrerID~RefereeID"
+	referralBonusIndex   = "referralbonus~ReferrerID~Month"
+	referralPolicyKey    = "ReferralPolicy"
+	referralPending      = "Pending"
+	referralPaid         = "Paid"
+	referralLimitReached = "LimitReached"
+)
+
+// ReferralPolicy sets the bonus paid to a referrer when a referred user buys the first paid membership
+// A referrer is paid at most MaxBonusesPerMonth bonuses per calendar month, further referrals are recorded without bonus
+type ReferralPolicy struct {
+	BonusAmount        int `json:"bonusamount"`
+	MaxBonusesPerMonth int `json:"maxbonusespermonth"`
+}
+
+// Referral links a referrer to a user registered with the referral
+type Referral struct {
+	ReferrerID   string `json:"referrerid"`
+	RefereeID    string `json:"refereeid"`
+	RegisteredOn string `json:"registeredon"`
+	Status       string `json:"status"`
+	BonusAmount  int    `json:"bonusamount"`
+	PaidOn       string `json:"paidon"`
+}
+
+var defaultReferralPolicy = ReferralPolicy{
+	BonusAmount:        200,
+	MaxBonusesPerMonth: 5,
+}
+
+// RegisterUserWithReferral registers the caller like RegisterUser and links the caller to the referring registered user
+// The referrer is paid the referral bonus once the caller buys the first paid membership with GetNewMemberShip.
+// The owner cannot refer users, the bonus is paid from the owner account
+func (h *HealthClub) RegisterUserWithReferral(ctx contractapi.TransactionContextInterface, name string, email string, referrerId string) (string, error) {
+
+	userid, err := ctx.GetClientIdentity().GetID()
+	if err != nil {
+		return "", fmt.Errorf("failed to get userID: %v", err)
+	}
+
+	refereeId := userPrefix + userid
+	referrerId = toUserKey(referrerId)
+
+	if referrerId == refereeId {
+		return "", fmt.Errorf("users cannot refer themselves")
+	}
+
+	referrer, err := ctx.GetStub().GetState(referrerId)
+	if err != nil {
+		return "", fmt.Errorf("error:%v", err)
+	}
+
+	if referrer == nil {
+		return "", fmt.Errorf("referrer %v is not registered", referrerId)
+	}
+
+	adminID, err := getOwnerID(ctx)
+	if err != nil {
+		return "", err
+	}
+
+	if strings.TrimPrefix(referrerId, userPrefix) == adminID {
+		return "", fmt.Errorf("the owner cannot refer users")
+	}
+
+	now, err := getTxTime(ctx)
+	if err != nil {
+		return "", fmt.Errorf("error:%v", err)
+	}
+
+	result, err := h.registerUser(ctx, name, email, referrerId)
+	if err != nil {
+		return "", err
+	}
+
+	referral := &Referral{
+		ReferrerID:   referrerId,
+		RefereeID:    refereeId,
+		RegisteredOn: now.Format(dateFormat),
+		Status:       referralPending,
+	}
+
+	err = putReferral(ctx, referral)
+	if err != nil {
+		return "", err
+	}
+
+	log.Printf("%v registered with referral of %v", refereeId, referrerId)
+
+	return result, nil
+}
+
+// GetReferrals returns the users referred by the given user with the bonus status of each referral
+func (h *HealthClub) GetReferrals(ctx contractapi.TransactionContextInterface, userId string) ([]Referral, error) {
+
+	referralIterator, err := ctx.GetStub().GetStateByPartialCompositeKey(referralIndex, []string{toUserKey(userId)})
+	if err != nil {
+		return nil, fmt.Errorf("error:%v", err.Error())
+	}
+
+	defer referralIterator.Close()
+
+	referrals := []Referral{}
+	for referralIterator.HasNext() {
+		responseRange, err := referralIterator.Next()
+		if err != nil {
+			return nil, fmt.Errorf("error:%v", err.Error())
+		}
+
+		referral := new(Referral)
+		_ = json.Unmarshal(responseRange.Value, &referral)
+		referrals = append(referrals, *referral)
+	}
+
+	return referrals, nil
+}
+
+// SetReferralPolicy sets the referral bonus and the maximum number of bonuses paid to one referrer per month
+func (h *HealthClub) SetReferralPolicy(ctx contractapi.TransactionContextInterface, bonusAmount int, maxBonusesPerMonth int) (string, error) {
+
+	err := checkOwner(ctx)
+	if err != nil {
+		return "", err
+	}
+
+	if bonusAmount < 0 || maxBonusesPerMonth < 0 {
+		return "", fmt.Errorf("bonus amount and max bonuses per month cannot be negative")
+	}
+
+	policy := ReferralPolicy{
+		BonusAmount:        bonusAmount,
+		MaxBonusesPerMonth: maxBonusesPerMonth,
+	}
+
+	policybytes, _ := json.Marshal(policy)
+	err = ctx.GetStub().PutState(referralPolicyKey, policybytes)
+	if err != nil {
+		return "", fmt.Errorf("error:%v", err)
+	}
+
+	return "referral policy is updated", nil
+}
+
+// GetReferralPolicy returns the referral policy, or the default policy if the owner has not set one
+func (h *HealthClub) GetReferralPolicy(ctx contractapi.TransactionContextInterface) (*ReferralPolicy, error) {
+	return getReferralPolicy(ctx)
+}
+
+// payReferralBonus adds the bonus of the pending referral of the referee to the batch, paid from the owner account,
+// unless the referrer already reached the monthly limit. The referral is updated either way.
+// returns nil if the referral is no longer pending
+func payReferralBonus(ctx contractapi.TransactionContextInterface, batch *erc20.TransferBatch, referrerId string, refereeId string, adminID string, now time.Time) (*Referral, error) {
+
+	referral, err := getReferral(ctx, referrerId, refereeId)
+	if err != nil {
+		return nil, err
+	}
+
+	if referral.Status != referralPending {
+		return nil, nil
+	}
+
+	policy, err := getReferralPolicy(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	month := now.Format("2006-01")
+	bonusKey, err := ctx.GetStub().CreateCompositeKey(referralBonusIndex, []string{referrerId, month})
+	if err != nil {
+		return nil, fmt.Errorf("failed to create the composite key for prefix %s: %v", referralBonusIndex, err)
+	}
+
+	bonusbytes, err := ctx.GetStub().GetState(bonusKey)
+	if err != nil {
+		return nil, fmt.Errorf("error:%v", err)
+	}
+
+	bonusesPaid, _ := strconv.Atoi(string(bonusbytes))
+
+	if bonusesPaid >= policy.MaxBonusesPerMonth {
+		log.Printf("%v reached the limit of %v referral bonuses in %v", referrerId, policy.MaxBonusesPerMonth, month)
+		referral.Status = referralLimitReached
+	} else {
+		if policy.BonusAmount > 0 {
+			err = batch.Transfer(adminID, strings.TrimPrefix(referrerId, userPrefix), policy.BonusAmount)
+			if err != nil {
+				return nil, fmt.Errorf("failed to pay referral bonus from treasury: %v", err)
+			}
+		}
+
+		err = ctx.GetStub().PutState(bonusKey, []byte(strconv.Itoa(bonusesPaid+1)))
+		if err != nil {
+			return nil, fmt.Errorf("error:%v", err)
+		}
+
+		referral.Status = referralPaid
+		referral.BonusAmount = policy.BonusAmount
+		referral.PaidOn = now.Format(dateFormat)
+	}
+
+	err = putReferral(ctx, referral)
+	if err != nil {
+		return nil, err
+	}
+
+	return referral, nil
+}
+
+// getReferralPolicy reads the referral policy, or returns the default policy if the owner has not set one
+func getReferralPolicy(ctx contractapi.TransactionContextInterface) (*ReferralPolicy, error) {
+
+	policybytes, err := ctx.GetStub().GetState(referralPolicyKey)
+	if err != nil {
+		return nil, fmt.Errorf("error:%v", err)
+	}
+
+	policy := defaultReferralPolicy
+	if policybytes != nil {
+		_ = json.Unmarshal(policybytes, &policy)
+	}
+
+	return &policy, nil
+}
+
+// getReferral reads the referral linking the referrer to the referee
+func getReferral(ctx contractapi.TransactionContextInterface, referrerId string, refereeId string) (*Referral, error) {
+
+	referralKey, err := ctx.GetStub().CreateCompositeKey(referralIndex, []string{referrerId, refereeId})
+	if err != nil {
+		return nil, fmt.Errorf("failed to create the composite key for prefix %s: %v", referralIndex, err)
+	}
+
+	referralbytes, err := ctx.GetStub().GetState(referralKey)
+	if err != nil {
+		return nil, fmt.Errorf("error:%v", err)
+	}
+
+	if referralbytes == nil {
+		return nil, fmt.Errorf("referral of %v by %v not found", refereeId, referrerId)
+	}
+
+	referral := new(Referral)
+	_ = json.Unmarshal(referralbytes, &referral)
+
+	return referral, nil
+}
+
+// putReferral writes a referral to the world state
+func putReferral(ctx contractapi.TransactionContextInterface, referral *Referral) error {
+
+	referralKey, err := ctx.GetStub().CreateCompositeKey(referralIndex, []string{referral.ReferrerID, referral.RefereeID})
+	if err != nil {
+		return fmt.Errorf("failed to create the composite key for prefix %s: %v", referralIndex, err)
+	}
+
+	referralbytes, _ := json.Marshal(referral)
+	err = ctx.GetStub().PutState(referralKey, referralbytes)
+	if err != nil {
+		return fmt.Errorf("error:%v", err)
+	}
+
+	return nil
+}
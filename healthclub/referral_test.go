@@ -0,0 +1,102 @@
+package healthclub
+
+import (
+	"testing"
+	"time"
+
+	"github.com/hyperledger/fabric-contract-api-go/contractapi"
+	"github.com/varun425/MiniClubChaincode/internal/chaincodetest"
+)
+
+// referred registers the user named name with the referral of the referrer and mints tokens on top of the registration bonus
+func referred(t *testing.T, c *testClub, name string, referrer *chaincodetest.Identity, tokens int) *chaincodetest.Identity {
+
+	t.Helper()
+
+	member := chaincodetest.NewIdentity(clientID(name), nil)
+	c.mustInvoke(t, member, func(ctx contractapi.TransactionContextInterface) error {
+		_, err := c.h.RegisterUserWithReferral(ctx, name, name+"@example.com", referrer.ID)
+		return err
+	})
+	c.mustInvoke(t, member, func(ctx contractapi.TransactionContextInterface) error {
+		return c.h.Mint(ctx, tokens)
+	})
+
+	return member
+}
+
+// referralStatuses returns the status of every referral of the referrer by referee
+func referralStatuses(t *testing.T, c *testClub, referrer *chaincodetest.Identity) map[string]string {
+
+	t.Helper()
+
+	var referrals []Referral
+	c.mustInvoke(t, referrer, func(ctx contractapi.TransactionContextInterface) error {
+		var err error
+		referrals, err = c.h.GetReferrals(ctx, referrer.ID)
+		return err
+	})
+
+	statuses := map[string]string{}
+	for _, referral := range referrals {
+		statuses[referral.RefereeID] = referral.Status
+	}
+
+	return statuses
+}
+
+func TestReferralBonusIsPaidOncePerRefereeWithinTheMonthlyLimit(t *testing.T) {
+
+	c := newTestClub(t)
+	c.mustInvoke(t, c.owner, func(ctx contractapi.TransactionContextInterface) error {
+		_, err := c.h.SetReferralPolicy(ctx, 200, 1)
+		return err
+	})
+	c.mustInvoke(t, c.owner, func(ctx contractapi.TransactionContextInterface) error {
+		return c.h.Mint(ctx, 1000)
+	})
+
+	alice := c.member(t, "alice", 0)
+	bob := referred(t, c, "bob", alice, 900)
+	carol := referred(t, c, "carol", alice, 900)
+
+	c.buy(t, bob, goldlevel)
+	c.assertBalances(t, map[string]int{alice.ID: 300})
+
+	// the second referral in the month is recorded without bonus
+	c.buy(t, carol, goldlevel)
+	c.assertBalances(t, map[string]int{alice.ID: 300})
+
+	// a later membership of bob pays nothing more
+	c.mustInvoke(t, bob, func(ctx contractapi.TransactionContextInterface) error {
+		return c.h.Mint(ctx, 1000)
+	})
+	c.after(40 * 24 * time.Hour)
+	c.buy(t, bob, goldlevel)
+	c.assertBalances(t, map[string]int{alice.ID: 300})
+
+	statuses := referralStatuses(t, c, alice)
+	expected := map[string]string{userPrefix + bob.ID: referralPaid, userPrefix + carol.ID: referralLimitReached}
+	for refereeId, status := range expected {
+		if statuses[refereeId] != status {
+			t.Errorf("referral of %v is %q, want %v", refereeId, statuses[refereeId], status)
+		}
+	}
+}
+
+func TestOwnerCannotRefer(t *testing.T) {
+
+	c := newTestClub(t)
+	c.mustInvoke(t, c.owner, func(ctx contractapi.TransactionContextInterface) error {
+		_, err := c.h.RegisterUser(ctx, "owner", "owner@example.com")
+		return err
+	})
+
+	dave := chaincodetest.NewIdentity(clientID("dave"), nil)
+	if _, err := c.invoke(dave, func(ctx contractapi.TransactionContextInterface) error {
+		_, err := c.h.RegisterUserWithReferral(ctx, "dave", "dave@example.com", c.owner.ID)
+		return err
+	}); err == nil {
+		t.Errorf("user registered with the referral of the owner")
+	}
+}
@@ -0,0 +1,305 @@
+package healthclub
+
+import (
+	"fmt"
+	"log"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/hyperledger/fabric-contract-api-go/contractapi"
+	erc20 "github.com/varun425/MiniClubChaincode/erc20"
+	"github.com/varun425/MiniClubChaincode/events"
+)
+
+const autoRenewIndex = "autorenew~MembershipID"
+
+// Renewal describes the outcome of renewing one membership
+type Renewal struct {
+	UserID               string `json:"userid"`
+	PreviousMembershipID string `json:"previousmembershipid"`
+	MembershipID         string `json:"membershipid,omitempty"`
+	Level                string `json:"level"`
+	Tokens               int    `json:"tokens"`
+	Reason               string `json:"reason,omitempty"`
+}
+
+// RenewalReport lists the memberships renewed and the renewals that failed in one ProcessRenewals call
+type RenewalReport struct {
+	Renewed []Renewal `json:"renewed"`
+	Failed  []Renewal `json:"failed"`
+}
+
+// SetAutoRenew opts the current membership of the caller in or out of automatic renewal
+// To be renewed the member must grant the club account an allowance of at least the level price through Approve.
+// Sponsored memberships are not renewed automatically, the member would be charged the share of the sponsor
+func (h *HealthClub) SetAutoRenew(ctx contractapi.TransactionContextInterface, enabled bool) (string, error) {
+
+	userid, err := ctx.GetClientIdentity().GetID()
+	if err != nil {
+		return "", fmt.Errorf("failed to get userID: %v", err)
+	}
+
+	userId := userPrefix + userid
+
+	currentmembershipId, membershipdetails, err := getCurrentMembership(ctx, userId)
+	if err != nil {
+		return "", fmt.Errorf("error:%v", err)
+	}
+
+	if membershipdetails.Status != StatusActive && membershipdetails.Status != StatusFrozen {
+		return "", fmt.Errorf("membership %v is %v", currentmembershipId, membershipdetails.Status)
+	}
+
+	if enabled && membershipdetails.SponsoredBy != "" {
+		return "", fmt.Errorf("membership is sponsored by %v and cannot be renewed automatically", membershipdetails.SponsoredBy)
+	}
+
+	membershipdetails.AutoRenew = enabled
+
+	err = putMembership(ctx, currentmembershipId, membershipdetails)
+	if err != nil {
+		return "", err
+	}
+
+	err = setAutoRenewIndex(ctx, currentmembershipId, userId, enabled)
+	if err != nil {
+		return "", fmt.Errorf("error:%v", err)
+	}
+
+	if !enabled {
+		return "Auto-renewal disabled", nil
+	}
+
+	adminID, err := getOwnerID(ctx)
+	if err != nil {
+		return "", err
+	}
+
+	log.Printf("auto-renewal enabled for membership %v", currentmembershipId)
+
+	return "Auto-renewal enabled, approve the club account " + adminID + " to spend the renewal price", nil
+}
+
+// ProcessRenewals renews up to batchSize memberships that opted in to auto-renewal and whose EndDate has passed.
+// The level price is pulled from the member account with TransferFrom against the allowance granted to the club account.
+// Renewals that fail, e.g. for insufficient funds or allowance, switch auto-renewal off for that membership.
+// This function triggers a MembershipRenewals event, and MembershipExpired and MembershipCreated events for every membership
+func (h *HealthClub) ProcessRenewals(ctx contractapi.TransactionContextInterface, batchSize int) (*RenewalReport, error) {
+
+	err := checkStaff(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	if batchSize <= 0 {
+		return nil, fmt.Errorf("batch size must be a positive integer")
+	}
+
+	now, err := getTxTime(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("error:%v", err)
+	}
+
+	adminID, err := getOwnerID(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	// collect due memberships first, the index is updated while renewing
+	renewIterator, err := ctx.GetStub().GetStateByPartialCompositeKey(autoRenewIndex, []string{})
+	if err != nil {
+		return nil, fmt.Errorf("error:%v", err.Error())
+	}
+
+	dueMembershipIds := []string{}
+	for renewIterator.HasNext() && len(dueMembershipIds) < batchSize {
+		responseRange, err := renewIterator.Next()
+		if err != nil {
+			renewIterator.Close()
+			return nil, fmt.Errorf("error:%v", err.Error())
+		}
+
+		_, compositeKeyParts, err := ctx.GetStub().SplitCompositeKey(responseRange.Key)
+		if err != nil {
+			renewIterator.Close()
+			return nil, fmt.Errorf("error:%v", err.Error())
+		}
+
+		membershipdetails, err := getMembership(ctx, compositeKeyParts[0])
+		if err != nil {
+			renewIterator.Close()
+			return nil, fmt.Errorf("error:%v", err)
+		}
+
+		// a frozen membership keeps its index entry and is renewed once resumed
+		if membershipdetails.Status == StatusFrozen {
+			continue
+		}
+
+		membershipendDate, _ := time.Parse(dateFormat, membershipdetails.EndDate)
+		if now.After(membershipendDate) || !membershipdetails.AutoRenew || membershipdetails.Status != StatusActive {
+			dueMembershipIds = append(dueMembershipIds, compositeKeyParts[0])
+		}
+	}
+	renewIterator.Close()
+
+	TotalMembershipsbytes, err := ctx.GetStub().GetState("TotalMemberships")
+	if err != nil {
+		return nil, fmt.Errorf("err: %v", err)
+	}
+
+	TotalMemberships, _ := strconv.Atoi(string(TotalMembershipsbytes))
+
+	batch := erc20.NewTransferBatch(ctx)
+	report := &RenewalReport{Renewed: []Renewal{}, Failed: []Renewal{}}
+
+	for _, membershipId := range dueMembershipIds {
+
+		membershipdetails, err := getMembership(ctx, membershipId)
+		if err != nil {
+			return nil, fmt.Errorf("error:%v", err)
+		}
+
+		userId := membershipdetails.UserID
+
+		// stale index entry: opted out, cancelled or already expired
+		if !membershipdetails.AutoRenew || membershipdetails.Status != StatusActive {
+			err = setAutoRenewIndex(ctx, membershipId, userId, false)
+			if err != nil {
+				return nil, fmt.Errorf("error:%v", err)
+			}
+			continue
+		}
+
+		renewal := Renewal{
+			UserID:               userId,
+			PreviousMembershipID: membershipId,
+			Level:                membershipdetails.Level,
+		}
+
+		userptr, err := getUser(ctx, userId)
+		if err != nil {
+			return nil, fmt.Errorf("error:%v", err)
+		}
+
+		membershipdetails.AutoRenew = false
+		err = transitionMembership(membershipdetails, StatusExpired, now, "membership ended")
+		if err != nil {
+			return nil, fmt.Errorf("error:%v", err)
+		}
+
+		// the renewal is a new membership, the renewed one expires either way
+		err = emitMembershipExpired(ctx, membershipId, membershipdetails)
+		if err != nil {
+			return nil, err
+		}
+
+		// renewed at the price of the home branch, paid to its treasury
+		// the payment and the royalty go to a fork of the batch, merged only once both succeeded
+		var price int
+		var payee string
+		renewalBatch := batch.Fork()
+		leveldetails, err := h.GetLevelDetails(ctx, membershipdetails.Level)
+		if err == nil {
+			price, err = levelPrice(ctx, membershipdetails.Level, leveldetails, membershipdetails.BranchID)
+		}
+		if err == nil {
+			payee, err = revenueAccount(ctx, membershipdetails.BranchID)
+		}
+		if err == nil {
+			renewal.Tokens = price
+			err = renewalBatch.TransferFrom(strings.TrimPrefix(userId, userPrefix), adminID, payee, price)
+		}
+		if err == nil {
+			err = splitRevenue(ctx, renewalBatch, membershipdetails.BranchID, revenueMembership, userId, price, now)
+		}
+		if err == nil {
+			err = batch.Merge(renewalBatch)
+		}
+
+		if err != nil {
+			renewal.Reason = err.Error()
+			report.Failed = append(report.Failed, renewal)
+			log.Printf("renewal of membership %v failed: %v", membershipId, err)
+
+			err = dissolveGroup(ctx, userId, "primary membership expired")
+			if err != nil {
+				return nil, err
+			}
+		} else {
+			TotalMemberships = TotalMemberships + 1
+			renewedMembershipID := membershipPrefix + strconv.Itoa(TotalMemberships)
+
+			// the renewal continues the renewed membership, however late the renewal is processed
+			membershipendDate, _ := time.Parse(dateFormat, membershipdetails.EndDate)
+			renewedMembership := newMembership(userId, membershipdetails.Level, leveldetails, membershipendDate.AddDate(0, 0, 1))
+			renewedMembership.TokenDeposited = price
+			renewedMembership.BranchID = membershipdetails.BranchID
+			renewedMembership.AllAccess = leveldetails.AllAccess
+			renewedMembership.AutoRenew = true
+
+			err = addMembershipToUser(ctx, renewedMembershipID, &renewedMembership, userId, userptr)
+			if err != nil {
+				return nil, fmt.Errorf("error:%v", err)
+			}
+
+			err = setAutoRenewIndex(ctx, renewedMembershipID, userId, true)
+			if err != nil {
+				return nil, fmt.Errorf("error:%v", err)
+			}
+
+			err = emitMembershipCreated(ctx, renewedMembershipID, &renewedMembership, membershipId)
+			if err != nil {
+				return nil, err
+			}
+
+			membershipdetails.RenewedBy = renewedMembershipID
+			renewal.MembershipID = renewedMembershipID
+			report.Renewed = append(report.Renewed, renewal)
+			log.Printf("membership %v renewed as %v", membershipId, renewedMembershipID)
+		}
+
+		err = putMembership(ctx, membershipId, membershipdetails)
+		if err != nil {
+			return nil, err
+		}
+
+		err = setAutoRenewIndex(ctx, membershipId, userId, false)
+		if err != nil {
+			return nil, fmt.Errorf("error:%v", err)
+		}
+	}
+
+	err = batch.Commit()
+	if err != nil {
+		return nil, fmt.Errorf("error:%v", err)
+	}
+
+	err = ctx.GetStub().PutState("TotalMemberships", []byte(strconv.Itoa(TotalMemberships)))
+	if err != nil {
+		return nil, fmt.Errorf("err: %v", err)
+	}
+
+	err = emitEvent(ctx, events.MembershipRenewals, report)
+	if err != nil {
+		return nil, err
+	}
+
+	return report, nil
+}
+
+// setAutoRenewIndex adds or removes the membership from the autorenew~MembershipID index
+func setAutoRenewIndex(ctx contractapi.TransactionContextInterface, membershipId string, userId string, enabled bool) error {
+
+	autoRenewKey, err := ctx.GetStub().CreateCompositeKey(autoRenewIndex, []string{membershipId})
+	if err != nil {
+		return fmt.Errorf("failed to create the composite key for prefix %s: %v", autoRenewIndex, err)
+	}
+
+	if enabled {
+		return ctx.GetStub().PutState(autoRenewKey, []byte(userId))
+	}
+
+	return ctx.GetStub().DelState(autoRenewKey)
+}
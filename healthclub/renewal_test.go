@@ -0,0 +1,118 @@
+package healthclub
+
+import (
+	"testing"
+	"time"
+
+	"github.com/hyperledger/fabric-contract-api-go/contractapi"
+	"github.com/varun425/MiniClubChaincode/internal/chaincodetest"
+)
+
+// optInToRenewal enables auto-renewal of the current membership and approves the club account for price tokens
+func optInToRenewal(t *testing.T, c *testClub, member *chaincodetest.Identity, price int) {
+
+	t.Helper()
+
+	c.mustInvoke(t, member, func(ctx contractapi.TransactionContextInterface) error {
+		_, err := c.h.SetAutoRenew(ctx, true)
+		return err
+	})
+	c.mustInvoke(t, member, func(ctx contractapi.TransactionContextInterface) error {
+		return c.h.Approve(ctx, c.owner.ID, price)
+	})
+}
+
+func TestProcessRenewals(t *testing.T) {
+
+	c := newTestClub(t)
+
+	alice := c.member(t, "alice", 900)
+	aliceMembership := c.buy(t, alice, goldlevel)
+	optInToRenewal(t, c, alice, 1000)
+	c.mustInvoke(t, alice, func(ctx contractapi.TransactionContextInterface) error {
+		return c.h.Mint(ctx, 1000)
+	})
+
+	// bob opts in without the tokens to pay for the renewal
+	bob := c.member(t, "bob", 900)
+	bobMembership := c.buy(t, bob, goldlevel)
+	optInToRenewal(t, c, bob, 1000)
+
+	// processed a week after the end of the term
+	c.after(39 * 24 * time.Hour)
+
+	report := processRenewals(t, c)
+	if len(report.Renewed) != 1 || report.Renewed[0].PreviousMembershipID != aliceMembership {
+		t.Fatalf("renewed %+v, want %v only", report.Renewed, aliceMembership)
+	}
+	if len(report.Failed) != 1 || report.Failed[0].PreviousMembershipID != bobMembership {
+		t.Fatalf("failed %+v, want %v only", report.Failed, bobMembership)
+	}
+
+	// the failed renewal of bob debits nothing
+	c.assertBalances(t, map[string]int{alice.ID: 0, bob.ID: 0, c.owner.ID: 3000})
+
+	renewed := c.membership(t, report.Renewed[0].MembershipID)
+	if renewed.Status != StatusActive || !renewed.AutoRenew || renewed.TokenDeposited != 1000 {
+		t.Errorf("renewed membership %+v", renewed)
+	}
+	if renewed.StartDate != "04-03-2026" || renewed.EndDate != "05-03-2026" {
+		t.Errorf("renewal runs from %v to %v, want from the day after the renewed term", renewed.StartDate, renewed.EndDate)
+	}
+
+	for _, membershipId := range []string{aliceMembership, bobMembership} {
+		if status := c.membership(t, membershipId).Status; status != StatusExpired {
+			t.Errorf("membership %v is %v, want %v", membershipId, status, StatusExpired)
+		}
+	}
+}
+
+func TestProcessRenewalsSkipsFrozenMemberships(t *testing.T) {
+
+	c := newTestClub(t)
+
+	alice := c.member(t, "alice", 900)
+	membershipId := c.buy(t, alice, goldlevel)
+	optInToRenewal(t, c, alice, 1000)
+	c.mustInvoke(t, alice, func(ctx contractapi.TransactionContextInterface) error {
+		return c.h.Mint(ctx, 1000)
+	})
+
+	// frozen for 7 days shortly before the end, the membership then ends 7 days later
+	c.after(25 * 24 * time.Hour)
+	c.mustInvoke(t, alice, func(ctx contractapi.TransactionContextInterface) error {
+		_, err := c.h.FreezeMembership(ctx, 7, "travel")
+		return err
+	})
+
+	c.after(7 * 24 * time.Hour)
+	report := processRenewals(t, c)
+	if len(report.Renewed) != 0 || len(report.Failed) != 0 {
+		t.Fatalf("renewals while the membership was due %+v", report)
+	}
+
+	c.after(7 * 24 * time.Hour)
+	report = processRenewals(t, c)
+	if len(report.Renewed) != 1 {
+		t.Fatalf("renewals after the extended end %+v, want the membership renewed", report)
+	}
+
+	previous := c.membership(t, membershipId)
+	if previous.Status != StatusExpired || len(previous.Freezes) != 1 {
+		t.Errorf("renewed membership %+v, want it expired after one freeze", previous)
+	}
+}
+
+func processRenewals(t *testing.T, c *testClub) *RenewalReport {
+
+	t.Helper()
+
+	var report *RenewalReport
+	c.mustInvoke(t, c.staff, func(ctx contractapi.TransactionContextInterface) error {
+		var err error
+		report, err = c.h.ProcessRenewals(ctx, 10)
+		return err
+	})
+
+	return report
+}
@@ -0,0 +1,271 @@
+package healthclub
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/hyperledger/fabric-contract-api-go/contractapi"
+	erc20 "github.com/varun425/MiniClubChaincode/erc20"
+)
+
+const (
+	revenueIndex      = "revenue~BranchID~Date~TxID~UserID"
+	revenueMembership = "membership"
+	revenueUpgrade    = "upgrade"
+	revenueDowngrade  = "downgrade"
+	revenueCancel     = "cancellation"
+	revenueClass      = "class"
+	revenueSession    = "session"
+	revenueDayPass    = "daypass"
+)
+
+// RevenueEntry is a sale or refund on the revenue ledger of a branch, sales without branch are booked on branch ""
+// Royalty is the part of the sale paid to headquarters (the owner account), negative when returned on a refund
+type RevenueEntry struct {
+	BranchID string `json:"branchid"`
+	TxID     string `json:"txid"`
+	Date     string `json:"date"`
+	Source   string `json:"source"`
+	UserID   string `json:"userid"`
+	Gross    int    `json:"gross"`
+	Refund   int    `json:"refund"`
+	Royalty  int    `json:"royalty"`
+}
+
+// RevenueSummary sums the revenue ledger of a branch between two dates, Net is the gross less refunds and royalties
+type RevenueSummary struct {
+	BranchID  string         `json:"branchid"`
+	From      string         `json:"from"`
+	To        string         `json:"to"`
+	Gross     int            `json:"gross"`
+	Refunds   int            `json:"refunds"`
+	Royalties int            `json:"royalties"`
+	Net       int            `json:"net"`
+	Entries   []RevenueEntry `json:"entries"`
+}
+
+// RevenueReport summarises the gross sales, refunds and royalties of the branch from one date to another (MM-DD-YYYY, inclusive)
+// The report can be read by the owner and by the branch manager, branch "" reports the sales made without branch
+func (h *HealthClub) RevenueReport(ctx contractapi.TransactionContextInterface, branchId string, from string, to string) (*RevenueSummary, error) {
+
+	if branchId == "" {
+		err := checkOwner(ctx)
+		if err != nil {
+			return nil, err
+		}
+	} else {
+		if _, err := getManagedBranch(ctx, branchId); err != nil {
+			return nil, err
+		}
+	}
+
+	fromDate, err := time.Parse(dateFormat, from)
+	if err != nil {
+		return nil, fmt.Errorf("invalid date %v, expected MM-DD-YYYY", from)
+	}
+
+	toDate, err := time.Parse(dateFormat, to)
+	if err != nil {
+		return nil, fmt.Errorf("invalid date %v, expected MM-DD-YYYY", to)
+	}
+
+	revenueIterator, err := ctx.GetStub().GetStateByPartialCompositeKey(revenueIndex, []string{branchId})
+	if err != nil {
+		return nil, fmt.Errorf("error:%v", err.Error())
+	}
+
+	defer revenueIterator.Close()
+
+	summary := &RevenueSummary{
+		BranchID: branchId,
+		From:     from,
+		To:       to,
+		Entries:  []RevenueEntry{},
+	}
+
+	for revenueIterator.HasNext() {
+		responseRange, err := revenueIterator.Next()
+		if err != nil {
+			return nil, fmt.Errorf("error:%v", err.Error())
+		}
+
+		_, compositeKeyParts, err := ctx.GetStub().SplitCompositeKey(responseRange.Key)
+		if err != nil {
+			return nil, fmt.Errorf("error:%v", err.Error())
+		}
+
+		date := compositeKeyParts[1]
+		if date < fromDate.Format(keyDateFormat) || date > toDate.Format(keyDateFormat) {
+			continue
+		}
+
+		entry := new(RevenueEntry)
+		_ = json.Unmarshal(responseRange.Value, &entry)
+
+		summary.Entries = append(summary.Entries, *entry)
+		summary.Gross = summary.Gross + entry.Gross
+		summary.Refunds = summary.Refunds + entry.Refund
+		summary.Royalties = summary.Royalties + entry.Royalty
+	}
+
+	summary.Net = summary.Gross - summary.Refunds - summary.Royalties
+
+	return summary, nil
+}
+
+// collectRevenue pays a sale of gross tokens from the caller to the revenue account of the branch and splits the royalty
+// Sales without branch are paid with a Transfer event, branch sales pay the royalty from the treasury in the same batch
+func (h *HealthClub) collectRevenue(ctx contractapi.TransactionContextInterface, branchId string, source string, gross int, now time.Time) error {
+
+	userid, err := ctx.GetClientIdentity().GetID()
+	if err != nil {
+		return fmt.Errorf("failed to get userID: %v", err)
+	}
+
+	payee, err := revenueAccount(ctx, branchId)
+	if err != nil {
+		return err
+	}
+
+	batch := erc20.NewTransferBatch(ctx)
+
+	if branchId != "" {
+		err = batch.Transfer(userid, payee, gross)
+	} else {
+		err = h.Transfer(ctx, payee, gross)
+	}
+	if err != nil {
+		return fmt.Errorf("err: %v", err)
+	}
+
+	err = splitRevenue(ctx, batch, branchId, source, userPrefix+userid, gross, now)
+	if err != nil {
+		return err
+	}
+
+	err = batch.Commit()
+	if err != nil {
+		return fmt.Errorf("error:%v", err)
+	}
+
+	return nil
+}
+
+// splitRevenue adds the royalty on a sale of gross tokens, paid by the branch treasury to the owner account, to the batch
+// and books the sale on the revenue ledger. The gross must already be paid to the treasury in the batch.
+// A branch whose treasury is the owner account keeps the royalty where it is, it is only booked
+func splitRevenue(ctx contractapi.TransactionContextInterface, batch *erc20.TransferBatch, branchId string, source string, userId string, gross int, now time.Time) error {
+
+	royalty, err := branchRoyalty(ctx, branchId, gross)
+	if err != nil {
+		return err
+	}
+
+	if royalty > 0 {
+		treasuryID, err := revenueAccount(ctx, branchId)
+		if err != nil {
+			return err
+		}
+
+		adminID, err := getOwnerID(ctx)
+		if err != nil {
+			return err
+		}
+
+		if treasuryID != adminID {
+			err = batch.Transfer(treasuryID, adminID, royalty)
+			if err != nil {
+				return fmt.Errorf("failed to pay royalty of branch %v: %v", branchId, err)
+			}
+		}
+	}
+
+	return putRevenueEntry(ctx, &RevenueEntry{
+		BranchID: branchId,
+		Source:   source,
+		UserID:   userId,
+		Gross:    gross,
+		Royalty:  royalty,
+	}, now)
+}
+
+// refundRevenue adds the refund of tokens to the recipient to the batch and books it on the revenue ledger
+// The branch treasury pays the refund less the royalty on it, which the owner account returns
+func refundRevenue(ctx contractapi.TransactionContextInterface, batch *erc20.TransferBatch, branchId string, source string, userId string, recipient string, refund int, now time.Time) error {
+
+	royalty, err := branchRoyalty(ctx, branchId, refund)
+	if err != nil {
+		return err
+	}
+
+	treasuryID, err := revenueAccount(ctx, branchId)
+	if err != nil {
+		return err
+	}
+
+	if refund-royalty > 0 {
+		err = batch.Transfer(treasuryID, recipient, refund-royalty)
+		if err != nil {
+			return fmt.Errorf("failed to pay refund from treasury: %v", err)
+		}
+	}
+
+	if royalty > 0 {
+		adminID, err := getOwnerID(ctx)
+		if err != nil {
+			return err
+		}
+
+		err = batch.Transfer(adminID, recipient, royalty)
+		if err != nil {
+			return fmt.Errorf("failed to return royalty of branch %v: %v", branchId, err)
+		}
+	}
+
+	return putRevenueEntry(ctx, &RevenueEntry{
+		BranchID: branchId,
+		Source:   source,
+		UserID:   userId,
+		Refund:   refund,
+		Royalty:  -royalty,
+	}, now)
+}
+
+// branchRoyalty returns the royalty owed to headquarters on an amount of branch revenue, nothing without branch
+func branchRoyalty(ctx contractapi.TransactionContextInterface, branchId string, amount int) (int, error) {
+
+	if branchId == "" {
+		return 0, nil
+	}
+
+	branch, err := getBranch(ctx, branchId)
+	if err != nil {
+		return 0, err
+	}
+
+	return (amount * branch.RoyaltyPercent) / 100, nil
+}
+
+// putRevenueEntry writes an entry to the revenue ledger of its branch
+func putRevenueEntry(ctx contractapi.TransactionContextInterface, entry *RevenueEntry, now time.Time) error {
+
+	entry.TxID = ctx.GetStub().GetTxID()
+	entry.Date = now.Format(dateFormat)
+
+	revenueKey, err := ctx.GetStub().CreateCompositeKey(revenueIndex, []string{entry.BranchID, now.Format(keyDateFormat), entry.TxID, entry.UserID})
+	if err != nil {
+		return fmt.Errorf("failed to create the composite key for prefix %s: %v", revenueIndex, err)
+	}
+
+	entrybytes, _ := json.Marshal(entry)
+	err = ctx.GetStub().PutState(revenueKey, entrybytes)
+	if err != nil {
+		return fmt.Errorf("error:%v", err)
+	}
+
+	log.Printf("%v revenue booked at branch %v: gross %v, refund %v, royalty %v", entry.Source, entry.BranchID, entry.Gross, entry.Refund, entry.Royalty)
+
+	return nil
+}
@@ -0,0 +1,39 @@
+package healthclub
+
+import (
+	"testing"
+
+	"github.com/hyperledger/fabric-contract-api-go/contractapi"
+)
+
+func TestBranchSaleWithOwnerAsTreasury(t *testing.T) {
+
+	c := newTestClub(t)
+	c.mustInvoke(t, c.owner, func(ctx contractapi.TransactionContextInterface) error {
+		_, err := c.h.CreateBranch(ctx, "hq", "Headquarters", "manager", c.owner.ID)
+		return err
+	})
+	c.mustInvoke(t, c.owner, func(ctx contractapi.TransactionContextInterface) error {
+		_, err := c.h.SetBranchRoyalty(ctx, "hq", 10)
+		return err
+	})
+
+	alice := c.member(t, "alice", 900)
+	c.mustInvoke(t, alice, func(ctx contractapi.TransactionContextInterface) error {
+		_, err := c.h.GetNewMemberShipAtBranch(ctx, goldlevel, "hq")
+		return err
+	})
+
+	c.assertBalances(t, map[string]int{alice.ID: 0, c.owner.ID: 1000})
+
+	var summary *RevenueSummary
+	c.mustInvoke(t, c.owner, func(ctx contractapi.TransactionContextInterface) error {
+		var err error
+		summary, err = c.h.RevenueReport(ctx, "hq", testStart.Format(dateFormat), testStart.Format(dateFormat))
+		return err
+	})
+
+	if summary.Gross != 1000 || summary.Royalties != 100 {
+		t.Errorf("revenue of hq: gross %v royalties %v, want 1000 and 100", summary.Gross, summary.Royalties)
+	}
+}
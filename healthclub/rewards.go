@@ -0,0 +1,379 @@
+package healthclub
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"strconv"
+	"time"
+
+	"github.com/hyperledger/fabric-contract-api-go/contractapi"
+	"github.com/varun425/MiniClubChaincode/events"
+)
+
+const (
+	rewardRuleIndex  = "rewardrule~RuleID"
+	rewardClaimIndex = "rewardclaim~UserID~RuleID~Period"
+	weekWindow       = "week"
+	monthWindow      = "month"
+	yearWindow       = "year"
+)
+
+// TierMultiplier scales a reward for members of a level, in percent
+type TierMultiplier struct {
+	Level   string `json:"level"`
+	Percent int    `json:"percent"`
+}
+
+// RewardRule pays Amount tokens for every VisitCount days visited within one calendar Window (week, month or year)
+type RewardRule struct {
+	RuleID          string           `json:"ruleid"`
+	VisitCount      int              `json:"visitcount"`
+	Window          string           `json:"window"`
+	Amount          int              `json:"amount"`
+	TierMultipliers []TierMultiplier `json:"tiermultipliers"`
+	Active          bool             `json:"active"`
+}
+
+// PendingReward is an earned but unclaimed reward of a rule for one window
+type PendingReward struct {
+	RuleID  string `json:"ruleid"`
+	Period  string `json:"period"`
+	Visits  int    `json:"visits"`
+	Rewards int    `json:"rewards"`
+	Amount  int    `json:"amount"`
+}
+
+// CreateRewardRule defines a rule paying amount tokens for every visitCount days visited within a window
+// The per level percents scale the amount for members of that level, e.g. 150 pays one and a half times the amount.
+// Only visits made on an active membership count, visits with a guest or day pass earn nothing
+func (h *HealthClub) CreateRewardRule(ctx contractapi.TransactionContextInterface, ruleId string, visitCount int, window string, amount int, goldPercent int, platinumPercent int, diamondPercent int) (string, error) {
+
+	err := checkOwner(ctx)
+	if err != nil {
+		return "", err
+	}
+
+	if ruleId == "" {
+		return "", fmt.Errorf("rule id is required")
+	}
+
+	if visitCount <= 0 || amount <= 0 {
+		return "", fmt.Errorf("visit count and amount must be positive integers")
+	}
+
+	if window != weekWindow && window != monthWindow && window != yearWindow {
+		return "", fmt.Errorf("window must be week, month or year but given: %v", window)
+	}
+
+	if goldPercent < 0 || platinumPercent < 0 || diamondPercent < 0 {
+		return "", fmt.Errorf("level percents cannot be negative")
+	}
+
+	ruleKey, err := ctx.GetStub().CreateCompositeKey(rewardRuleIndex, []string{ruleId})
+	if err != nil {
+		return "", fmt.Errorf("failed to create the composite key for prefix %s: %v", rewardRuleIndex, err)
+	}
+
+	existing, err := ctx.GetStub().GetState(ruleKey)
+	if err != nil {
+		return "", fmt.Errorf("error:%v", err)
+	}
+
+	if existing != nil {
+		return "", fmt.Errorf("reward rule %v already exists", ruleId)
+	}
+
+	rule := RewardRule{
+		RuleID:     ruleId,
+		VisitCount: visitCount,
+		Window:     window,
+		Amount:     amount,
+		TierMultipliers: []TierMultiplier{
+			{Level: goldlevel, Percent: goldPercent},
+			{Level: platinumlevel, Percent: platinumPercent},
+			{Level: diamondlevel, Percent: diamondPercent},
+		},
+		Active: true,
+	}
+
+	rulebytes, _ := json.Marshal(rule)
+	err = ctx.GetStub().PutState(ruleKey, rulebytes)
+	if err != nil {
+		return "", fmt.Errorf("error:%v", err)
+	}
+
+	log.Printf("reward rule %v pays %v tokens every %v visits per %v", ruleId, amount, visitCount, window)
+
+	return "reward rule is created", nil
+}
+
+// DisableRewardRule stops a reward rule from earning new rewards, unclaimed rewards of it are no longer paid
+func (h *HealthClub) DisableRewardRule(ctx contractapi.TransactionContextInterface, ruleId string) (string, error) {
+
+	err := checkOwner(ctx)
+	if err != nil {
+		return "", err
+	}
+
+	ruleKey, err := ctx.GetStub().CreateCompositeKey(rewardRuleIndex, []string{ruleId})
+	if err != nil {
+		return "", fmt.Errorf("failed to create the composite key for prefix %s: %v", rewardRuleIndex, err)
+	}
+
+	rulebytes, err := ctx.GetStub().GetState(ruleKey)
+	if err != nil {
+		return "", fmt.Errorf("error:%v", err)
+	}
+
+	if rulebytes == nil {
+		return "", fmt.Errorf("reward rule %v not found", ruleId)
+	}
+
+	rule := new(RewardRule)
+	_ = json.Unmarshal(rulebytes, &rule)
+	rule.Active = false
+
+	rulebytes, _ = json.Marshal(rule)
+	err = ctx.GetStub().PutState(ruleKey, rulebytes)
+	if err != nil {
+		return "", fmt.Errorf("error:%v", err)
+	}
+
+	return "reward rule is disabled", nil
+}
+
+// GetRewardRules returns all reward rules
+func (h *HealthClub) GetRewardRules(ctx contractapi.TransactionContextInterface) ([]RewardRule, error) {
+
+	ruleIterator, err := ctx.GetStub().GetStateByPartialCompositeKey(rewardRuleIndex, []string{})
+	if err != nil {
+		return nil, fmt.Errorf("error:%v", err.Error())
+	}
+
+	defer ruleIterator.Close()
+
+	rules := []RewardRule{}
+	for ruleIterator.HasNext() {
+		responseRange, err := ruleIterator.Next()
+		if err != nil {
+			return nil, fmt.Errorf("error:%v", err.Error())
+		}
+
+		rule := new(RewardRule)
+		_ = json.Unmarshal(responseRange.Value, &rule)
+		rules = append(rules, *rule)
+	}
+
+	return rules, nil
+}
+
+// PreviewRewards returns the rewards the user has earned through attendance and not claimed yet
+func (h *HealthClub) PreviewRewards(ctx contractapi.TransactionContextInterface, userId string) ([]PendingReward, error) {
+	return h.pendingRewards(ctx, toUserKey(userId))
+}
+
+// ClaimRewards pays the caller all earned and unclaimed attendance rewards, each reward is paid once
+// This function triggers a RewardsClaimed event
+func (h *HealthClub) ClaimRewards(ctx contractapi.TransactionContextInterface) ([]PendingReward, error) {
+
+	userid, err := ctx.GetClientIdentity().GetID()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get userID: %v", err)
+	}
+
+	userId := userPrefix + userid
+
+	pending, err := h.pendingRewards(ctx, userId)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(pending) == 0 {
+		return nil, fmt.Errorf("no rewards to claim")
+	}
+
+	total := 0
+	for _, reward := range pending {
+
+		claimKey, err := ctx.GetStub().CreateCompositeKey(rewardClaimIndex, []string{userId, reward.RuleID, reward.Period})
+		if err != nil {
+			return nil, fmt.Errorf("failed to create the composite key for prefix %s: %v", rewardClaimIndex, err)
+		}
+
+		claimed, err := getClaimedRewards(ctx, claimKey)
+		if err != nil {
+			return nil, err
+		}
+
+		err = ctx.GetStub().PutState(claimKey, []byte(strconv.Itoa(claimed+reward.Rewards)))
+		if err != nil {
+			return nil, fmt.Errorf("error:%v", err)
+		}
+
+		total += reward.Amount
+	}
+
+	if total > 0 {
+		err = h.Mint(ctx, total)
+		if err != nil {
+			return nil, fmt.Errorf("error:%v", err)
+		}
+	}
+
+	err = emitEvent(ctx, events.RewardsClaimed, pending)
+	if err != nil {
+		return nil, err
+	}
+
+	log.Printf("%v claimed %v reward tokens", userId, total)
+
+	return pending, nil
+}
+
+// pendingRewards counts the days visited on a membership by the user per window of every active rule and returns
+// the rewards earned beyond those already claimed. Each reward is scaled by the level of the membership on the
+// visit that completed it, so an upgrade or downgrade changes only the rewards earned afterwards
+func (h *HealthClub) pendingRewards(ctx contractapi.TransactionContextInterface, userId string) ([]PendingReward, error) {
+
+	rules, err := h.GetRewardRules(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	attendance, err := getAttendance(ctx, userId, time.Time{}, time.Date(9999, 12, 31, 0, 0, 0, 0, time.UTC))
+	if err != nil {
+		return nil, err
+	}
+
+	// visits recorded before the level was stored on them take the level of their membership
+	membershipLevels := map[string]string{}
+	for i, visit := range attendance {
+		if visit.MembershipID == "" || visit.Level != "" {
+			continue
+		}
+
+		if _, ok := membershipLevels[visit.MembershipID]; !ok {
+			membershipdetails, err := getMembership(ctx, visit.MembershipID)
+			if err != nil {
+				return nil, err
+			}
+			membershipLevels[visit.MembershipID] = membershipdetails.Level
+		}
+		attendance[i].Level = membershipLevels[visit.MembershipID]
+	}
+
+	pending := []PendingReward{}
+	for _, rule := range rules {
+
+		if !rule.Active {
+			continue
+		}
+
+		// visits are counted as distinct days so repeated check-ins on one day count once,
+		// a day counts at the level of its first visit. Attendance is read in the order of the days.
+		visitedDays := map[string][]string{}
+		dayLevels := map[string]string{}
+		periods := []string{}
+		for _, visit := range attendance {
+			// the membership of a visit is set only if it gave access, pass visits have none
+			if visit.MembershipID == "" {
+				continue
+			}
+
+			day, err := time.Parse(dateFormat, visit.Date)
+			if err != nil {
+				continue
+			}
+
+			if _, ok := dayLevels[visit.Date]; ok {
+				continue
+			}
+			dayLevels[visit.Date] = visit.Level
+
+			period := rewardPeriod(rule.Window, day)
+			if visitedDays[period] == nil {
+				periods = append(periods, period)
+			}
+			visitedDays[period] = append(visitedDays[period], visit.Date)
+		}
+
+		for _, period := range periods {
+
+			earned := len(visitedDays[period]) / rule.VisitCount
+			if earned == 0 {
+				continue
+			}
+
+			claimKey, err := ctx.GetStub().CreateCompositeKey(rewardClaimIndex, []string{userId, rule.RuleID, period})
+			if err != nil {
+				return nil, fmt.Errorf("failed to create the composite key for prefix %s: %v", rewardClaimIndex, err)
+			}
+
+			claimed, err := getClaimedRewards(ctx, claimKey)
+			if err != nil {
+				return nil, err
+			}
+
+			if earned <= claimed {
+				continue
+			}
+
+			amount := 0
+			for reward := claimed + 1; reward <= earned; reward++ {
+				completedOn := visitedDays[period][reward*rule.VisitCount-1]
+				amount += (rule.Amount * tierPercent(rule, dayLevels[completedOn])) / 100
+			}
+
+			pending = append(pending, PendingReward{
+				RuleID:  rule.RuleID,
+				Period:  period,
+				Visits:  len(visitedDays[period]),
+				Rewards: earned - claimed,
+				Amount:  amount,
+			})
+		}
+	}
+
+	return pending, nil
+}
+
+// getClaimedRewards returns the number of rewards already claimed under the claim key
+func getClaimedRewards(ctx contractapi.TransactionContextInterface, claimKey string) (int, error) {
+
+	claimedbytes, err := ctx.GetStub().GetState(claimKey)
+	if err != nil {
+		return 0, fmt.Errorf("error:%v", err)
+	}
+
+	claimed, _ := strconv.Atoi(string(claimedbytes))
+
+	return claimed, nil
+}
+
+// rewardPeriod names the calendar window containing the day, e.g. 2026-W42, 2026-10 or 2026
+func rewardPeriod(window string, day time.Time) string {
+
+	switch window {
+	case weekWindow:
+		year, week := day.ISOWeek()
+		return fmt.Sprintf("%d-W%02d", year, week)
+	case yearWindow:
+		return day.Format("2006")
+	default:
+		return day.Format("2006-01")
+	}
+}
+
+// tierPercent returns the multiplier of the rule for the level, 0 if the rule does not define one
+// or the user has no membership
+func tierPercent(rule RewardRule, level string) int {
+
+	for _, multiplier := range rule.TierMultipliers {
+		if multiplier.Level == level {
+			return multiplier.Percent
+		}
+	}
+
+	return 0
+}
@@ -0,0 +1,137 @@
+package healthclub
+
+import (
+	"testing"
+	"time"
+
+	"github.com/hyperledger/fabric-contract-api-go/contractapi"
+	"github.com/varun425/MiniClubChaincode/internal/chaincodetest"
+)
+
+func TestRewardsCountMembershipVisitsOnly(t *testing.T) {
+
+	c := newTestClub(t)
+	c.mustInvoke(t, c.owner, func(ctx contractapi.TransactionContextInterface) error {
+		_, err := c.h.CreateRewardRule(ctx, "twice-a-week", 2, weekWindow, 50, 100, 150, 200)
+		return err
+	})
+
+	alice := c.member(t, "alice", 900)
+	c.buy(t, alice, goldlevel)
+
+	// dave visits on day passes without membership
+	dave := c.member(t, "dave", 0)
+	for day := 0; day < 2; day++ {
+		c.mustInvoke(t, dave, func(ctx contractapi.TransactionContextInterface) error {
+			_, err := c.h.BuyDayPass(ctx, testStart.AddDate(0, 0, day).Format(dateFormat))
+			return err
+		})
+	}
+
+	for day := 0; day < 2; day++ {
+		visit(t, c, alice, "gym")
+		visit(t, c, dave, "gym")
+		c.after(22 * time.Hour)
+	}
+
+	for _, user := range []*chaincodetest.Identity{alice, dave} {
+		var pending []PendingReward
+		c.mustInvoke(t, user, func(ctx contractapi.TransactionContextInterface) error {
+			var err error
+			pending, err = c.h.PreviewRewards(ctx, user.ID)
+			return err
+		})
+
+		expected := 0
+		if user == alice {
+			expected = 50
+		}
+
+		total := 0
+		for _, reward := range pending {
+			total += reward.Amount
+		}
+		if total != expected {
+			t.Errorf("pending rewards of %v = %v (%+v), want %v", user.ID, total, pending, expected)
+		}
+	}
+
+	c.mustInvoke(t, alice, func(ctx contractapi.TransactionContextInterface) error {
+		_, err := c.h.ClaimRewards(ctx)
+		return err
+	})
+	c.assertBalances(t, map[string]int{alice.ID: 50})
+
+	if _, err := c.invoke(dave, func(ctx contractapi.TransactionContextInterface) error {
+		_, err := c.h.ClaimRewards(ctx)
+		return err
+	}); err == nil {
+		t.Errorf("rewards claimed for day pass visits")
+	}
+}
+
+func TestTierPercentOfUnknownLevel(t *testing.T) {
+
+	rule := RewardRule{TierMultipliers: []TierMultiplier{{Level: goldlevel, Percent: 150}}}
+
+	if percent := tierPercent(rule, goldlevel); percent != 150 {
+		t.Errorf("percent of %v = %v, want 150", goldlevel, percent)
+	}
+
+	for _, level := range []string{"", diamondlevel} {
+		if percent := tierPercent(rule, level); percent != 0 {
+			t.Errorf("percent of level %q = %v, want 0", level, percent)
+		}
+	}
+}
+
+func TestRewardsScaleByTheLevelOfTheVisits(t *testing.T) {
+
+	c := newTestClub(t)
+	c.mustInvoke(t, c.owner, func(ctx contractapi.TransactionContextInterface) error {
+		_, err := c.h.CreateRewardRule(ctx, "twice-a-week", 2, weekWindow, 100, 100, 150, 200)
+		return err
+	})
+
+	alice := c.member(t, "alice", 9900)
+	c.buy(t, alice, goldlevel)
+
+	// the first reward is earned on Gold, the second after the upgrade to Diamond
+	for day := 0; day < 4; day++ {
+		if day == 2 {
+			c.mustInvoke(t, alice, func(ctx contractapi.TransactionContextInterface) error {
+				_, err := c.h.UpgradeMembership(ctx, diamondlevel)
+				return err
+			})
+		}
+		visit(t, c, alice, "gym")
+		c.after(22 * time.Hour)
+	}
+
+	var visits []Attendance
+	c.mustInvoke(t, alice, func(ctx contractapi.TransactionContextInterface) error {
+		var err error
+		visits, err = c.h.GetAttendance(ctx, alice.ID, testStart.Format(dateFormat), c.Now.Format(dateFormat))
+		return err
+	})
+	for i, visit := range visits {
+		expected := goldlevel
+		if i >= 2 {
+			expected = diamondlevel
+		}
+		if visit.Level != expected {
+			t.Errorf("visit %v on %v recorded at %q, want %v", i, visit.Date, visit.Level, expected)
+		}
+	}
+
+	var pending []PendingReward
+	c.mustInvoke(t, alice, func(ctx contractapi.TransactionContextInterface) error {
+		var err error
+		pending, err = c.h.PreviewRewards(ctx, alice.ID)
+		return err
+	})
+
+	if len(pending) != 1 || pending[0].Rewards != 2 || pending[0].Amount != 300 {
+		t.Errorf("pending rewards %+v, want 2 rewards of 100 at 100%% and 200%%", pending)
+	}
+}
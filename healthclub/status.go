@@ -0,0 +1,210 @@
+package healthclub
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"strconv"
+	"time"
+
+	"github.com/hyperledger/fabric-contract-api-go/contractapi"
+)
+
+// Membership statuses
+// Memberships are active from purchase and cannot be transferred, so there is no Pending or Transferred status
+const (
+	StatusActive    = "Active"
+	StatusFrozen    = "Frozen"
+	StatusExpired   = "Expired"
+	StatusCancelled = "Cancelled"
+)
+
+// membershipTransitions lists the statuses a membership may move to from each status
+// Expired and Cancelled are final. The empty status is a membership being created.
+var membershipTransitions = map[string][]string{
+	"":           {StatusActive},
+	StatusActive: {StatusFrozen, StatusExpired, StatusCancelled},
+	StatusFrozen: {StatusActive, StatusExpired, StatusCancelled},
+}
+
+// StatusChange records one status transition of a membership
+type StatusChange struct {
+	From   string `json:"from"`
+	To     string `json:"to"`
+	Date   string `json:"date"`
+	Reason string `json:"reason"`
+}
+
+// legacyMembershipFlags holds the booleans that described a membership before Status was introduced
+type legacyMembershipFlags struct {
+	IsCompleted bool
+	IsCancelled bool
+	IsUpdated   bool
+	IsFrozen    bool
+}
+
+// transitionMembership moves the membership to the given status and records the change in its history
+// This is the only place the Status of a membership is changed
+func transitionMembership(membership *Membership, status string, now time.Time, reason string) error {
+
+	allowed := false
+	for _, next := range membershipTransitions[membership.Status] {
+		if next == status {
+			allowed = true
+			break
+		}
+	}
+
+	if !allowed {
+		if membership.Status == "" {
+			return fmt.Errorf("membership cannot be created as %v", status)
+		}
+		return fmt.Errorf("membership is %v and cannot become %v", membership.Status, status)
+	}
+
+	membership.StatusHistory = append(membership.StatusHistory, StatusChange{
+		From:   membership.Status,
+		To:     status,
+		Date:   now.Format(dateFormat),
+		Reason: reason,
+	})
+	membership.Status = status
+
+	return nil
+}
+
+// getMembership reads a membership from the world state
+// records written before Status was introduced are converted in memory, see MigrateMemberships,
+// and frozen memberships past their FrozenUntil are resumed in memory, see endElapsedFreeze
+func getMembership(ctx contractapi.TransactionContextInterface, membershipId string) (*Membership, error) {
+
+	membershipbytes, err := ctx.GetStub().GetState(membershipId)
+	if err != nil {
+		return nil, fmt.Errorf("error:%v", err)
+	}
+
+	if membershipbytes == nil {
+		return nil, fmt.Errorf("membership %v not found", membershipId)
+	}
+
+	membershipdetails := new(Membership)
+	_ = json.Unmarshal(membershipbytes, &membershipdetails)
+
+	if membershipdetails.Status == "" {
+		migrateMembershipStatus(membershipbytes, membershipdetails)
+	}
+
+	// a freeze that has run its course is ended in memory, the next write of the membership persists it
+	if membershipdetails.Status == StatusFrozen {
+		now, err := getTxTime(ctx)
+		if err != nil {
+			return nil, fmt.Errorf("error:%v", err)
+		}
+
+		err = endElapsedFreeze(membershipdetails, now)
+		if err != nil {
+			return nil, fmt.Errorf("error:%v", err)
+		}
+	}
+
+	return membershipdetails, nil
+}
+
+// putMembership writes a membership to the world state
+func putMembership(ctx contractapi.TransactionContextInterface, membershipId string, membership *Membership) error {
+
+	membership.DocType = membershipDocType
+	membership.StartDateKey = toDateKey(membership.StartDate)
+	membership.EndDateKey = toDateKey(membership.EndDate)
+
+	membershipbytes, _ := json.Marshal(membership)
+	err := ctx.GetStub().PutState(membershipId, membershipbytes)
+	if err != nil {
+		return fmt.Errorf("error:%v", err)
+	}
+
+	return nil
+}
+
+// migrateMembershipStatus derives the Status of a legacy membership from its IsCompleted, IsCancelled and IsFrozen flags
+func migrateMembershipStatus(membershipbytes []byte, membership *Membership) {
+
+	legacy := new(legacyMembershipFlags)
+	_ = json.Unmarshal(membershipbytes, &legacy)
+
+	status := StatusActive
+	if legacy.IsCancelled {
+		status = StatusCancelled
+	} else if legacy.IsCompleted {
+		status = StatusExpired
+	} else if legacy.IsFrozen {
+		status = StatusFrozen
+	}
+
+	membership.Status = status
+	membership.StatusHistory = []StatusChange{{
+		From:   "",
+		To:     status,
+		Date:   membership.StartDate,
+		Reason: "migrated from legacy flags",
+	}}
+}
+
+// MigrateMemberships rewrites up to count memberships starting at Membership-<startIndex> that were stored
+// before Status was introduced, replacing IsCompleted, IsCancelled, IsUpdated and IsFrozen with Status,
+// or before the docType and date keys rich queries select on were introduced
+// returns the number of memberships migrated
+func (h *HealthClub) MigrateMemberships(ctx contractapi.TransactionContextInterface, startIndex int, count int) (int, error) {
+
+	err := checkOwner(ctx)
+	if err != nil {
+		return 0, err
+	}
+
+	if startIndex <= 0 || count <= 0 {
+		return 0, fmt.Errorf("start index and count must be positive integers")
+	}
+
+	resInBytes, err := ctx.GetStub().GetState("TotalMemberships")
+	if err != nil {
+		return 0, fmt.Errorf("error:%v", err.Error())
+	}
+
+	TotalMemberships, _ := strconv.Atoi(string(resInBytes))
+
+	migrated := 0
+	for i := startIndex; i < startIndex+count && i <= TotalMemberships; i++ {
+
+		membershipId := membershipPrefix + strconv.Itoa(i)
+		membershipbytes, err := ctx.GetStub().GetState(membershipId)
+		if err != nil {
+			return 0, fmt.Errorf("error:%v", err.Error())
+		}
+
+		if membershipbytes == nil {
+			continue
+		}
+
+		membershipdetails := new(Membership)
+		_ = json.Unmarshal(membershipbytes, &membershipdetails)
+
+		if membershipdetails.Status != "" && membershipdetails.DocType != "" && membershipdetails.EndDateKey != "" {
+			continue
+		}
+
+		if membershipdetails.Status == "" {
+			migrateMembershipStatus(membershipbytes, membershipdetails)
+		}
+
+		err = putMembership(ctx, membershipId, membershipdetails)
+		if err != nil {
+			return 0, err
+		}
+
+		migrated++
+	}
+
+	log.Printf("%v memberships migrated", migrated)
+
+	return migrated, nil
+}
@@ -0,0 +1,866 @@
+package healthclub
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/hyperledger/fabric-contract-api-go/contractapi"
+	erc20 "github.com/varun425/MiniClubChaincode/erc20"
+	"github.com/varun425/MiniClubChaincode/events"
+)
+
+const (
+	trainerIndex             = "trainer~TrainerID"
+	sessionOfferIndex        = "sessionoffer~OfferID"
+	sessionBookingIndex      = "sessionbooking~BookingID"
+	trainerCommissionKey     = "TrainerCommission"
+	trainerEscrowAccount     = "HealthClubTrainerEscrow"
+	defaultTrainerCommission = 20
+	sessionBooked            = "Booked"
+	sessionCompleted         = "Completed"
+	sessionCancelled         = "Cancelled"
+	sessionDisputed          = "Disputed"
+	sessionRefunded          = "Refunded"
+	sessionDisputeWindow     = 72 * time.Hour
+	sessionConfirmDeadline   = 7 * 24 * time.Hour
+)
+
+// Trainer is a registered user selling personal training sessions, offers can only be published once staff approved the trainer
+// Ratings are aggregated as the sum and number of ratings given by members after completed sessions
+type Trainer struct {
+	TrainerID   string `json:"trainerid"`
+	Name        string `json:"name"`
+	Bio         string `json:"bio"`
+	Approved    bool   `json:"approved"`
+	ApprovedBy  string `json:"approvedby"`
+	RatingTotal int    `json:"ratingtotal"`
+	RatingCount int    `json:"ratingcount"`
+}
+
+// SessionOffer is a session a trainer sells at Price tokens, Slots are the start times (MM-DD-YYYY HH:MM:SS) still available
+type SessionOffer struct {
+	OfferID   string   `json:"offerid"`
+	TrainerID string   `json:"trainerid"`
+	Title     string   `json:"title"`
+	Price     int      `json:"price"`
+	Slots     []string `json:"slots"`
+}
+
+// SessionBooking is a booked session whose price is held in escrow until both the member and the trainer confirm completion,
+// or until the dispute window after the slot has passed without the member disputing it. Disputed sessions are resolved by staff,
+// sessions the trainer has not confirmed by the confirm deadline after the slot can be reclaimed by the member.
+// The commission is revenue of BranchID, the home branch of the membership of the member, and is paid to its treasury
+type SessionBooking struct {
+	BookingID        string `json:"bookingid"`
+	OfferID          string `json:"offerid"`
+	TrainerID        string `json:"trainerid"`
+	MemberID         string `json:"memberid"`
+	Slot             string `json:"slot"`
+	Price            int    `json:"price"`
+	Commission       int    `json:"commission"`
+	Status           string `json:"status"`
+	MemberConfirmed  bool   `json:"memberconfirmed"`
+	TrainerConfirmed bool   `json:"trainerconfirmed"`
+	Rating           int    `json:"rating"`
+	DisputeReason    string `json:"disputereason"`
+	ResolvedBy       string `json:"resolvedby"`
+	BranchID         string `json:"branchid"`
+}
+
+// RegisterTrainer registers the caller as a trainer, the trainer must be approved by staff before publishing offers
+func (h *HealthClub) RegisterTrainer(ctx contractapi.TransactionContextInterface, name string, bio string) (string, error) {
+
+	userid, err := ctx.GetClientIdentity().GetID()
+	if err != nil {
+		return "", fmt.Errorf("failed to get userID: %v", err)
+	}
+
+	trainerId := userPrefix + userid
+
+	user, err := ctx.GetStub().GetState(trainerId)
+	if err != nil {
+		return "", fmt.Errorf("error:%v", err)
+	}
+
+	if user == nil {
+		return "", fmt.Errorf("user not found, register as a user first")
+	}
+
+	if _, err := getTrainer(ctx, trainerId); err == nil {
+		return "", fmt.Errorf("trainer %v already registered", trainerId)
+	}
+
+	trainer := &Trainer{
+		TrainerID: trainerId,
+		Name:      name,
+		Bio:       bio,
+	}
+
+	err = putTrainer(ctx, trainer)
+	if err != nil {
+		return "", err
+	}
+
+	log.Printf("trainer %v registered and awaiting approval", trainerId)
+
+	return "Trainer registered, awaiting staff approval", nil
+}
+
+// ApproveTrainer allows a registered trainer to publish session offers
+func (h *HealthClub) ApproveTrainer(ctx contractapi.TransactionContextInterface, trainerId string) (string, error) {
+
+	err := checkStaff(ctx)
+	if err != nil {
+		return "", err
+	}
+
+	trainer, err := getTrainer(ctx, toUserKey(trainerId))
+	if err != nil {
+		return "", err
+	}
+
+	if trainer.Approved {
+		return "", fmt.Errorf("trainer %v is already approved", trainer.TrainerID)
+	}
+
+	staffid, err := ctx.GetClientIdentity().GetID()
+	if err != nil {
+		return "", fmt.Errorf("failed to get userID: %v", err)
+	}
+
+	trainer.Approved = true
+	trainer.ApprovedBy = staffid
+
+	err = putTrainer(ctx, trainer)
+	if err != nil {
+		return "", err
+	}
+
+	return "Trainer approved", nil
+}
+
+// GetTrainer returns a trainer with the aggregated rating
+func (h *HealthClub) GetTrainer(ctx contractapi.TransactionContextInterface, trainerId string) (*Trainer, error) {
+	return getTrainer(ctx, toUserKey(trainerId))
+}
+
+// CreateSessionOffer publishes a session of the calling approved trainer, slots are start times MM-DD-YYYY HH:MM:SS
+func (h *HealthClub) CreateSessionOffer(ctx contractapi.TransactionContextInterface, offerId string, title string, price int, slots []string) (string, error) {
+
+	userid, err := ctx.GetClientIdentity().GetID()
+	if err != nil {
+		return "", fmt.Errorf("failed to get userID: %v", err)
+	}
+
+	trainer, err := getTrainer(ctx, userPrefix+userid)
+	if err != nil {
+		return "", err
+	}
+
+	if !trainer.Approved {
+		return "", fmt.Errorf("trainer %v is not approved yet", trainer.TrainerID)
+	}
+
+	if offerId == "" || title == "" {
+		return "", fmt.Errorf("offer id and title are required")
+	}
+
+	if price <= 0 {
+		return "", fmt.Errorf("price must be a positive integer")
+	}
+
+	if len(slots) == 0 {
+		return "", fmt.Errorf("at least one slot is required")
+	}
+
+	for _, slot := range slots {
+		if _, err := time.Parse(timeFormat, slot); err != nil {
+			return "", fmt.Errorf("invalid slot %v, expected MM-DD-YYYY HH:MM:SS", slot)
+		}
+	}
+
+	if _, err := getSessionOffer(ctx, offerId); err == nil {
+		return "", fmt.Errorf("session offer %v already exists", offerId)
+	}
+
+	offer := &SessionOffer{
+		OfferID:   offerId,
+		TrainerID: trainer.TrainerID,
+		Title:     title,
+		Price:     price,
+		Slots:     slots,
+	}
+
+	err = putSessionOffer(ctx, offer)
+	if err != nil {
+		return "", err
+	}
+
+	log.Printf("trainer %v offers %v at %v tokens in %v slots", trainer.TrainerID, offerId, price, len(slots))
+
+	return "session offer is created", nil
+}
+
+// GetSessionOffer returns a session offer with its available slots
+func (h *HealthClub) GetSessionOffer(ctx contractapi.TransactionContextInterface, offerId string) (*SessionOffer, error) {
+	return getSessionOffer(ctx, offerId)
+}
+
+// BookSession books an available slot of an offer for the caller, whose membership must grant access to the club
+// The price is held in escrow until the session is confirmed, cancelled or its dispute resolved. Returns the booking id
+func (h *HealthClub) BookSession(ctx contractapi.TransactionContextInterface, offerId string, slot string) (string, error) {
+
+	userid, err := ctx.GetClientIdentity().GetID()
+	if err != nil {
+		return "", fmt.Errorf("failed to get userID: %v", err)
+	}
+
+	memberId := userPrefix + userid
+
+	offer, err := getSessionOffer(ctx, offerId)
+	if err != nil {
+		return "", err
+	}
+
+	if offer.TrainerID == memberId {
+		return "", fmt.Errorf("trainers cannot book their own sessions")
+	}
+
+	if !containsUser(offer.Slots, slot) {
+		return "", fmt.Errorf("slot %v is not available in offer %v", slot, offerId)
+	}
+
+	now, err := getTxTime(ctx)
+	if err != nil {
+		return "", fmt.Errorf("error:%v", err)
+	}
+
+	slotStart, _ := time.Parse(timeFormat, slot)
+	if !now.Before(slotStart) {
+		return "", fmt.Errorf("slot %v has already started", slot)
+	}
+
+	_, membershipdetails, err := getAccessMembership(ctx, memberId)
+	if err != nil {
+		return "", fmt.Errorf("error:%v", err)
+	}
+
+	err = checkMembershipAccess(membershipdetails, now)
+	if err != nil {
+		return "", fmt.Errorf("cannot book session: %v", err)
+	}
+
+	commissionPercent, err := h.GetTrainerCommission(ctx)
+	if err != nil {
+		return "", err
+	}
+
+	offer.Slots = removeUser(offer.Slots, slot)
+
+	err = putSessionOffer(ctx, offer)
+	if err != nil {
+		return "", err
+	}
+
+	booking := &SessionBooking{
+		BookingID:  ctx.GetStub().GetTxID(),
+		OfferID:    offerId,
+		TrainerID:  offer.TrainerID,
+		MemberID:   memberId,
+		Slot:       slot,
+		Price:      offer.Price,
+		Commission: (offer.Price * commissionPercent) / 100,
+		Status:     sessionBooked,
+		BranchID:   membershipdetails.BranchID,
+	}
+
+	err = putSessionBooking(ctx, booking)
+	if err != nil {
+		return "", err
+	}
+
+	// hold the price in escrow
+	err = h.Transfer(ctx, trainerEscrowAccount, offer.Price)
+	if err != nil {
+		return "", fmt.Errorf("err: %v", err)
+	}
+
+	log.Printf("%v booked session %v of %v at %v", memberId, offerId, offer.TrainerID, slot)
+
+	return booking.BookingID, nil
+}
+
+// ConfirmSession confirms completion of a booked session by the calling member or trainer
+// Once both confirmed, the escrow is released to the trainer less the club commission, which goes to the branch treasury.
+// Once the dispute window after the slot has passed, the confirmation of the trainer alone releases the escrow.
+// This function triggers a SessionCompleted event on release
+func (h *HealthClub) ConfirmSession(ctx contractapi.TransactionContextInterface, bookingId string) (*SessionBooking, error) {
+
+	userid, err := ctx.GetClientIdentity().GetID()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get userID: %v", err)
+	}
+
+	callerId := userPrefix + userid
+
+	booking, err := getSessionBooking(ctx, bookingId)
+	if err != nil {
+		return nil, err
+	}
+
+	if booking.Status != sessionBooked {
+		return nil, fmt.Errorf("session %v is %v", bookingId, booking.Status)
+	}
+
+	now, err := getTxTime(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("error:%v", err)
+	}
+
+	slotStart, _ := time.Parse(timeFormat, booking.Slot)
+	if now.Before(slotStart) {
+		return nil, fmt.Errorf("session %v has not started yet", bookingId)
+	}
+
+	switch callerId {
+	case booking.MemberID:
+		booking.MemberConfirmed = true
+	case booking.TrainerID:
+		booking.TrainerConfirmed = true
+	default:
+		return nil, fmt.Errorf("only the member or the trainer of the session can confirm it")
+	}
+
+	// the member did not dispute the session in time
+	undisputed := booking.TrainerConfirmed && !now.Before(slotStart.Add(sessionDisputeWindow))
+
+	if (!booking.MemberConfirmed || !booking.TrainerConfirmed) && !undisputed {
+		err = putSessionBooking(ctx, booking)
+		if err != nil {
+			return nil, err
+		}
+
+		return booking, nil
+	}
+
+	err = releaseSession(ctx, booking, now)
+	if err != nil {
+		return nil, err
+	}
+
+	return booking, nil
+}
+
+// DisputeSession disputes a booked session by the calling member within the dispute window after the slot,
+// holding the escrow until staff resolves the dispute with ResolveSession.
+// This function triggers a SessionDisputed event
+func (h *HealthClub) DisputeSession(ctx contractapi.TransactionContextInterface, bookingId string, reason string) (*SessionBooking, error) {
+
+	userid, err := ctx.GetClientIdentity().GetID()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get userID: %v", err)
+	}
+
+	booking, err := getSessionBooking(ctx, bookingId)
+	if err != nil {
+		return nil, err
+	}
+
+	if booking.MemberID != userPrefix+userid {
+		return nil, fmt.Errorf("only the member of the session can dispute it")
+	}
+
+	if booking.Status != sessionBooked {
+		return nil, fmt.Errorf("session %v is %v", bookingId, booking.Status)
+	}
+
+	if reason == "" {
+		return nil, fmt.Errorf("a reason is required to dispute a session")
+	}
+
+	now, err := getTxTime(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("error:%v", err)
+	}
+
+	slotStart, _ := time.Parse(timeFormat, booking.Slot)
+	if now.Before(slotStart) {
+		return nil, fmt.Errorf("session %v has not started yet, use CancelSession", bookingId)
+	}
+
+	if !now.Before(slotStart.Add(sessionDisputeWindow)) {
+		return nil, fmt.Errorf("session %v can only be disputed within %v of its start", bookingId, sessionDisputeWindow)
+	}
+
+	booking.Status = sessionDisputed
+	booking.DisputeReason = reason
+
+	err = putSessionBooking(ctx, booking)
+	if err != nil {
+		return nil, err
+	}
+
+	err = emitEvent(ctx, events.SessionDisputed, booking)
+	if err != nil {
+		return nil, err
+	}
+
+	log.Printf("session %v disputed by %v: %v", bookingId, booking.MemberID, reason)
+
+	return booking, nil
+}
+
+// ResolveSession resolves a disputed session by staff, releasing the escrow to the trainer as if the session
+// was confirmed by both parties, or refunding the full price to the member.
+// This function triggers a SessionCompleted or a SessionRefunded event
+func (h *HealthClub) ResolveSession(ctx contractapi.TransactionContextInterface, bookingId string, releaseToTrainer bool) (*SessionBooking, error) {
+
+	err := checkStaff(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	staffid, err := ctx.GetClientIdentity().GetID()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get userID: %v", err)
+	}
+
+	booking, err := getSessionBooking(ctx, bookingId)
+	if err != nil {
+		return nil, err
+	}
+
+	if booking.Status != sessionDisputed {
+		return nil, fmt.Errorf("session %v is %v, only disputed sessions can be resolved", bookingId, booking.Status)
+	}
+
+	now, err := getTxTime(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("error:%v", err)
+	}
+
+	booking.ResolvedBy = staffid
+
+	if releaseToTrainer {
+		err = releaseSession(ctx, booking, now)
+		if err != nil {
+			return nil, err
+		}
+
+		return booking, nil
+	}
+
+	err = refundSession(ctx, booking)
+	if err != nil {
+		return nil, err
+	}
+
+	log.Printf("disputed session %v refunded to %v by %v", bookingId, booking.MemberID, staffid)
+
+	return booking, nil
+}
+
+// ReclaimSession refunds the full price of a booked session to the calling member once the confirm deadline after
+// the slot has passed without the trainer confirming the session, so the escrow is not held forever.
+// This function triggers a SessionRefunded event
+func (h *HealthClub) ReclaimSession(ctx contractapi.TransactionContextInterface, bookingId string) (*SessionBooking, error) {
+
+	userid, err := ctx.GetClientIdentity().GetID()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get userID: %v", err)
+	}
+
+	booking, err := getSessionBooking(ctx, bookingId)
+	if err != nil {
+		return nil, err
+	}
+
+	if booking.MemberID != userPrefix+userid {
+		return nil, fmt.Errorf("only the member of the session can reclaim it")
+	}
+
+	if booking.Status != sessionBooked {
+		return nil, fmt.Errorf("session %v is %v", bookingId, booking.Status)
+	}
+
+	if booking.TrainerConfirmed {
+		return nil, fmt.Errorf("session %v is confirmed by the trainer, use DisputeSession", bookingId)
+	}
+
+	now, err := getTxTime(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("error:%v", err)
+	}
+
+	slotStart, _ := time.Parse(timeFormat, booking.Slot)
+	if now.Before(slotStart.Add(sessionConfirmDeadline)) {
+		return nil, fmt.Errorf("session %v can be reclaimed once it is unconfirmed %v after its start", bookingId, sessionConfirmDeadline)
+	}
+
+	err = refundSession(ctx, booking)
+	if err != nil {
+		return nil, err
+	}
+
+	log.Printf("unconfirmed session %v reclaimed by %v", bookingId, booking.MemberID)
+
+	return booking, nil
+}
+
+// CancelSession cancels a booked session that has not started, by the member or the trainer
+// The escrowed price is refunded to the member and the slot becomes available again
+func (h *HealthClub) CancelSession(ctx contractapi.TransactionContextInterface, bookingId string) (string, error) {
+
+	userid, err := ctx.GetClientIdentity().GetID()
+	if err != nil {
+		return "", fmt.Errorf("failed to get userID: %v", err)
+	}
+
+	callerId := userPrefix + userid
+
+	booking, err := getSessionBooking(ctx, bookingId)
+	if err != nil {
+		return "", err
+	}
+
+	if callerId != booking.MemberID && callerId != booking.TrainerID {
+		return "", fmt.Errorf("only the member or the trainer of the session can cancel it")
+	}
+
+	if booking.Status != sessionBooked {
+		return "", fmt.Errorf("session %v is %v", bookingId, booking.Status)
+	}
+
+	now, err := getTxTime(ctx)
+	if err != nil {
+		return "", fmt.Errorf("error:%v", err)
+	}
+
+	slotStart, _ := time.Parse(timeFormat, booking.Slot)
+	if !now.Before(slotStart) {
+		return "", fmt.Errorf("session %v has already started", bookingId)
+	}
+
+	offer, err := getSessionOffer(ctx, booking.OfferID)
+	if err != nil {
+		return "", err
+	}
+
+	offer.Slots = append(offer.Slots, booking.Slot)
+
+	err = putSessionOffer(ctx, offer)
+	if err != nil {
+		return "", err
+	}
+
+	booking.Status = sessionCancelled
+
+	err = putSessionBooking(ctx, booking)
+	if err != nil {
+		return "", err
+	}
+
+	batch := erc20.NewTransferBatch(ctx)
+	err = batch.Transfer(trainerEscrowAccount, strings.TrimPrefix(booking.MemberID, userPrefix), booking.Price)
+	if err != nil {
+		return "", fmt.Errorf("failed to refund escrow: %v", err)
+	}
+
+	err = batch.Commit()
+	if err != nil {
+		return "", fmt.Errorf("error:%v", err)
+	}
+
+	return "Session cancelled", nil
+}
+
+// RateTrainer rates the trainer of a completed session of the caller from 1 to 5 stars, once per session
+func (h *HealthClub) RateTrainer(ctx contractapi.TransactionContextInterface, bookingId string, stars int) (*Trainer, error) {
+
+	userid, err := ctx.GetClientIdentity().GetID()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get userID: %v", err)
+	}
+
+	booking, err := getSessionBooking(ctx, bookingId)
+	if err != nil {
+		return nil, err
+	}
+
+	if booking.MemberID != userPrefix+userid {
+		return nil, fmt.Errorf("only the member of the session can rate it")
+	}
+
+	if booking.Status != sessionCompleted {
+		return nil, fmt.Errorf("only completed sessions can be rated")
+	}
+
+	if booking.Rating != 0 {
+		return nil, fmt.Errorf("session %v is already rated", bookingId)
+	}
+
+	if stars < 1 || stars > 5 {
+		return nil, fmt.Errorf("rating must be between 1 and 5 stars")
+	}
+
+	trainer, err := getTrainer(ctx, booking.TrainerID)
+	if err != nil {
+		return nil, err
+	}
+
+	booking.Rating = stars
+	trainer.RatingTotal = trainer.RatingTotal + stars
+	trainer.RatingCount = trainer.RatingCount + 1
+
+	err = putSessionBooking(ctx, booking)
+	if err != nil {
+		return nil, err
+	}
+
+	err = putTrainer(ctx, trainer)
+	if err != nil {
+		return nil, err
+	}
+
+	return trainer, nil
+}
+
+// GetSessionBooking returns a booked session
+func (h *HealthClub) GetSessionBooking(ctx contractapi.TransactionContextInterface, bookingId string) (*SessionBooking, error) {
+	return getSessionBooking(ctx, bookingId)
+}
+
+// SetTrainerCommission sets the percent of every session price kept by the club, paid to the treasury of the branch of the session
+func (h *HealthClub) SetTrainerCommission(ctx contractapi.TransactionContextInterface, percent int) (string, error) {
+
+	err := checkOwner(ctx)
+	if err != nil {
+		return "", err
+	}
+
+	if percent < 0 || percent > 100 {
+		return "", fmt.Errorf("commission must be between 0 and 100 percent")
+	}
+
+	err = ctx.GetStub().PutState(trainerCommissionKey, []byte(strconv.Itoa(percent)))
+	if err != nil {
+		return "", fmt.Errorf("error:%v", err)
+	}
+
+	return "trainer commission is updated", nil
+}
+
+// GetTrainerCommission returns the percent of every session price kept by the club, paid to the treasury of the branch of the session
+func (h *HealthClub) GetTrainerCommission(ctx contractapi.TransactionContextInterface) (int, error) {
+
+	commissionbytes, err := ctx.GetStub().GetState(trainerCommissionKey)
+	if err != nil {
+		return 0, fmt.Errorf("error:%v", err)
+	}
+
+	if commissionbytes == nil {
+		return defaultTrainerCommission, nil
+	}
+
+	commission, _ := strconv.Atoi(string(commissionbytes))
+
+	return commission, nil
+}
+
+// releaseSession pays the escrowed price of a session to the trainer less the commission, which goes to the branch treasury,
+// and completes the session
+func releaseSession(ctx contractapi.TransactionContextInterface, booking *SessionBooking, now time.Time) error {
+
+	payee, err := revenueAccount(ctx, booking.BranchID)
+	if err != nil {
+		return err
+	}
+
+	batch := erc20.NewTransferBatch(ctx)
+
+	err = batch.Transfer(trainerEscrowAccount, strings.TrimPrefix(booking.TrainerID, userPrefix), booking.Price-booking.Commission)
+	if err != nil {
+		return fmt.Errorf("failed to release escrow: %v", err)
+	}
+
+	if booking.Commission > 0 {
+		err = batch.Transfer(trainerEscrowAccount, payee, booking.Commission)
+		if err != nil {
+			return fmt.Errorf("failed to release escrow: %v", err)
+		}
+
+		err = splitRevenue(ctx, batch, booking.BranchID, revenueSession, booking.MemberID, booking.Commission, now)
+		if err != nil {
+			return err
+		}
+	}
+
+	err = batch.Commit()
+	if err != nil {
+		return fmt.Errorf("error:%v", err)
+	}
+
+	booking.Status = sessionCompleted
+
+	err = putSessionBooking(ctx, booking)
+	if err != nil {
+		return err
+	}
+
+	err = emitEvent(ctx, events.SessionCompleted, booking)
+	if err != nil {
+		return err
+	}
+
+	log.Printf("session %v completed, %v tokens released to %v", booking.BookingID, booking.Price-booking.Commission, booking.TrainerID)
+
+	return nil
+}
+
+// refundSession refunds the escrowed price of a session to the member
+func refundSession(ctx contractapi.TransactionContextInterface, booking *SessionBooking) error {
+
+	batch := erc20.NewTransferBatch(ctx)
+	err := batch.Transfer(trainerEscrowAccount, strings.TrimPrefix(booking.MemberID, userPrefix), booking.Price)
+	if err != nil {
+		return fmt.Errorf("failed to refund escrow: %v", err)
+	}
+
+	err = batch.Commit()
+	if err != nil {
+		return fmt.Errorf("error:%v", err)
+	}
+
+	booking.Status = sessionRefunded
+
+	err = putSessionBooking(ctx, booking)
+	if err != nil {
+		return err
+	}
+
+	return emitEvent(ctx, events.SessionRefunded, booking)
+}
+
+// getTrainer reads a trainer from the world state
+func getTrainer(ctx contractapi.TransactionContextInterface, trainerId string) (*Trainer, error) {
+
+	trainerKey, err := ctx.GetStub().CreateCompositeKey(trainerIndex, []string{trainerId})
+	if err != nil {
+		return nil, fmt.Errorf("failed to create the composite key for prefix %s: %v", trainerIndex, err)
+	}
+
+	trainerbytes, err := ctx.GetStub().GetState(trainerKey)
+	if err != nil {
+		return nil, fmt.Errorf("error:%v", err)
+	}
+
+	if trainerbytes == nil {
+		return nil, fmt.Errorf("trainer %v not found", trainerId)
+	}
+
+	trainer := new(Trainer)
+	_ = json.Unmarshal(trainerbytes, &trainer)
+
+	return trainer, nil
+}
+
+// putTrainer writes a trainer to the world state
+func putTrainer(ctx contractapi.TransactionContextInterface, trainer *Trainer) error {
+
+	trainerKey, err := ctx.GetStub().CreateCompositeKey(trainerIndex, []string{trainer.TrainerID})
+	if err != nil {
+		return fmt.Errorf("failed to create the composite key for prefix %s: %v", trainerIndex, err)
+	}
+
+	trainerbytes, _ := json.Marshal(trainer)
+	err = ctx.GetStub().PutState(trainerKey, trainerbytes)
+	if err != nil {
+		return fmt.Errorf("error:%v", err)
+	}
+
+	return nil
+}
+
+// getSessionOffer reads a session offer from the world state
+func getSessionOffer(ctx contractapi.TransactionContextInterface, offerId string) (*SessionOffer, error) {
+
+	offerKey, err := ctx.GetStub().CreateCompositeKey(sessionOfferIndex, []string{offerId})
+	if err != nil {
+		return nil, fmt.Errorf("failed to create the composite key for prefix %s: %v", sessionOfferIndex, err)
+	}
+
+	offerbytes, err := ctx.GetStub().GetState(offerKey)
+	if err != nil {
+		return nil, fmt.Errorf("error:%v", err)
+	}
+
+	if offerbytes == nil {
+		return nil, fmt.Errorf("session offer %v not found", offerId)
+	}
+
+	offer := new(SessionOffer)
+	_ = json.Unmarshal(offerbytes, &offer)
+
+	return offer, nil
+}
+
+// putSessionOffer writes a session offer to the world state
+func putSessionOffer(ctx contractapi.TransactionContextInterface, offer *SessionOffer) error {
+
+	offerKey, err := ctx.GetStub().CreateCompositeKey(sessionOfferIndex, []string{offer.OfferID})
+	if err != nil {
+		return fmt.Errorf("failed to create the composite key for prefix %s: %v", sessionOfferIndex, err)
+	}
+
+	offerbytes, _ := json.Marshal(offer)
+	err = ctx.GetStub().PutState(offerKey, offerbytes)
+	if err != nil {
+		return fmt.Errorf("error:%v", err)
+	}
+
+	return nil
+}
+
+// getSessionBooking reads a booked session from the world state
+func getSessionBooking(ctx contractapi.TransactionContextInterface, bookingId string) (*SessionBooking, error) {
+
+	bookingKey, err := ctx.GetStub().CreateCompositeKey(sessionBookingIndex, []string{bookingId})
+	if err != nil {
+		return nil, fmt.Errorf("failed to create the composite key for prefix %s: %v", sessionBookingIndex, err)
+	}
+
+	bookingbytes, err := ctx.GetStub().GetState(bookingKey)
+	if err != nil {
+		return nil, fmt.Errorf("error:%v", err)
+	}
+
+	if bookingbytes == nil {
+		return nil, fmt.Errorf("session booking %v not found", bookingId)
+	}
+
+	booking := new(SessionBooking)
+	_ = json.Unmarshal(bookingbytes, &booking)
+
+	return booking, nil
+}
+
+// putSessionBooking writes a booked session to the world state
+func putSessionBooking(ctx contractapi.TransactionContextInterface, booking *SessionBooking) error {
+
+	bookingKey, err := ctx.GetStub().CreateCompositeKey(sessionBookingIndex, []string{booking.BookingID})
+	if err != nil {
+		return fmt.Errorf("failed to create the composite key for prefix %s: %v", sessionBookingIndex, err)
+	}
+
+	bookingbytes, _ := json.Marshal(booking)
+	err = ctx.GetStub().PutState(bookingKey, bookingbytes)
+	if err != nil {
+		return fmt.Errorf("error:%v", err)
+	}
+
+	return nil
+}
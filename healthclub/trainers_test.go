@@ -0,0 +1,198 @@
+package healthclub
+
+import (
+	"testing"
+	"time"
+
+	"github.com/hyperledger/fabric-contract-api-go/contractapi"
+	"github.com/varun425/MiniClubChaincode/internal/chaincodetest"
+)
+
+// newTrainer registers and approves the trainer tom offering the session pt at 200 tokens in slots starting a day apart from tomorrow
+func newTrainer(t *testing.T, c *testClub, slots int) *chaincodetest.Identity {
+
+	t.Helper()
+
+	tom := c.member(t, "tom", 0)
+	c.mustInvoke(t, tom, func(ctx contractapi.TransactionContextInterface) error {
+		_, err := c.h.RegisterTrainer(ctx, "Tom", "strength coach")
+		return err
+	})
+	c.mustInvoke(t, c.staff, func(ctx contractapi.TransactionContextInterface) error {
+		_, err := c.h.ApproveTrainer(ctx, tom.ID)
+		return err
+	})
+
+	offered := []string{}
+	for day := 1; day <= slots; day++ {
+		offered = append(offered, testStart.AddDate(0, 0, day).Format(timeFormat))
+	}
+
+	c.mustInvoke(t, tom, func(ctx contractapi.TransactionContextInterface) error {
+		_, err := c.h.CreateSessionOffer(ctx, "pt", "Strength", 200, offered)
+		return err
+	})
+
+	return tom
+}
+
+// bookSession books the slot on the given day of the pt offer for the member and returns the booking id
+func bookSession(t *testing.T, c *testClub, member *chaincodetest.Identity, day int) string {
+
+	t.Helper()
+
+	var bookingId string
+	c.mustInvoke(t, member, func(ctx contractapi.TransactionContextInterface) error {
+		var err error
+		bookingId, err = c.h.BookSession(ctx, "pt", testStart.AddDate(0, 0, day).Format(timeFormat))
+		return err
+	})
+
+	return bookingId
+}
+
+// sessionStatus returns the committed status of the booking
+func sessionStatus(t *testing.T, c *testClub, bookingId string) string {
+
+	t.Helper()
+
+	var booking *SessionBooking
+	c.mustInvoke(t, c.staff, func(ctx contractapi.TransactionContextInterface) error {
+		var err error
+		booking, err = c.h.GetSessionBooking(ctx, bookingId)
+		return err
+	})
+
+	return booking.Status
+}
+
+func TestSessionReleasedToTrainerAfterDisputeWindow(t *testing.T) {
+
+	c := newTestClub(t)
+	tom := newTrainer(t, c, 1)
+	alice := c.member(t, "alice", 1100)
+	c.buy(t, alice, goldlevel)
+
+	bookingId := bookSession(t, c, alice, 1)
+	c.assertBalances(t, map[string]int{alice.ID: 0, trainerEscrowAccount: 200})
+
+	confirm := func(ctx contractapi.TransactionContextInterface) error {
+		_, err := c.h.ConfirmSession(ctx, bookingId)
+		return err
+	}
+
+	// alice never confirms, so the escrow is held while alice can still dispute
+	c.after(25 * time.Hour)
+	c.mustInvoke(t, tom, confirm)
+	if status := sessionStatus(t, c, bookingId); status != sessionBooked {
+		t.Fatalf("session is %v within the dispute window, want %v", status, sessionBooked)
+	}
+
+	c.after(sessionDisputeWindow)
+	if _, err := c.invoke(alice, func(ctx contractapi.TransactionContextInterface) error {
+		_, err := c.h.DisputeSession(ctx, bookingId, "trainer did not show up")
+		return err
+	}); err == nil {
+		t.Fatalf("session disputed after the dispute window")
+	}
+
+	c.mustInvoke(t, tom, confirm)
+	if status := sessionStatus(t, c, bookingId); status != sessionCompleted {
+		t.Errorf("session is %v, want %v", status, sessionCompleted)
+	}
+	c.assertBalances(t, map[string]int{tom.ID: 260, trainerEscrowAccount: 0, c.owner.ID: 1040})
+}
+
+func TestDisputedSessionResolvedByStaff(t *testing.T) {
+
+	c := newTestClub(t)
+	tom := newTrainer(t, c, 2)
+	alice := c.member(t, "alice", 1300)
+	c.buy(t, alice, goldlevel)
+
+	refunded := bookSession(t, c, alice, 1)
+	released := bookSession(t, c, alice, 2)
+	c.assertBalances(t, map[string]int{alice.ID: 0, trainerEscrowAccount: 400})
+
+	c.after(3 * 24 * time.Hour)
+	for _, bookingId := range []string{refunded, released} {
+		c.mustInvoke(t, alice, func(ctx contractapi.TransactionContextInterface) error {
+			_, err := c.h.DisputeSession(ctx, bookingId, "session was cut short")
+			return err
+		})
+	}
+
+	// a disputed session is no longer released by the trainer alone
+	c.after(sessionDisputeWindow)
+	if _, err := c.invoke(tom, func(ctx contractapi.TransactionContextInterface) error {
+		_, err := c.h.ConfirmSession(ctx, released)
+		return err
+	}); err == nil {
+		t.Fatalf("disputed session confirmed by the trainer")
+	}
+
+	resolve := func(bookingId string, releaseToTrainer bool) func(ctx contractapi.TransactionContextInterface) error {
+		return func(ctx contractapi.TransactionContextInterface) error {
+			_, err := c.h.ResolveSession(ctx, bookingId, releaseToTrainer)
+			return err
+		}
+	}
+
+	if _, err := c.invoke(tom, resolve(released, true)); err == nil {
+		t.Fatalf("disputed session resolved by the trainer")
+	}
+
+	c.mustInvoke(t, c.staff, resolve(refunded, false))
+	c.mustInvoke(t, c.staff, resolve(released, true))
+
+	if status := sessionStatus(t, c, refunded); status != sessionRefunded {
+		t.Errorf("refunded session is %v, want %v", status, sessionRefunded)
+	}
+	if status := sessionStatus(t, c, released); status != sessionCompleted {
+		t.Errorf("released session is %v, want %v", status, sessionCompleted)
+	}
+	c.assertBalances(t, map[string]int{alice.ID: 200, tom.ID: 260, trainerEscrowAccount: 0, c.owner.ID: 1040})
+
+	if _, err := c.invoke(c.staff, resolve(refunded, true)); err == nil {
+		t.Errorf("resolved session resolved again")
+	}
+}
+
+func TestUnconfirmedSessionReclaimedByMember(t *testing.T) {
+
+	c := newTestClub(t)
+	tom := newTrainer(t, c, 1)
+	alice := c.member(t, "alice", 1100)
+	c.buy(t, alice, goldlevel)
+
+	bookingId := bookSession(t, c, alice, 1)
+
+	reclaim := func(ctx contractapi.TransactionContextInterface) error {
+		_, err := c.h.ReclaimSession(ctx, bookingId)
+		return err
+	}
+
+	// tom never confirms the session
+	c.after(24*time.Hour + sessionDisputeWindow)
+	if _, err := c.invoke(alice, reclaim); err == nil {
+		t.Fatalf("session reclaimed before the confirm deadline")
+	}
+
+	c.after(sessionConfirmDeadline - sessionDisputeWindow)
+	if _, err := c.invoke(tom, reclaim); err == nil {
+		t.Fatalf("session reclaimed by the trainer")
+	}
+
+	c.mustInvoke(t, alice, reclaim)
+	if status := sessionStatus(t, c, bookingId); status != sessionRefunded {
+		t.Errorf("session is %v, want %v", status, sessionRefunded)
+	}
+	c.assertBalances(t, map[string]int{alice.ID: 200, tom.ID: 100, trainerEscrowAccount: 0})
+
+	if _, err := c.invoke(tom, func(ctx contractapi.TransactionContextInterface) error {
+		_, err := c.h.ConfirmSession(ctx, bookingId)
+		return err
+	}); err == nil {
+		t.Errorf("reclaimed session confirmed by the trainer")
+	}
+}
@@ -0,0 +1,137 @@
+package healthclub
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/hyperledger/fabric-contract-api-go/contractapi"
+)
+
+// TierChange records an upgrade of a membership from one level to another
+type TierChange struct {
+	FromLevel  string `json:"fromlevel"`
+	ToLevel    string `json:"tolevel"`
+	Date       string `json:"date"`
+	Credit     int    `json:"credit"`
+	AmountPaid int    `json:"amountpaid"`
+}
+
+// UpgradeQuote is the price of upgrading a membership at a given date
+// UnusedCredit is the value of the unused days of the current term, AmountDue is what the member pays
+type UpgradeQuote struct {
+	MembershipID string `json:"membershipid"`
+	FromLevel    string `json:"fromlevel"`
+	ToLevel      string `json:"tolevel"`
+	NewPrice     int    `json:"newprice"`
+	TermDays     int    `json:"termdays"`
+	UsedDays     int    `json:"useddays"`
+	UnusedCredit int    `json:"unusedcredit"`
+	AmountDue    int    `json:"amountdue"`
+	StartDate    string `json:"startdate"`
+	EndDate      string `json:"enddate"`
+}
+
+// QuoteUpgrade returns the price the caller would pay today to upgrade the current membership to the given level
+func (h *HealthClub) QuoteUpgrade(ctx contractapi.TransactionContextInterface, level string) (*UpgradeQuote, error) {
+
+	userid, err := ctx.GetClientIdentity().GetID()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get userID: %v", err)
+	}
+
+	currentmembershipId, membershipdetails, err := getCurrentMembership(ctx, userPrefix+userid)
+	if err != nil {
+		return nil, fmt.Errorf("error:%v", err)
+	}
+
+	now, err := getTxTime(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("error:%v", err)
+	}
+
+	return h.quoteUpgrade(ctx, currentmembershipId, membershipdetails, level, now)
+}
+
+// quoteUpgrade prices an upgrade of the membership to the given level at the given time.
+// The tokens deposited for the current term are spread evenly over its days and the unused days are
+// credited against the price of the new level. The new term starts at the upgrade and lasts the
+// calendar months of the new level.
+func (h *HealthClub) quoteUpgrade(ctx contractapi.TransactionContextInterface, membershipId string, membership *Membership, level string, now time.Time) (*UpgradeQuote, error) {
+
+	if level != goldlevel && level != diamondlevel && level != platinumlevel {
+		return nil, fmt.Errorf("level not matched,expected Gold,Platinum,Diamond but given: %v", level)
+	}
+
+	if membership.Status != StatusActive {
+		return nil, fmt.Errorf("cannot upgrade a %v membership", membership.Status)
+	}
+
+	// the unused credit would include the share of the corporate account
+	if membership.SponsoredBy != "" {
+		return nil, fmt.Errorf("membership is sponsored by %v and cannot be upgraded", membership.SponsoredBy)
+	}
+
+	if membership.Level == level {
+		return nil, fmt.Errorf("already on %v level", level)
+	}
+
+	currentLevel, err := h.GetLevelDetails(ctx, membership.Level)
+	if err != nil {
+		return nil, fmt.Errorf("error:%v", err)
+	}
+
+	newLevel, err := h.GetLevelDetails(ctx, level)
+	if err != nil {
+		return nil, fmt.Errorf("error:%v", err)
+	}
+
+	// prices of the home branch of the membership
+	currentPrice, err := levelPrice(ctx, membership.Level, currentLevel, membership.BranchID)
+	if err != nil {
+		return nil, err
+	}
+
+	newPrice, err := levelPrice(ctx, level, newLevel, membership.BranchID)
+	if err != nil {
+		return nil, err
+	}
+
+	if newPrice <= currentPrice {
+		return nil, fmt.Errorf("cannot de-grade membership from %v to %v, use DowngradeMembership", membership.Level, level)
+	}
+
+	membershipstartDate, _ := time.Parse(dateFormat, membership.StartDate)
+	membershipendDate, _ := time.Parse(dateFormat, membership.EndDate)
+
+	if !now.Before(membershipendDate) {
+		return nil, fmt.Errorf("membership expired on %v cannot update", membership.EndDate)
+	}
+
+	termDays := daysBetween(membershipstartDate, membershipendDate)
+	usedDays := daysBetween(membershipstartDate, now)
+	if usedDays < 0 {
+		usedDays = 0
+	}
+
+	unusedCredit := 0
+	if termDays > 0 && usedDays < termDays {
+		unusedCredit = (membership.TokenDeposited * (termDays - usedDays)) / termDays
+	}
+
+	if unusedCredit > newPrice {
+		unusedCredit = newPrice
+	}
+
+	return &UpgradeQuote{
+		MembershipID: membershipId,
+		FromLevel:    membership.Level,
+		ToLevel:      level,
+		NewPrice:     newPrice,
+		TermDays:     termDays,
+		UsedDays:     usedDays,
+		UnusedCredit: unusedCredit,
+		AmountDue:    newPrice - unusedCredit,
+		StartDate:    now.Format(dateFormat),
+		EndDate:      now.AddDate(0, newLevel.Months, 0).Format(dateFormat),
+	}, nil
+}
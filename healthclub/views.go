@@ -0,0 +1,80 @@
+package healthclub
+
+import (
+	"time"
+
+	"github.com/hyperledger/fabric-contract-api-go/contractapi"
+)
+
+// MembershipView is a membership as returned by queries, with its ledger key, its owner and fields derived at the query time
+// CurrentStatus is Expired for an Active membership past its end date that has not been expired yet.
+// DaysRemaining counts the days left in the term of an Active or Frozen membership
+type MembershipView struct {
+	MembershipID  string      `json:"membershipid"`
+	OwnerID       string      `json:"ownerid"`
+	CurrentStatus string      `json:"currentstatus"`
+	DaysRemaining int         `json:"daysremaining"`
+	Membership    *Membership `json:"membership"`
+}
+
+// UserView is a user as returned by queries, with its ledger key and the state of the current membership
+type UserView struct {
+	UserID              string `json:"userid"`
+	CurrentMembershipID string `json:"currentmembershipid"`
+	CurrentStatus       string `json:"currentstatus"`
+	DaysRemaining       int    `json:"daysremaining"`
+	User                *User  `json:"user"`
+}
+
+// newMembershipView derives the fields of the view of the membership stored under membershipId at the given time
+func newMembershipView(membershipId string, membership *Membership, now time.Time) MembershipView {
+
+	view := MembershipView{
+		MembershipID:  membershipId,
+		OwnerID:       membership.UserID,
+		CurrentStatus: membership.Status,
+		Membership:    membership,
+	}
+
+	membershipendDate, _ := time.Parse(dateFormat, membership.EndDate)
+
+	if membership.Status == StatusActive && !now.Before(membershipendDate) {
+		view.CurrentStatus = StatusExpired
+	}
+
+	if view.CurrentStatus == StatusActive || view.CurrentStatus == StatusFrozen {
+		view.DaysRemaining = daysBetween(now, membershipendDate)
+		if view.DaysRemaining < 0 {
+			view.DaysRemaining = 0
+		}
+	}
+
+	return view
+}
+
+// newUserView reads the current membership of the user stored under userId and derives the fields of the view
+func newUserView(ctx contractapi.TransactionContextInterface, userId string, user *User, now time.Time) (UserView, error) {
+
+	view := UserView{
+		UserID: userId,
+		User:   user,
+	}
+
+	if len(user.Memberships) == 0 {
+		return view, nil
+	}
+
+	currentmembershipId := user.Memberships[len(user.Memberships)-1]
+	membershipdetails, err := getMembership(ctx, currentmembershipId)
+	if err != nil {
+		return view, err
+	}
+
+	membershipView := newMembershipView(currentmembershipId, membershipdetails, now)
+
+	view.CurrentMembershipID = currentmembershipId
+	view.CurrentStatus = membershipView.CurrentStatus
+	view.DaysRemaining = membershipView.DaysRemaining
+
+	return view, nil
+}
@@ -0,0 +1,87 @@
+package healthclub
+
+import (
+	"testing"
+	"time"
+
+	"github.com/hyperledger/fabric-contract-api-go/contractapi"
+)
+
+// membershipView returns the view of the membership at the current timestamp
+func membershipView(t *testing.T, c *testClub, membershipId string) *MembershipView {
+
+	t.Helper()
+
+	var view *MembershipView
+	c.mustInvoke(t, c.staff, func(ctx contractapi.TransactionContextInterface) error {
+		var err error
+		view, err = c.h.GetMembershipDetails(ctx, membershipId)
+		return err
+	})
+
+	return view
+}
+
+func TestViewsCarryTheLedgerKeysAndDerivedFields(t *testing.T) {
+
+	c := newTestClub(t)
+	alice := c.member(t, "alice", 900)
+	bob := c.member(t, "bob", 0)
+	membershipId := c.buy(t, alice, goldlevel)
+
+	// the Gold term runs to 04-02-2026
+	c.after(10 * 24 * time.Hour)
+
+	view := membershipView(t, c, membershipId)
+	if view.MembershipID != membershipId || view.OwnerID != userPrefix+alice.ID || view.CurrentStatus != StatusActive || view.DaysRemaining != 20 {
+		t.Errorf("membership view %v of %v, %v with %v days, want %v of alice, Active with 20 days", view.MembershipID, view.OwnerID, view.CurrentStatus, view.DaysRemaining, membershipId)
+	}
+
+	var aliceView, bobView *UserView
+	c.mustInvoke(t, c.staff, func(ctx contractapi.TransactionContextInterface) error {
+		var err error
+		aliceView, err = c.h.GetUserDetails(ctx, alice.ID)
+		if err != nil {
+			return err
+		}
+		bobView, err = c.h.GetUserDetails(ctx, userPrefix+bob.ID)
+		return err
+	})
+	if aliceView.UserID != userPrefix+alice.ID || aliceView.CurrentMembershipID != membershipId || aliceView.CurrentStatus != StatusActive || aliceView.DaysRemaining != 20 {
+		t.Errorf("user view %+v, want alice with %v Active for 20 days", aliceView, membershipId)
+	}
+	if bobView.UserID != userPrefix+bob.ID || bobView.CurrentMembershipID != "" || bobView.CurrentStatus != "" {
+		t.Errorf("user view %+v, want bob without membership", bobView)
+	}
+
+	var memberships []MembershipView
+	var users []UserView
+	c.mustInvoke(t, c.staff, func(ctx contractapi.TransactionContextInterface) error {
+		var err error
+		memberships, err = c.h.GetAllMembershipsOfUsers(ctx)
+		if err != nil {
+			return err
+		}
+		users, err = c.h.GetAllUsers(ctx)
+		return err
+	})
+	if len(memberships) != 1 || memberships[0].MembershipID != membershipId || memberships[0].OwnerID != userPrefix+alice.ID {
+		t.Errorf("memberships %+v, want the membership of alice under its key", memberships)
+	}
+
+	userIds := map[string]bool{}
+	for _, user := range users {
+		userIds[user.UserID] = true
+	}
+	if !userIds[userPrefix+alice.ID] || !userIds[userPrefix+bob.ID] {
+		t.Errorf("users %v, want alice and bob under their keys", userIds)
+	}
+
+	// a membership past its end date reads as expired before ExpireMemberships records it
+	c.after(25 * 24 * time.Hour)
+
+	view = membershipView(t, c, membershipId)
+	if view.CurrentStatus != StatusExpired || view.DaysRemaining != 0 || view.Membership.Status != StatusActive {
+		t.Errorf("membership view %v with %v days of a %v membership, want Expired with 0 days of an Active membership", view.CurrentStatus, view.DaysRemaining, view.Membership.Status)
+	}
+}
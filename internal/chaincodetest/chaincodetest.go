@@ -0,0 +1,296 @@
+// Package chaincodetest runs contract functions against an in-memory world state in tests.
+// As on a peer, GetState returns the state committed before the transaction, the writes of a transaction
+// are visible to the transactions after it only, and they are discarded if the transaction fails.
+package chaincodetest
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/hyperledger/fabric-chaincode-go/pkg/cid"
+	"github.com/hyperledger/fabric-chaincode-go/shim"
+	"github.com/hyperledger/fabric-contract-api-go/contractapi"
+	"github.com/hyperledger/fabric-protos-go/ledger/queryresult"
+	"github.com/hyperledger/fabric-protos-go/peer"
+	"github.com/varun425/MiniClubChaincode/events/envelope"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+// compositeKeyNamespace starts every composite key, range queries skip those keys as on a peer
+const compositeKeyNamespace = "\x00"
+
+// World is the world state of a channel, Now is the timestamp of the next transaction
+type World struct {
+	Now   time.Time
+	state map[string][]byte
+	txs   int
+}
+
+// NewWorld returns an empty world state whose transactions are timestamped now
+func NewWorld(now time.Time) *World {
+	return &World{
+		Now:   now,
+		state: map[string][]byte{},
+	}
+}
+
+// Invoke runs fn as one transaction of the caller and commits its writes if fn returns nil
+// The context of the transaction is returned to inspect the events it emitted
+func (w *World) Invoke(caller *Identity, fn func(ctx contractapi.TransactionContextInterface) error) (*envelope.TransactionContext, error) {
+
+	w.txs++
+	txStub := &Stub{
+		world:  w,
+		txID:   "tx" + strconv.Itoa(w.txs),
+		writes: map[string][]byte{},
+	}
+
+	ctx := new(envelope.TransactionContext)
+	ctx.SetStub(txStub)
+	ctx.SetClientIdentity(caller)
+
+	err := fn(ctx)
+	if err != nil {
+		return ctx, err
+	}
+
+	for key, value := range txStub.writes {
+		if value == nil {
+			delete(w.state, key)
+			continue
+		}
+		w.state[key] = value
+	}
+
+	return ctx, nil
+}
+
+// Get returns the committed value of a key, nil if it is not set
+func (w *World) Get(key string) []byte {
+	return w.state[key]
+}
+
+// Put sets the committed value of a key
+func (w *World) Put(key string, value []byte) {
+	w.state[key] = value
+}
+
+// Balance returns the committed token balance of an account
+func (w *World) Balance(account string) int {
+
+	balance, _ := strconv.Atoi(string(w.state[account]))
+
+	return balance
+}
+
+// Identity is a client identity of the Org1MSP, the MSP allowed to initialize the erc20 contract
+type Identity struct {
+	cid.ClientIdentity
+	ID         string
+	MSPID      string
+	Attributes map[string]string
+}
+
+// NewIdentity returns an Org1MSP identity with the given ID and attributes
+func NewIdentity(id string, attributes map[string]string) *Identity {
+	return &Identity{
+		ID:         id,
+		MSPID:      "Org1MSP",
+		Attributes: attributes,
+	}
+}
+
+func (i *Identity) GetID() (string, error) {
+	return i.ID, nil
+}
+
+func (i *Identity) GetMSPID() (string, error) {
+	return i.MSPID, nil
+}
+
+func (i *Identity) GetAttributeValue(attrName string) (string, bool, error) {
+
+	value, found := i.Attributes[attrName]
+
+	return value, found, nil
+}
+
+// Stub implements the world state functions of the chaincode stub used by the contracts for one transaction
+// The other functions of shim.ChaincodeStubInterface panic
+type Stub struct {
+	shim.ChaincodeStubInterface
+	world  *World
+	txID   string
+	writes map[string][]byte
+	events map[string][]byte
+}
+
+func (s *Stub) GetTxID() string {
+	return s.txID
+}
+
+func (s *Stub) GetTxTimestamp() (*timestamppb.Timestamp, error) {
+	return timestamppb.New(s.world.Now), nil
+}
+
+func (s *Stub) GetState(key string) ([]byte, error) {
+	return s.world.state[key], nil
+}
+
+func (s *Stub) PutState(key string, value []byte) error {
+
+	if key == "" {
+		return fmt.Errorf("key must not be an empty string")
+	}
+
+	// a nil value marks a deleted key
+	if value == nil {
+		value = []byte{}
+	}
+	s.writes[key] = value
+
+	return nil
+}
+
+func (s *Stub) DelState(key string) error {
+
+	s.writes[key] = nil
+
+	return nil
+}
+
+func (s *Stub) SetEvent(name string, payload []byte) error {
+
+	if s.events == nil {
+		s.events = map[string][]byte{}
+	}
+	s.events[name] = payload
+
+	return nil
+}
+
+func (s *Stub) CreateCompositeKey(objectType string, attributes []string) (string, error) {
+
+	key := compositeKeyNamespace + objectType + compositeKeyNamespace
+	for _, attribute := range attributes {
+		if strings.Contains(attribute, compositeKeyNamespace) {
+			return "", fmt.Errorf("attribute %q contains U+0000", attribute)
+		}
+		key += attribute + compositeKeyNamespace
+	}
+
+	return key, nil
+}
+
+func (s *Stub) SplitCompositeKey(compositeKey string) (string, []string, error) {
+
+	if !strings.HasPrefix(compositeKey, compositeKeyNamespace) {
+		return "", nil, fmt.Errorf("%q is not a composite key", compositeKey)
+	}
+
+	parts := strings.Split(strings.TrimSuffix(compositeKey[1:], compositeKeyNamespace), compositeKeyNamespace)
+
+	return parts[0], parts[1:], nil
+}
+
+func (s *Stub) GetStateByRange(startKey string, endKey string) (shim.StateQueryIteratorInterface, error) {
+
+	keys := []string{}
+	for key := range s.world.state {
+		if strings.HasPrefix(key, compositeKeyNamespace) || key < startKey || (endKey != "" && key >= endKey) {
+			continue
+		}
+		keys = append(keys, key)
+	}
+
+	return s.iterator(keys), nil
+}
+
+// GetStateByRangeWithPagination returns up to pageSize keys of the range starting at the bookmark, as on a peer
+// the bookmark of the next page is the key following the page and is empty after the last page
+func (s *Stub) GetStateByRangeWithPagination(startKey string, endKey string, pageSize int32, bookmark string) (shim.StateQueryIteratorInterface, *peer.QueryResponseMetadata, error) {
+
+	if bookmark != "" {
+		startKey = bookmark
+	}
+
+	keys := []string{}
+	for key := range s.world.state {
+		if strings.HasPrefix(key, compositeKeyNamespace) || key < startKey || (endKey != "" && key >= endKey) {
+			continue
+		}
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	metadata := &peer.QueryResponseMetadata{}
+	if len(keys) > int(pageSize) {
+		metadata.Bookmark = keys[pageSize]
+		keys = keys[:pageSize]
+	}
+	metadata.FetchedRecordsCount = int32(len(keys))
+
+	return s.iterator(keys), metadata, nil
+}
+
+func (s *Stub) GetStateByPartialCompositeKey(objectType string, attributes []string) (shim.StateQueryIteratorInterface, error) {
+
+	prefix, err := s.CreateCompositeKey(objectType, attributes)
+	if err != nil {
+		return nil, err
+	}
+
+	keys := []string{}
+	for key := range s.world.state {
+		if strings.HasPrefix(key, prefix) {
+			keys = append(keys, key)
+		}
+	}
+
+	return s.iterator(keys), nil
+}
+
+// Event returns the payload of the chaincode event set under name in the transaction, nil if none
+func (s *Stub) Event(name string) []byte {
+	return s.events[name]
+}
+
+func (s *Stub) iterator(keys []string) *iterator {
+
+	sort.Strings(keys)
+
+	results := []*queryresult.KV{}
+	for _, key := range keys {
+		results = append(results, &queryresult.KV{Key: key, Value: s.world.state[key]})
+	}
+
+	return &iterator{results: results}
+}
+
+// iterator iterates over a snapshot of the query results
+type iterator struct {
+	results []*queryresult.KV
+}
+
+func (i *iterator) HasNext() bool {
+	return len(i.results) > 0
+}
+
+func (i *iterator) Next() (*queryresult.KV, error) {
+
+	if len(i.results) == 0 {
+		return nil, fmt.Errorf("no more results")
+	}
+
+	result := i.results[0]
+	i.results = i.results[1:]
+
+	return result, nil
+}
+
+func (i *iterator) Close() error {
+	return nil
+}
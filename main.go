@@ -3,13 +3,18 @@ package main
 import (
 	"log"
 
+	"github.com/hyperledger/fabric-contract-api-go/contractapi"
 	"github.com/varun425/MiniClubChaincode/erc20"
+	"github.com/varun425/MiniClubChaincode/events/envelope"
 	"github.com/varun425/MiniClubChaincode/healthclub"
-	"github.com/hyperledger/fabric-contract-api-go/contractapi"
 )
 
 func main() {
-	miniclub, err := contractapi.NewChaincode(&healthclub.HealthClub{}, &erc20.SmartContract{})
+	// HealthClub transactions emit one HealthClubEvents envelope carrying all their events
+	healthClub := &healthclub.HealthClub{}
+	healthClub.TransactionContextHandler = new(envelope.TransactionContext)
+
+	miniclub, err := contractapi.NewChaincode(healthClub, &erc20.SmartContract{})
 	if err != nil {
 		log.Panicf("Error creating miniclub chaincode: %v", err)
 	}